@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"fiet/model"
+	"fiet/repository"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot.
+const APIKeyPrefix = "fiet_"
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to tell
+// keys apart.
+const apiKeyPrefixLength = len(APIKeyPrefix) + 6
+
+// apiKeyTouchInterval limits how often last_used_at is written for a key
+// in busy use.
+const apiKeyTouchInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for unknown, expired or revoked keys and for
+// keys of accounts that cannot log in.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// NewAPIKey returns a random API key, the prefix to show in its place and
+// the hash to store.
+func NewAPIKey() (key string, prefix string, hash string, err error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + token
+	return key, key[:apiKeyPrefixLength], HashToken(key), nil
+}
+
+// APIKeyVerifier authenticates requests made with an API key. Unlike access
+// tokens, keys are looked up on every request, so revoking a key or
+// disabling its owner takes effect immediately.
+type APIKeyVerifier struct {
+	Keys  repository.APIKeyRepository
+	Users repository.UserRepository
+	Roles repository.RoleRepository
+}
+
+func NewAPIKeyVerifier(keys repository.APIKeyRepository, users repository.UserRepository, roles repository.RoleRepository) *APIKeyVerifier {
+	return &APIKeyVerifier{Keys: keys, Users: users, Roles: roles}
+}
+
+// Verify looks up raw and returns the key with the roles of its owner and
+// the permissions it may use: those of its scopes the owner still holds.
+func (v *APIKeyVerifier) Verify(ctx context.Context, raw string) (*model.APIKey, []string, []string, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return nil, nil, nil, ErrInvalidAPIKey
+	}
+	key, err := v.Keys.GetByHash(ctx, HashToken(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, nil, err
+	}
+
+	user, err := v.Users.GetByUUID(ctx, key.UserUUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, nil, err
+	}
+	if user.DisabledAt != nil || user.PasswordResetRequired {
+		return nil, nil, nil, ErrInvalidAPIKey
+	}
+
+	roles, err := v.Roles.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	held, err := v.Roles.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	permissions := []string{}
+	for _, scope := range strings.Fields(key.Scopes) {
+		if slices.Contains(held, scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := v.Keys.Touch(ctx, key.ID, now); err != nil {
+			log.Println("Error recording API key use:", err)
+		}
+	}
+	return key, roles, permissions, nil
+}
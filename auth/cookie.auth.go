@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"fiet/config"
+)
+
+// CookieConfig holds the attributes of the cookies the API sets.
+type CookieConfig struct {
+	// Domain is empty for host-only cookies
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// LoadCookieConfig reads COOKIE_DOMAIN, COOKIE_SECURE and COOKIE_SAMESITE.
+// Cookies are host-only, Secure and SameSite=Lax by default.
+func LoadCookieConfig() (CookieConfig, error) {
+	cfg := CookieConfig{
+		Domain:   os.Getenv("COOKIE_DOMAIN"),
+		Secure:   config.Bool("COOKIE_SECURE", true),
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "", "lax":
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure
+		if !cfg.Secure {
+			return cfg, fmt.Errorf("COOKIE_SAMESITE=none requires COOKIE_SECURE")
+		}
+		cfg.SameSite = http.SameSiteNoneMode
+	default:
+		return cfg, fmt.Errorf("unknown COOKIE_SAMESITE %q", os.Getenv("COOKIE_SAMESITE"))
+	}
+	return cfg, nil
+}
+
+// Cookie returns a cookie with the configured attributes. A negative
+// maxAge deletes the cookie.
+func (cfg CookieConfig) Cookie(name string, value string, path string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	}
+}
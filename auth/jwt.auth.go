@@ -1,45 +1,105 @@
 package auth
 
 import (
-	"fmt"
+	"fiet/config"
+	"fiet/model"
 	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
-var jwtSecret []byte // 🔐 Store this securely (env or config)
+var keys *KeySet // 🔐 Loaded from JWT_* env, see LoadKeySet
+
+var (
+	// AccessTokenTTL is the lifetime of JWTs from GenerateToken.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the lifetime of each refresh token.
+	RefreshTokenTTL time.Duration
+	// PasswordResetTTL is how long a password reset link stays valid.
+	PasswordResetTTL time.Duration
+	// EmailVerificationTTL is how long an email verification link stays valid.
+	EmailVerificationTTL time.Duration
+	// MFAChallengeTTL is how long a login may take to enter the second factor.
+	MFAChallengeTTL time.Duration
+	// MagicLinkTTL is how long an emailed sign-in link stays valid.
+	MagicLinkTTL time.Duration
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+)
 
 func init() {
-	fmt.Println("JWT Secret:", jwtSecret)
 	err := godotenv.Load("dev.env")
 	if err != nil {
 		log.Println("Warning loading .env file")
 	}
-	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-	fmt.Println("JWT Secret:", jwtSecret)
+	keys, err = LoadKeySet()
+	if err != nil {
+		log.Fatalf("Invalid JWT key configuration: %v", err)
+	}
+	log.Printf("Signing JWTs with %s (kid %s)", keys.Signing.Method.Alg(), keys.Signing.ID)
+
+	// Millisecond iat so a token issued right before "logout everywhere"
+	// can be told apart from one issued right after it.
+	jwt.TimePrecision = time.Millisecond
+
+	AccessTokenTTL = config.Duration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	RefreshTokenTTL = config.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	PasswordResetTTL = config.Duration("PASSWORD_RESET_TTL", time.Hour)
+	EmailVerificationTTL = config.Duration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	MFAChallengeTTL = config.Duration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	MagicLinkTTL = config.Duration("MAGIC_LINK_TTL", 15*time.Minute)
+	if TOTPIssuer = os.Getenv("TOTP_ISSUER"); TOTPIssuer == "" {
+		TOTPIssuer = "Fiet"
+	}
 }
 
-func GenerateToken(userUUID string) (string, error) {
+// GenerateToken issues an access token for the login session sessionID.
+// Roles and permissions are embedded so authorization checks do not need a
+// database round trip.
+func GenerateToken(userUUID string, sessionID int, roles []string, permissions []string) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"user_uuid": userUUID,
-		"exp":       time.Now().Add(24 * time.Hour).Unix(),
-		"iat":       time.Now().Unix(), // issued at
-		"nbf":       time.Now().Unix(), // not before
+		"user_uuid":   userUUID,
+		"sid":         sessionID, // lets the tokens of one login be revoked
+		"roles":       roles,
+		"permissions": permissions,
+		"jti":         uuid.New().String(), // lets a single token be revoked
+		"exp":         jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		"iat":         jwt.NewNumericDate(now), // issued at
+		"nbf":         jwt.NewNumericDate(now), // not before
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return keys.Sign(claims)
+}
+
+// GenerateServiceToken issues an access token to a service account. It
+// names the account in sub, with sub_type service, instead of user_uuid.
+func GenerateServiceToken(accountUUID string, permissions []string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":         accountUUID,
+		"sub_type":    model.PrincipalService,
+		"roles":       []string{},
+		"permissions": permissions,
+		"jti":         uuid.New().String(),
+		"exp":         jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		"iat":         jwt.NewNumericDate(now),
+		"nbf":         jwt.NewNumericDate(now),
+	}
+
+	return keys.Sign(claims)
 }
 
 func ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	// Keyfunc validates the signing method against the key
+	return jwt.Parse(tokenString, keys.Keyfunc)
+}
+
+// Keys returns the active key set, e.g. to publish it as JWKS.
+func Keys() *KeySet {
+	return keys
 }
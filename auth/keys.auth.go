@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a JWT signing or verification key. Asymmetric keys are
+// identified by their RFC 7638 thumbprint, which becomes the kid header.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private is []byte for HS256, otherwise a crypto.Signer. Nil for
+	// verification-only keys.
+	Private interface{}
+	// Public is []byte for HS256, otherwise the crypto.PublicKey.
+	Public interface{}
+}
+
+// KeySet signs tokens with one key and verifies them with any of the
+// known keys, so a new signing key can be rolled out while tokens signed
+// by the previous one are still accepted.
+type KeySet struct {
+	Signing *Key
+	Verify  map[string]*Key // by kid, includes Signing
+}
+
+// hmacKeyID is the kid of the shared secret. It is never published.
+const hmacKeyID = "hs256"
+
+// LoadKeySet builds the key set from the environment:
+//
+//	JWT_ALG               HS256 (default), RS256, ES256 or EdDSA
+//	JWT_SECRET            shared secret for HS256
+//	JWT_PRIVATE_KEY_FILE  PEM private key for RS256/ES256/EdDSA
+//	JWT_VERIFY_KEY_FILES  comma separated PEM keys of previous signing
+//	                      keys that are still accepted during rotation
+func LoadKeySet() (*KeySet, error) {
+	alg := os.Getenv("JWT_ALG")
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+
+	var signing *Key
+	if alg == jwt.SigningMethodHS256.Alg() {
+		secret := []byte(os.Getenv("JWT_SECRET"))
+		signing = &Key{ID: hmacKeyID, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+	} else {
+		path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for JWT_ALG=%s", alg)
+		}
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if key.Private == nil {
+			return nil, fmt.Errorf("%s: expected a private key", path)
+		}
+		if key.Method.Alg() != alg {
+			return nil, fmt.Errorf("%s: key is for %s, but JWT_ALG is %s", path, key.Method.Alg(), alg)
+		}
+		signing = key
+	}
+
+	set := &KeySet{Signing: signing, Verify: map[string]*Key{signing.ID: signing}}
+
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFY_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = nil // only ever used to verify
+		set.Verify[key.ID] = key
+	}
+
+	return set, nil
+}
+
+// Sign signs claims with the signing key and sets the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.Signing.Method, claims)
+	token.Header["kid"] = s.Signing.ID
+	return token.SignedString(s.Signing.Private)
+}
+
+// Keyfunc picks the verification key by kid and rejects tokens whose alg
+// does not match that key, so an RSA public key can never be used as an
+// HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Tokens issued before kid was added were always HS256
+		kid = hmacKeyID
+	}
+
+	key, ok := s.Verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS lists the public verification keys. Shared secrets are left out.
+func (s *KeySet) JWKS() []JWK {
+	keys := []JWK{}
+	for _, key := range s.Verify {
+		if jwk, ok := publicJWK(key.Public); ok {
+			jwk.Kid = key.ID
+			jwk.Use = "sig"
+			jwk.Alg = key.Method.Alg()
+			keys = append(keys, jwk)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
+
+func publicJWK(public interface{}) (JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}, true
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: pub.Curve.Params().Name, X: b64(pub.X.FillBytes(make([]byte, size))), Y: b64(pub.Y.FillBytes(make([]byte, size)))}, true
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(pub)}, true
+	}
+	return JWK{}, false
+}
+
+// PublicKey decodes the key, the reverse of publicJWK.
+func (j JWK) PublicKey() (interface{}, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	switch j.Kty {
+	case "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported OKP key %q", j.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as kid.
+func thumbprint(public interface{}) (string, error) {
+	jwk, ok := publicJWK(public)
+	if !ok {
+		return "", errors.New("unsupported public key type")
+	}
+
+	// Required members only, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// readKeyFile loads a PEM private or public key and works out its
+// algorithm and kid.
+func readKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	key := &Key{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key.Private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key.Public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if key.Private != nil {
+		signer, ok := key.Private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported private key type", path)
+		}
+		key.Public = signer.Public()
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s: ES256 needs a P-256 key", path)
+		}
+		key.Method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key.Public)
+	}
+
+	key.ID, err = thumbprint(key.Public)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"fiet/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// oauthPath is where the OAuth endpoints are mounted below the issuer.
+const oauthPath = "/api/v1/oauth"
+
+// accessTokenType marks access tokens issued to OAuth clients (RFC 9068),
+// which only work at the userinfo endpoint.
+const accessTokenType = "at+jwt"
+
+// OAuthScopes are the scopes clients can request, in the order they are
+// shown on the consent screen.
+var OAuthScopes = []string{model.ScopeOpenID, model.ScopeProfile, model.ScopeEmail}
+
+// OAuthServer lets other apps sign users in with their account, as a
+// minimal OpenID Connect provider. Its ID and access tokens are signed
+// with the JWT signing key, which must be asymmetric so that clients can
+// verify them against /.well-known/jwks.json.
+type OAuthServer struct {
+	// Issuer is the public URL of this server, e.g. https://auth.example.com
+	Issuer string
+	// ConsentURL is the frontend page that asks the user to approve a request
+	ConsentURL string
+	// RequestTTL is how long the user has to log in and consent
+	RequestTTL time.Duration
+	// CodeTTL is how long the client has to redeem a code
+	CodeTTL time.Duration
+}
+
+// OAuthAccessClaims are the claims of an access token issued to a client.
+type OAuthAccessClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// NewOAuthServer configures the provider from OAUTH_ISSUER and
+// OAUTH_CONSENT_URL, which defaults to the /oauth/consent page of appURL.
+// Without OAUTH_ISSUER the provider is turned off and nil is returned.
+func NewOAuthServer(appURL string) (*OAuthServer, error) {
+	issuer := strings.TrimSuffix(os.Getenv("OAUTH_ISSUER"), "/")
+	if issuer == "" {
+		return nil, nil
+	}
+	if keys.Signing.Method == jwt.SigningMethodHS256 {
+		return nil, errors.New("OAUTH_ISSUER needs JWT_ALG RS256, ES256 or EdDSA, since clients cannot verify HS256 tokens")
+	}
+
+	consentURL := os.Getenv("OAUTH_CONSENT_URL")
+	if consentURL == "" {
+		consentURL = appURL + "/oauth/consent"
+	}
+	return &OAuthServer{
+		Issuer:     issuer,
+		ConsentURL: consentURL,
+		RequestTTL: 10 * time.Minute,
+		CodeTTL:    time.Minute,
+	}, nil
+}
+
+// Discovery is the OpenID Provider Metadata served at
+// /.well-known/openid-configuration.
+func (s *OAuthServer) Discovery() map[string]interface{} {
+	return map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + oauthPath + "/authorize",
+		"token_endpoint":                        s.Issuer + oauthPath + "/token",
+		"userinfo_endpoint":                     s.Issuer + oauthPath + "/userinfo",
+		"jwks_uri":                              s.Issuer + "/.well-known/jwks.json",
+		"scopes_supported":                      OAuthScopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{keys.Signing.Method.Alg()},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{"iss", "sub", "aud", "exp", "iat", "nonce",
+			"name", "age", "created_at", "updated_at", "email", "email_verified"},
+		"authorization_response_iss_parameter_supported": true,
+	}
+}
+
+// ParseScope keeps the supported scopes of a space separated scope
+// parameter, without duplicates, in the order of OAuthScopes. Unknown
+// scopes are ignored as RFC 6749 allows.
+func ParseScope(scope string) []string {
+	requested := strings.Fields(scope)
+	scopes := []string{}
+	for _, s := range OAuthScopes {
+		if slices.Contains(requested, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// VerifyPKCE checks a code_verifier against the S256 code_challenge.
+func VerifyPKCE(verifier string, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// AccessToken issues a token for the userinfo endpoint. It has no
+// user_uuid claim, so the API itself does not accept it.
+func (s *OAuthServer) AccessToken(userUUID string, clientID string, scope string) (string, error) {
+	now := time.Now()
+	claims := OAuthAccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Subject:   userUUID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+		ClientID: clientID,
+		Scope:    scope,
+	}
+
+	// KeySet.Sign without the typ header
+	token := jwt.NewWithClaims(keys.Signing.Method, claims)
+	token.Header["kid"] = keys.Signing.ID
+	token.Header["typ"] = accessTokenType
+	return token.SignedString(keys.Signing.Private)
+}
+
+// ValidateAccessToken verifies an access token from AccessToken.
+// Revocation is up to the caller.
+func (s *OAuthServer) ValidateAccessToken(raw string) (*OAuthAccessClaims, error) {
+	var claims OAuthAccessClaims
+	token, err := jwt.ParseWithClaims(raw, &claims, keys.Keyfunc,
+		jwt.WithIssuer(s.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if token.Header["typ"] != accessTokenType || claims.Subject == "" || claims.ID == "" {
+		return nil, errors.New("not an OAuth access token")
+	}
+	return &claims, nil
+}
+
+// IDToken issues the OpenID Connect ID token for user, with the claims of
+// the granted scopes.
+func (s *OAuthServer) IDToken(user *model.User, clientID string, nonce string, scopes []string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.Issuer,
+		"sub": user.UUID,
+		"aud": clientID,
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for key, value := range UserClaims(user, scopes) {
+		claims[key] = value
+	}
+	return keys.Sign(claims)
+}
+
+// UserClaims maps the public fields of user to OpenID Connect claims: the
+// profile scope covers the name, age and timestamps, and the email scope
+// the email address and whether it is verified.
+func UserClaims(user *model.User, scopes []string) map[string]interface{} {
+	public := user.Public()
+	claims := map[string]interface{}{"sub": public.UUID}
+	if slices.Contains(scopes, model.ScopeProfile) {
+		if public.Name != nil {
+			claims["name"] = *public.Name
+		}
+		if public.Age != nil {
+			claims["age"] = *public.Age
+		}
+		claims["created_at"] = public.CreatedAt.Unix()
+		claims["updated_at"] = public.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, model.ScopeEmail) {
+		claims["email"] = public.Email
+		claims["email_verified"] = public.EmailVerifiedAt != nil
+	}
+	return claims
+}
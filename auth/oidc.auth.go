@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCStateTTL is how long the user has to log in at the provider.
+const OIDCStateTTL = 10 * time.Minute
+
+// jwksRefreshInterval limits how often an unknown kid makes the provider
+// keys be fetched again.
+const jwksRefreshInterval = time.Minute
+
+// OIDCProvider is an OpenID Connect provider users can log in with, using
+// the authorization code flow with PKCE. Its endpoints and keys are
+// discovered from the issuer on first use.
+type OIDCProvider struct {
+	// Name identifies the provider in URLs and linked identities
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// AllowedDomains, when set, only admits email addresses of these domains
+	AllowedDomains []string
+	// TrustEmail treats every email from the provider as verified, for
+	// providers such as a university directory that own the addresses
+	TrustEmail bool
+
+	HTTPClient *http.Client
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	keys          map[string]interface{} // by kid
+	keysFetchedAt time.Time
+}
+
+// oidcMetadata is the part of the discovery document that is used.
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// OIDCClaims are the ID token claims a login needs.
+type OIDCClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	Name            string `json:"name"`
+}
+
+// LoadOIDCProviders configures the providers named in the comma separated
+// OIDC_PROVIDERS from OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _SCOPES, _DISPLAY_NAME, _ALLOWED_DOMAINS and _TRUST_EMAIL. Every provider
+// redirects back to OIDC_REDIRECT_URL, which defaults to the
+// /login/oidc/callback page of appURL.
+func LoadOIDCProviders(appURL string) (map[string]*OIDCProvider, error) {
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = appURL + "/login/oidc/callback"
+	}
+
+	providers := make(map[string]*OIDCProvider)
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(name)
+		env := func(key string) string {
+			return os.Getenv("OIDC_" + strings.ToUpper(name) + "_" + key)
+		}
+
+		p := &OIDCProvider{
+			Name:           name,
+			DisplayName:    env("DISPLAY_NAME"),
+			Issuer:         strings.TrimSuffix(env("ISSUER"), "/"),
+			ClientID:       env("CLIENT_ID"),
+			ClientSecret:   env("CLIENT_SECRET"),
+			RedirectURL:    redirectURL,
+			Scopes:         splitList(env("SCOPES"), " "),
+			AllowedDomains: splitList(strings.ToLower(env("ALLOWED_DOMAINS")), ","),
+			TrustEmail:     env("TRUST_EMAIL") == "true",
+			HTTPClient:     &http.Client{Timeout: 10 * time.Second},
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs an issuer and a client ID", name)
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func splitList(list string, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(list, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AuthCodeURL is where the browser logs in. The provider sends it back to
+// RedirectURL with a code and the state.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	for key, values := range u.Query() {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems the code from the redirect and returns the claims of
+// the verified ID token. The email comes from the userinfo endpoint when
+// the ID token leaves it out.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*OIDCClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	// client_secret_basic is the default unless only _post is offered
+	postSecret := slices.Contains(metadata.TokenAuthMethods, "client_secret_post") &&
+		!slices.Contains(metadata.TokenAuthMethods, "client_secret_basic")
+	if p.ClientSecret != "" && postSecret {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.ClientSecret != "" && !postSecret {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := p.do(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, metadata, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Email == "" && metadata.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if err := p.userinfo(ctx, metadata, tokens.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// Allows reports whether the email of claims may log in, according to
+// AllowedDomains.
+func (p *OIDCProvider) Allows(claims *OIDCClaims) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(claims.Email, "@")
+	return at >= 0 && slices.Contains(p.AllowedDomains, strings.ToLower(claims.Email[at+1:]))
+}
+
+// VerifiedEmail returns the email of claims if the provider vouches for it.
+func (p *OIDCProvider) VerifiedEmail(claims *OIDCClaims) (string, bool) {
+	if claims.Email == "" || !(claims.EmailVerified || p.TrustEmail) {
+		return "", false
+	}
+	return claims.Email, true
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, metadata *oidcMetadata, raw string, nonce string) (*OIDCClaims, error) {
+	// Never HS256: the client secret is not a signing key we want to trust
+	algs := []string{}
+	for _, alg := range metadata.SigningAlgs {
+		if alg != "none" && !strings.HasPrefix(alg, "HS") {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		algs = []string{jwt.SigningMethodRS256.Alg()}
+	}
+
+	var claims OIDCClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, metadata, kid)
+	},
+		jwt.WithValidMethods(algs),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID {
+		return nil, errors.New("invalid ID token: issued to another client")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid ID token: no subject")
+	}
+	return &claims, nil
+}
+
+func (p *OIDCProvider) userinfo(ctx context.Context, metadata *oidcMetadata, accessToken string, claims *OIDCClaims) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.UserinfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := p.do(req, &info); err != nil {
+		return fmt.Errorf("userinfo request: %w", err)
+	}
+	// The response may only be used for the user of the ID token
+	if info.Subject != claims.Subject {
+		return errors.New("userinfo is about another subject")
+	}
+	claims.Email = info.Email
+	claims.EmailVerified = info.EmailVerified
+	if claims.Name == "" {
+		claims.Name = info.Name
+	}
+	return nil
+}
+
+// discover fetches the provider metadata once. A failed attempt is
+// retried on the next call.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata oidcMetadata
+	if err := p.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery: missing endpoints")
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// key returns the provider key kid, fetching the key set again when kid
+// is unknown since the provider may have rotated its keys. Without a kid
+// the only key is used.
+func (p *OIDCProvider) key(ctx context.Context, metadata *oidcMetadata, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	find := func() (interface{}, bool) {
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key, true
+			}
+		}
+		key, ok := p.keys[kid]
+		return key, ok
+	}
+	if key, ok := find(); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	p.keys = make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.PublicKey(); err == nil {
+			p.keys[jwk.Kid] = key
+		}
+	}
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := find(); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// do sends req and decodes the JSON response into v.
+func (p *OIDCProvider) do(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"fiet/model"
+	"fiet/repository"
+)
+
+// Revoker answers "is this access token revoked?" from memory so the auth
+// middleware never hits the database. Revocations are written through to
+// the repository, and Start reloads it periodically to pick up
+// revocations made by other replicas.
+type Revoker struct {
+	Store repository.RevocationRepository
+
+	mu      sync.RWMutex
+	tokens  map[string]time.Time // jti -> token expiry
+	cutoffs map[string]time.Time // user UUID or sessionSubject -> not before
+}
+
+func NewRevoker(store repository.RevocationRepository) *Revoker {
+	return &Revoker{
+		Store:   store,
+		tokens:  make(map[string]time.Time),
+		cutoffs: make(map[string]time.Time),
+	}
+}
+
+// Load replaces the cache with the contents of the repository.
+func (r *Revoker) Load(ctx context.Context) error {
+	tokens, err := r.Store.ListRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+	cutoffs, err := r.Store.ListCutoffs(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = make(map[string]time.Time, len(tokens))
+	for _, t := range tokens {
+		r.tokens[t.JTI] = t.ExpiresAt
+	}
+	r.cutoffs = make(map[string]time.Time, len(cutoffs))
+	for _, c := range cutoffs {
+		r.cutoffs[c.UserUUID] = c.NotBefore
+	}
+	return nil
+}
+
+// Start loads the cache and keeps it in sync every interval until ctx is
+// done. Entries that can no longer match a valid token are pruned.
+func (r *Revoker) Start(ctx context.Context, interval time.Duration) error {
+	if err := r.Load(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				now := time.Now().UTC()
+				if err := r.Store.Prune(ctx, now, now.Add(-AccessTokenTTL)); err != nil {
+					log.Println("Error pruning revoked tokens:", err)
+				}
+				if err := r.Load(ctx); err != nil {
+					log.Println("Error reloading revoked tokens:", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// RevokeToken revokes a single access token until it expires.
+func (r *Revoker) RevokeToken(ctx context.Context, jti string, userUUID string, expiresAt time.Time) error {
+	err := r.Store.RevokeToken(ctx, model.RevokedToken{
+		JTI:       jti,
+		UserUUID:  userUUID,
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.tokens[jti] = expiresAt
+	r.mu.Unlock()
+	return nil
+}
+
+// RevokeUser revokes every token issued to the user up to now.
+func (r *Revoker) RevokeUser(ctx context.Context, userUUID string) error {
+	// iat has millisecond resolution; truncating keeps tokens issued right
+	// after this call (e.g. for the session that changed the password) valid.
+	return r.setCutoff(ctx, userUUID, time.Now().UTC().Truncate(time.Millisecond))
+}
+
+// RevokeSession revokes every token issued for the login session so far.
+func (r *Revoker) RevokeSession(ctx context.Context, sessionID int) error {
+	// An ended session gets no new tokens, so unlike RevokeUser this rounds
+	// up to also catch tokens issued in the same millisecond.
+	notBefore := time.Now().UTC().Truncate(time.Millisecond).Add(time.Millisecond)
+	return r.setCutoff(ctx, sessionSubject(sessionID), notBefore)
+}
+
+// RevokeServiceAccount revokes every token issued to the service account
+// so far.
+func (r *Revoker) RevokeServiceAccount(ctx context.Context, accountUUID string) error {
+	// The old secret gets no new tokens, so like RevokeSession this rounds
+	// up to also catch tokens issued in the same millisecond.
+	notBefore := time.Now().UTC().Truncate(time.Millisecond).Add(time.Millisecond)
+	return r.setCutoff(ctx, accountUUID, notBefore)
+}
+
+// setCutoff revokes the tokens of subject issued before notBefore.
+func (r *Revoker) setCutoff(ctx context.Context, subject string, notBefore time.Time) error {
+	err := r.Store.SetCutoff(ctx, model.TokenCutoff{UserUUID: subject, NotBefore: notBefore})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cutoffs[subject] = notBefore
+	r.mu.Unlock()
+	return nil
+}
+
+// IsSessionRevoked reports whether a token of the login session issued at
+// issuedAt has been revoked.
+func (r *Revoker) IsSessionRevoked(sessionID int, issuedAt time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notBefore, ok := r.cutoffs[sessionSubject(sessionID)]
+	return ok && issuedAt.Before(notBefore)
+}
+
+// sessionSubject keys the cutoff of a login session, which shares the
+// table of user cutoffs.
+func sessionSubject(sessionID int) string {
+	return "session:" + strconv.Itoa(sessionID)
+}
+
+// IsRevoked reports whether a token with the given jti, subject and issue
+// time has been revoked.
+func (r *Revoker) IsRevoked(jti string, userUUID string, issuedAt time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.tokens[jti]; ok {
+		return true
+	}
+	if notBefore, ok := r.cutoffs[userUUID]; ok && issuedAt.Before(notBefore) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fiet/config"
+	"fiet/model"
+	"fiet/repository"
+)
+
+// Names of the cookies and header used in session mode.
+const (
+	SessionCookie = "session"
+	// CSRFCookie is readable from JS so the frontend can echo it in CSRFHeader
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// sessionTouchInterval limits how often activity is written for a session
+// in busy use, so last_seen_at is up to this much behind.
+const sessionTouchInterval = time.Minute
+
+// ErrInvalidSession is returned for unknown or expired sessions and for
+// sessions of accounts that cannot log in.
+var ErrInvalidSession = errors.New("invalid session")
+
+// SessionManager records every login as a session the user can list and
+// end. With Cookie set (AUTH_MODE=session) browsers authenticate with the
+// session itself, which ends after IdleTimeout without requests and at the
+// latest MaxAge after login; otherwise the session lasts as long as its
+// refresh tokens.
+type SessionManager struct {
+	Sessions    repository.SessionRepository
+	Users       repository.UserRepository
+	Roles       repository.RoleRepository
+	Cookie      bool
+	IdleTimeout time.Duration
+	MaxAge      time.Duration
+
+	mu     sync.Mutex
+	seen   map[int]time.Time // session ID -> last written last_seen_at
+	pruned time.Time
+}
+
+// NewSessionManager reads SESSION_IDLE_TIMEOUT and SESSION_MAX_AGE.
+func NewSessionManager(sessions repository.SessionRepository, users repository.UserRepository, roles repository.RoleRepository) *SessionManager {
+	return &SessionManager{
+		Sessions:    sessions,
+		Users:       users,
+		Roles:       roles,
+		IdleTimeout: config.Duration("SESSION_IDLE_TIMEOUT", 2*time.Hour),
+		MaxAge:      config.Duration("SESSION_MAX_AGE", 7*24*time.Hour),
+		seen:        make(map[int]time.Time),
+	}
+}
+
+// Create starts a session for user, logged in from userAgent and ip, and
+// returns the tokens for the session cookie and the CSRF header. In token
+// mode familyID is the refresh token family of the login.
+func (m *SessionManager) Create(ctx context.Context, user *model.User, userAgent string, ip string, familyID *string) (token string, csrfToken string, session *model.Session, err error) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", nil, err
+	}
+	csrfToken, csrfHash, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	now := time.Now().UTC()
+	session = &model.Session{
+		SessionHash: hash,
+		CSRFHash:    csrfHash,
+		UserID:      user.ID,
+		UserUUID:    user.UUID,
+		DeviceName:  deviceName(userAgent),
+		IPAddress:   ip,
+		FamilyID:    familyID,
+		ExpiresAt:   m.expiry(now, now),
+	}
+	if familyID != nil {
+		// Moved forward with each refresh
+		session.ExpiresAt = now.Add(RefreshTokenTTL)
+	}
+	if err := m.Sessions.Create(ctx, session); err != nil {
+		return "", "", nil, err
+	}
+	return token, csrfToken, session, nil
+}
+
+// Verify looks up the session for token and returns it with the current
+// roles and permissions of its user, which are read on every request like
+// for API keys.
+func (m *SessionManager) Verify(ctx context.Context, token string) (*model.Session, []string, []string, error) {
+	session, err := m.Sessions.GetByHash(ctx, HashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, nil, ErrInvalidSession
+		}
+		return nil, nil, nil, err
+	}
+
+	user, err := m.Users.GetByUUID(ctx, session.UserUUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, nil, ErrInvalidSession
+		}
+		return nil, nil, nil, err
+	}
+	if user.DisabledAt != nil || user.PasswordResetRequired {
+		return nil, nil, nil, ErrInvalidSession
+	}
+
+	roles, err := m.Roles.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	permissions, err := m.Roles.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		expiresAt := m.expiry(session.CreatedAt, now)
+		if err := m.Sessions.Touch(ctx, session.ID, now, expiresAt); err != nil {
+			log.Println("Error recording session activity:", err)
+		} else {
+			session.LastSeenAt, session.ExpiresAt = now, expiresAt
+		}
+	}
+	return session, roles, permissions, nil
+}
+
+// Seen records that an access token of the session was used. Access
+// tokens are verified without the database, so this writes at most once
+// per sessionTouchInterval for each session on each instance.
+func (m *SessionManager) Seen(ctx context.Context, sessionID int) {
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	if last, ok := m.seen[sessionID]; ok && now.Sub(last) < sessionTouchInterval {
+		m.mu.Unlock()
+		return
+	}
+	if m.seen == nil {
+		m.seen = make(map[int]time.Time)
+	}
+	m.seen[sessionID] = now
+	if now.Sub(m.pruned) >= sessionTouchInterval {
+		for id, last := range m.seen {
+			if now.Sub(last) >= sessionTouchInterval {
+				delete(m.seen, id)
+			}
+		}
+		m.pruned = now
+	}
+	m.mu.Unlock()
+
+	err := m.Sessions.SetLastSeen(ctx, sessionID, now)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error recording session activity:", err)
+	}
+}
+
+// CheckCSRF reports whether csrfToken is the CSRF token of session.
+func (m *SessionManager) CheckCSRF(session *model.Session, csrfToken string) bool {
+	if csrfToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashToken(csrfToken)), []byte(session.CSRFHash)) == 1
+}
+
+// expiry is when a session created at createdAt and last used at now ends.
+func (m *SessionManager) expiry(createdAt time.Time, now time.Time) time.Time {
+	expiresAt := now.Add(m.IdleTimeout)
+	if limit := createdAt.Add(m.MaxAge); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
+
+// deviceName describes the browser and OS of a User-Agent, such as
+// "Firefox on Windows", falling back to the User-Agent itself.
+func deviceName(userAgent string) string {
+	var browser, os string
+	for _, b := range []struct{ token, name string }{
+		// Order matters: Edge and Opera also claim Chrome, Chrome claims Safari
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"CrOS", "ChromeOS"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	case userAgent == "":
+		return "Unknown device"
+	case len(userAgent) > 200:
+		return userAgent[:200]
+	}
+	return userAgent
+}
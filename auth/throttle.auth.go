@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fiet/config"
+	"fiet/repository"
+)
+
+// LockoutPolicy decides how long repeated login failures lock a subject
+// out.
+type LockoutPolicy struct {
+	// MaxFailures is how many failures lock the subject for the first
+	// time; 0 turns the lockout off.
+	MaxFailures int
+	// BaseDelay is the first lockout. Every further failure doubles it, up
+	// to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Delay is the lockout after the given number of consecutive failures, or
+// 0 if there is none yet.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.MaxFailures; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// LoginThrottle slows down password guessing. Failures are counted per
+// account, which stops guessing one password from many IPs, and per
+// client IP, which stops trying a few passwords against many accounts.
+type LoginThrottle struct {
+	Store   repository.LoginAttemptRepository
+	Account LockoutPolicy
+	IP      LockoutPolicy
+	// Window is how long a failure counts towards a lockout
+	Window time.Duration
+}
+
+// NewLoginThrottle reads the policies from LOGIN_* environment variables.
+func NewLoginThrottle(store repository.LoginAttemptRepository) *LoginThrottle {
+	base := config.Duration("LOGIN_LOCKOUT_BASE", time.Minute)
+	maxDelay := config.Duration("LOGIN_LOCKOUT_MAX", time.Hour)
+	return &LoginThrottle{
+		Store:   store,
+		Account: LockoutPolicy{MaxFailures: config.Int("LOGIN_MAX_FAILURES", 5), BaseDelay: base, MaxDelay: maxDelay},
+		IP:      LockoutPolicy{MaxFailures: config.Int("LOGIN_MAX_FAILURES_PER_IP", 20), BaseDelay: base, MaxDelay: maxDelay},
+		Window:  config.Duration("LOGIN_FAILURE_WINDOW", 24*time.Hour),
+	}
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how much longer logins to the account from ip are locked,
+// or 0 if they are allowed.
+func (t *LoginThrottle) Check(ctx context.Context, email string, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, subject := range []string{repository.AccountAttemptSubject(email), ipSubject(ip)} {
+		attempt, err := t.Store.Get(ctx, subject)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if attempt.LockedUntil != nil {
+			wait = max(wait, time.Until(*attempt.LockedUntil))
+		}
+	}
+	return wait, nil
+}
+
+// Fail counts a failed login to the account from ip and returns the
+// lockout it caused, or 0.
+func (t *LoginThrottle) Fail(ctx context.Context, email string, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, s := range []struct {
+		subject string
+		policy  LockoutPolicy
+	}{
+		{repository.AccountAttemptSubject(email), t.Account},
+		{ipSubject(ip), t.IP},
+	} {
+		attempt, err := t.Store.RecordFailure(ctx, s.subject, time.Now().Add(-t.Window))
+		if err != nil {
+			return 0, err
+		}
+		delay := s.policy.Delay(attempt.Failures)
+		if delay == 0 {
+			continue
+		}
+		if err := t.Store.Lock(ctx, s.subject, time.Now().Add(delay)); err != nil {
+			return 0, err
+		}
+		wait = max(wait, delay)
+	}
+	return wait, nil
+}
+
+// Reset forgets the failures of the account and lifts its lockout, after a
+// successful login or by an admin. Failures of IPs are kept, so that an
+// attacker cannot clear them by logging in to an account of their own.
+func (t *LoginThrottle) Reset(ctx context.Context, email string) error {
+	return t.Store.Reset(ctx, repository.AccountAttemptSubject(email))
+}
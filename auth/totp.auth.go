@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpPeriod = 30 // seconds per time step
+	// totpSkew is how many steps a code may be behind or ahead, to allow
+	// for clock drift and slow typing.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1, // the only one every authenticator app supports
+}
+
+// TOTPKey is a newly generated TOTP secret for an account.
+type TOTPKey struct {
+	Secret string // base32
+	URI    string // otpauth:// URI for authenticator apps
+	QRCode string // the URI as a PNG data URI
+}
+
+// NewTOTPKey generates a TOTP secret for the account with the given email.
+func NewTOTPKey(email string) (*TOTPKey, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TOTPIssuer,
+		AccountName: email,
+		Period:      totpPeriod,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return &TOTPKey{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// ValidateTOTP checks code against secret at time now and returns the time
+// step it belongs to. Callers must reject steps that were already used.
+func ValidateTOTP(secret string, code string, now time.Time) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		t := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns a fresh set of one-time recovery codes and the
+// hashes to store in their place.
+func NewRecoveryCodes() (codes []string, hashes []string, err error) {
+	for range recoveryCodeCount {
+		b := make([]byte, 5) // 40 bits
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b)) // 8 characters
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user, ignoring
+// case, spaces and dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
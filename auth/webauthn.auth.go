@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"fiet/model"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// NewWebAuthn configures the relying party for passkeys. WEBAUTHN_RP_ID
+// defaults to the host of appURL and WEBAUTHN_RP_ORIGINS, a comma
+// separated list, to appURL itself.
+func NewWebAuthn(appURL string) (*webauthn.WebAuthn, error) {
+	origins := []string{appURL}
+	if list := os.Getenv("WEBAUTHN_RP_ORIGINS"); list != "" {
+		origins = nil
+		for _, origin := range strings.Split(list, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				origins = append(origins, strings.TrimSuffix(origin, "/"))
+			}
+		}
+	}
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		u, err := url.Parse(origins[0])
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("cannot derive WEBAUTHN_RP_ID from %q", origins[0])
+		}
+		rpID = u.Hostname()
+	}
+
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = TOTPIssuer
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+	})
+}
+
+// WebAuthnUser presents a user and their registered credentials to the
+// webauthn library. The user handle is the UUID, which unlike the email
+// never changes.
+type WebAuthnUser struct {
+	User        *model.User
+	Credentials []model.WebAuthnCredential
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte { return []byte(u.User.UUID) }
+
+func (u *WebAuthnUser) WebAuthnName() string { return u.User.Email }
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	if u.User.Name != nil && *u.User.Name != "" {
+		return *u.User.Name
+	}
+	return u.User.Email
+}
+
+func (u *WebAuthnUser) WebAuthnIcon() string { return "" }
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for _, c := range u.Credentials {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			continue
+		}
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(c.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: uint32(c.SignCount),
+			},
+		})
+	}
+	return credentials
+}
+
+// CredentialExclusions lists the user's credentials so an authenticator
+// does not register a second time.
+func (u *WebAuthnUser) CredentialExclusions() []protocol.CredentialDescriptor {
+	credentials := u.WebAuthnCredentials()
+	exclusions := make([]protocol.CredentialDescriptor, len(credentials))
+	for i, c := range credentials {
+		exclusions[i] = c.Descriptor()
+	}
+	return exclusions
+}
+
+// NewWebAuthnCredential converts a freshly registered credential for
+// storage.
+func NewWebAuthnCredential(userID int, name string, c *webauthn.Credential) *model.WebAuthnCredential {
+	transports := make([]string, len(c.Transport))
+	for i, t := range c.Transport {
+		transports[i] = string(t)
+	}
+	return &model.WebAuthnCredential{
+		UserID:          userID,
+		CredentialID:    WebAuthnCredentialID(c.ID),
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		AAGUID:          c.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, ","),
+		SignCount:       int64(c.Authenticator.SignCount),
+		BackupEligible:  c.Flags.BackupEligible,
+		BackupState:     c.Flags.BackupState,
+		Name:            name,
+	}
+}
+
+// WebAuthnCredentialID is how credential IDs are stored: base64url
+// without padding, the same as in the JSON of a ceremony.
+func WebAuthnCredentialID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
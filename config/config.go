@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,3 +18,48 @@ func LoadConfig() {
 	}
 	fmt.Println("JWT secret", os.Getenv("JWT_SECRET"))
 }
+
+// Duration reads a time.Duration such as "15m" from the environment,
+// falling back to def when it is unset or invalid.
+func Duration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// Int reads an integer from the environment, falling back to def when it
+// is unset or invalid.
+func Int(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// Bool reads a boolean such as "true" or "0" from the environment, falling
+// back to def when it is unset or invalid.
+func Bool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %t", key, value, def)
+		return def
+	}
+	return b
+}
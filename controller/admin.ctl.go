@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"errors"
+	"fiet/model"
+	"fiet/repository"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get any user
+// @Summary      Get User (admin)
+// @Description  Retrieve a user by UUID. Requires users:read.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {object}  model.PublicUser
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid} [get]
+// @Security 	 BearerAuth
+func (db *DBController) AdminGetUser(c *gin.Context) {
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, user.Public())
+}
+
+// Update any user
+// @Summary      Update User (admin)
+// @Description  Update the name, age or email of a user. A new email address has to be verified again. Requires users:update.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        uuid  path      string            true  "User UUID"
+// @Param        user  body      model.PublicUser  true  "User details to update"
+// @Success      200  {string}  "User updated successfully"
+// @Failure      400  {string}  "Invalid request data"
+// @Failure      403  {string}  "Missing permission, or the user is an admin or has permissions you lack"
+// @Failure      404  {string}  "User not found"
+// @Failure      409  {string}  "Email already in use"
+// @Failure      500  {string}  "Failed to update user"
+// @Router       /admin/users/{uuid} [patch]
+// @Security 	 BearerAuth
+func (db *DBController) AdminUpdateUser(c *gin.Context) {
+	if _, ok := db.adminTarget(c); !ok {
+		return
+	}
+	db.updateUser(c, c.Param("uuid"))
+}
+
+// Delete any user
+// @Summary      Delete User (admin)
+// @Description  Delete a user and revoke all of their sessions. The user can be restored until the grace period has passed. Requires users:delete.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "User deleted successfully"
+// @Failure      403  {string}  "Missing permission, or the user is an admin or has permissions you lack"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Failed to delete user"
+// @Router       /admin/users/{uuid} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) AdminDeleteUser(c *gin.Context) {
+	user, ok := db.adminTarget(c)
+	if !ok {
+		return
+	}
+	db.deleteUser(c, user)
+}
+
+// Restore a deleted user
+// @Summary      Restore User (admin)
+// @Description  Undo the deletion of a user within the grace period. Requires users:delete.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "User restored"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "No restorable user with this UUID"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/restore [post]
+// @Security 	 BearerAuth
+func (db *DBController) AdminRestoreUser(c *gin.Context) {
+	err := db.Users.Restore(c.Request.Context(), c.Param("uuid"), time.Now().UTC().Add(-db.DeletionGracePeriod))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No restorable user with this UUID"})
+			return
+		}
+		log.Println("Restore error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User restored"})
+}
+
+// Disable a user
+// @Summary      Disable User
+// @Description  Block a user from logging in and revoke all of their sessions. Requires users:update.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "User disabled"
+// @Failure      400  {string}  "Cannot disable your own account"
+// @Failure      403  {string}  "Missing permission, or the user is an admin or has permissions you lack"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/disable [post]
+// @Security 	 BearerAuth
+func (db *DBController) DisableUser(c *gin.Context) {
+	user, ok := db.adminTarget(c)
+	if !ok {
+		return
+	}
+	// An admin locking themselves out leaves nobody to undo it
+	if user.UUID == c.GetString("user_uuid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot disable your own account"})
+		return
+	}
+
+	err := db.Users.SetDisabled(c.Request.Context(), user.UUID, true)
+	if err == nil {
+		err = db.revokeAllSessions(c, user)
+	}
+	if err != nil {
+		userStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled"})
+}
+
+// Enable a user
+// @Summary      Enable User
+// @Description  Allow a disabled user to log in again. Requires users:update.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "User enabled"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/enable [post]
+// @Security 	 BearerAuth
+func (db *DBController) EnableUser(c *gin.Context) {
+	if err := db.Users.SetDisabled(c.Request.Context(), c.Param("uuid"), false); err != nil {
+		userStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled"})
+}
+
+// Force a password reset
+// @Summary      Force Password Reset
+// @Description  Revoke all sessions of a user and refuse logins until the password is changed. Requires users:update.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "Password reset required"
+// @Failure      403  {string}  "Missing permission, or the user is an admin or has permissions you lack"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/force-password-reset [post]
+// @Security 	 BearerAuth
+func (db *DBController) ForcePasswordReset(c *gin.Context) {
+	user, ok := db.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	err := db.Users.SetPasswordResetRequired(c.Request.Context(), user.UUID, true)
+	if err == nil {
+		err = db.revokeAllSessions(c, user)
+	}
+	if err != nil {
+		userStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset required"})
+}
+
+// Reset the second factor of a user
+// @Summary      Reset MFA
+// @Description  Remove the TOTP credential, recovery codes and passkeys of a user who lost them, and revoke all of their sessions. Requires users:update.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "MFA reset"
+// @Failure      403  {string}  "Missing permission, or the user is an admin or has permissions you lack"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/mfa/reset [post]
+// @Security 	 BearerAuth
+func (db *DBController) AdminResetMFA(c *gin.Context) {
+	user, ok := db.adminTarget(c)
+	if !ok {
+		return
+	}
+	err := db.MFA.DeleteTOTP(c.Request.Context(), user.ID)
+	if err == nil {
+		err = db.WebAuthn.DeleteCredentials(c.Request.Context(), user.ID)
+	}
+	// Sessions that passed the old second factor may belong to whoever
+	// took it
+	if err == nil {
+		err = db.revokeAllSessions(c, user)
+	}
+	if err != nil {
+		userStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset"})
+}
+
+// Lift a login lockout
+// @Summary      Unlock User
+// @Description  Clear the failed login attempts of a user and lift the lockout of the account. Lockouts of client IPs expire on their own. Requires users:update.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {string}  "User unlocked"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/unlock [post]
+// @Security 	 BearerAuth
+func (db *DBController) UnlockUser(c *gin.Context) {
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return
+	}
+	if err := db.Throttle.Reset(c.Request.Context(), user.Email); err != nil {
+		userStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// adminTarget loads the user named by the :uuid path parameter for an
+// admin action. Admins and users with permissions the caller does not hold
+// are refused, so that no admin can take over or lock out an account
+// stronger than their own. It writes the error response if that fails.
+func (db *DBController) adminTarget(c *gin.Context) (*model.User, bool) {
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return nil, false
+	}
+	ctx := c.Request.Context()
+	roles, err := db.Roles.GetUserRoles(ctx, user.ID)
+	var permissions []string
+	if err == nil {
+		permissions, err = db.Roles.GetUserPermissions(ctx, user.ID)
+	}
+	if err != nil {
+		log.Println("Error fetching user permissions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+
+	held := c.GetStringSlice("permissions")
+	if slices.Contains(roles, model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage an admin account"})
+		return nil, false
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(held, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User has permissions you do not hold", "details": permission})
+			return nil, false
+		}
+	}
+	return user, true
+}
+
+func userStatusError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	log.Println("Error updating user status:", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+}
@@ -0,0 +1,87 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// grant gives the account a role.
+func (s *testServer) grant(user *model.User, role string) {
+	s.t.Helper()
+	if err := s.store.Roles.AssignRole(context.Background(), user.ID, role); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestAdminCannotManageStrongerAccounts(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.signup("ada@example.com")
+	s.grant(ada, model.RoleAdmin)
+	bob := s.signup("bob@example.com")
+	s.grant(bob, model.RoleAdmin)
+	carol := s.signup("carol@example.com")
+	s.grant(carol, model.RoleStaff)
+	dave := s.signup("dave@example.com")
+	token := s.login("ada@example.com")
+
+	actions := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"update", http.MethodPatch, "", gin.H{"email": "eve@example.com"}},
+		{"disable", http.MethodPost, "/disable", nil},
+		{"force password reset", http.MethodPost, "/force-password-reset", nil},
+		{"reset MFA", http.MethodPost, "/mfa/reset", nil},
+		{"delete", http.MethodDelete, "", nil},
+	}
+	for _, action := range actions {
+		t.Run(action.name, func(t *testing.T) {
+			expect(t, s.call(action.method, "/api/v1/admin/users/"+bob.UUID+action.path, token, action.body), http.StatusForbidden)
+
+			// An API key of the admin holds only some of the permissions
+			// of staff
+			body := expect(t, s.call(http.MethodPost, "/api/v1/user/api-keys", token, gin.H{
+				"name": action.name, "scopes": []string{"users:update", "users:delete"},
+			}), http.StatusCreated)
+			expect(t, s.call(action.method, "/api/v1/admin/users/"+carol.UUID+action.path, "", action.body,
+				"X-API-Key", body["key"].(string)), http.StatusForbidden)
+		})
+	}
+
+	other, err := s.store.Users.GetByUUID(context.Background(), bob.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Email != "bob@example.com" || other.DisabledAt != nil || other.PasswordResetRequired || other.DeletedAt != nil {
+		t.Errorf("another admin was changed: %+v", other)
+	}
+	s.login("bob@example.com")
+
+	// Users whose permissions the admin holds are fine
+	expect(t, s.call(http.MethodPost, "/api/v1/admin/users/"+carol.UUID+"/force-password-reset", token, nil), http.StatusOK)
+	expect(t, s.call(http.MethodPatch, "/api/v1/admin/users/"+dave.UUID, token, gin.H{"name": "Dave"}), http.StatusOK)
+}
+
+func TestAdminResetMFA(t *testing.T) {
+	s := newTestServer(t)
+	bob := s.signup("bob@example.com")
+	bobToken := s.login("bob@example.com")
+	s.registerPasskey(bobToken)
+	ada := s.signup("ada@example.com")
+	s.grant(ada, model.RoleAdmin)
+	token := s.login("ada@example.com")
+
+	expect(t, s.call(http.MethodPost, "/api/v1/admin/users/"+bob.UUID+"/mfa/reset", token, nil), http.StatusOK)
+	if credentials, _ := s.store.WebAuthn.ListCredentials(context.Background(), bob.ID); len(credentials) != 0 {
+		t.Errorf("passkeys left after the reset: %v", credentials)
+	}
+	// Whoever used the lost factor is signed out
+	expect(t, s.call(http.MethodGet, "/api/v1/user", bobToken, nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAPIKeys is how many keys a user may have at once.
+const maxAPIKeys = 25
+
+// defaultAPIKeyLifetime applies when a key is created without
+// expires_in_days.
+const defaultAPIKeyLifetime = 90 * 24 * time.Hour
+
+// Create an API key
+// @Summary      Create API Key
+// @Description  Create a named key for scripts, sent as `Authorization: ApiKey <key>` or `X-API-Key: <key>`. The key acts as the caller with at most the permissions in scopes, which must be permissions the caller holds, and cannot manage the account itself.
+// @Description  The key is only returned in this response.
+// @Tags         apikey
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.APIKeyRequest  true  "Name, scopes and lifetime"
+// @Success      201  {object}  model.APIKeyResponse
+// @Failure      400  {string}  "Invalid input or scope"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Called with an API key"
+// @Failure      409  {string}  "Too many API keys"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/api-keys [post]
+// @Security 	 BearerAuth
+func (db *DBController) CreateAPIKey(c *gin.Context) {
+	var req model.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	held := c.GetStringSlice("permissions")
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(held, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Scopes must be permissions you hold", "details": scope})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	lifetime := defaultAPIKeyLifetime
+	if req.ExpiresInDays > 0 {
+		lifetime = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	keys, err := db.APIKeys.List(ctx, user.ID)
+	if err != nil {
+		log.Println("Error listing API keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	if len(keys) >= maxAPIKeys {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many API keys, revoke one first"})
+		return
+	}
+
+	raw, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	key := &model.APIKey{
+		UserID:    user.ID,
+		UserUUID:  user.UUID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().UTC().Add(lifetime),
+	}
+	if err := db.APIKeys.Create(ctx, key); err != nil {
+		log.Println("Error creating API key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	response := key.Public()
+	response.Key = raw
+	c.JSON(http.StatusCreated, response)
+}
+
+// List API keys
+// @Summary      List API Keys
+// @Description  The caller's API keys, without the keys themselves.
+// @Tags         apikey
+// @Produce      json
+// @Success      200  {array}   model.APIKeyResponse
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Called with an API key"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/api-keys [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListAPIKeys(c *gin.Context) {
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	keys, err := db.APIKeys.List(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error listing API keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+	response := make([]model.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, key.Public())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke an API key
+// @Summary      Revoke API Key
+// @Description  Delete one of the caller's API keys; requests with it fail right away.
+// @Tags         apikey
+// @Produce      json
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {string}  "API key revoked"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Called with an API key"
+// @Failure      404  {string}  "API key not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/api-keys/{id} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	if err := db.APIKeys.Delete(c.Request.Context(), user.ID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		log.Println("Error revoking API key:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
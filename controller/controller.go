@@ -1,7 +1,40 @@
 package controller
 
-import "github.com/jmoiron/sqlx"
+import (
+	"fiet/auth"
+	"fiet/mail"
+	"fiet/password"
+	"fiet/repository"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
 
 type DBController struct {
-	Database *sqlx.DB
+	*repository.Store
+	Revoker *auth.Revoker
+	// Throttle locks out repeated login failures
+	Throttle *auth.LoginThrottle
+	// DeletionGracePeriod is how long a deleted account can be restored
+	DeletionGracePeriod time.Duration
+	// PasswordPolicy applies to every new password
+	PasswordPolicy password.Policy
+	// PasswordHasher hashes new passwords and upgrades outdated hashes
+	PasswordHasher password.Hasher
+	Mailer         mail.Sender
+	// AppURL is the address of the web frontend, used for links in emails
+	AppURL string
+	// RequireVerifiedEmail refuses logins until the email is verified
+	RequireVerifiedEmail bool
+	// RelyingParty runs the WebAuthn ceremonies for passkeys
+	RelyingParty *webauthn.WebAuthn
+	// OIDCProviders are the identity providers users can log in with, by name
+	OIDCProviders map[string]*auth.OIDCProvider
+	// OAuthServer lets other apps sign users in; nil when OAUTH_ISSUER is unset
+	OAuthServer *auth.OAuthServer
+	// SessionManager records logins as sessions, which replace tokens for
+	// user logins with AUTH_MODE=session
+	SessionManager *auth.SessionManager
+	// Cookies sets the attributes of the session and refresh token cookies
+	Cookies auth.CookieConfig
 }
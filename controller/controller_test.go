@@ -0,0 +1,192 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"fiet/auth"
+	"fiet/controller"
+	"fiet/mail"
+	"fiet/model"
+	"fiet/password"
+	"fiet/repository"
+	"fiet/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testPassword = "horse-battery-staple"
+	testAppURL   = "http://app.test"
+)
+
+// testServer serves the real routes from controllers wired to an
+// in-memory store.
+type testServer struct {
+	t      *testing.T
+	store  *repository.Store
+	ctls   *controller.DBController
+	router *gin.Engine
+	mail   chan mail.Message
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+	gin.SetMode(gin.TestMode)
+
+	relyingParty, err := auth.NewWebAuthn(testAppURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := repository.NewMemoryStore()
+	s := &testServer{t: t, store: store, mail: make(chan mail.Message, 100)}
+	s.ctls = &controller.DBController{
+		Store:               store,
+		Revoker:             auth.NewRevoker(store.Revocations),
+		Throttle:            auth.NewLoginThrottle(store.LoginAttempts),
+		DeletionGracePeriod: time.Hour,
+		PasswordPolicy:      password.LoadPolicy(),
+		PasswordHasher:      password.LoadHasher(),
+		Mailer:              mailerFunc(func(msg mail.Message) { s.mail <- msg }),
+		AppURL:              testAppURL,
+		RelyingParty:        relyingParty,
+		SessionManager:      auth.NewSessionManager(store.Sessions, store.Users, store.Roles),
+	}
+	s.routes()
+	return s
+}
+
+// routes (re)registers the routes, e.g. after a test changed ctls.
+func (s *testServer) routes() {
+	s.router = gin.New()
+	router.SetUserRoutes(s.router.Group("/api/v1"), s.ctls)
+	router.SetWellKnownRoutes(s.router, s.ctls)
+}
+
+// mailerFunc delivers mail to a function.
+type mailerFunc func(msg mail.Message)
+
+func (f mailerFunc) Send(ctx context.Context, msg mail.Message) error {
+	f(msg)
+	return nil
+}
+
+// call sends a request with body encoded as JSON unless it is nil or a
+// string, and token as bearer token unless it is empty.
+func (s *testServer) call(method, path, token string, body any, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless w has status code and returns its JSON
+// object body.
+func expect(t *testing.T, w *httptest.ResponseRecorder, code int) map[string]any {
+	t.Helper()
+	if w.Code != code {
+		t.Fatalf("got status %d, want %d: %s", w.Code, code, w.Body.String())
+	}
+	var body map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return body
+}
+
+// signup creates an account with testPassword.
+func (s *testServer) signup(email string) *model.User {
+	s.t.Helper()
+	expect(s.t, s.call(http.MethodPost, "/api/v1/signup", "", gin.H{"email": email, "password": testPassword}), http.StatusCreated)
+	user, err := s.store.Users.GetByEmail(context.Background(), email)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return user
+}
+
+// login returns an access token for the account.
+func (s *testServer) login(email string) string {
+	s.t.Helper()
+	body := expect(s.t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": email, "password": testPassword}), http.StatusOK)
+	return body["token"].(string)
+}
+
+// waitMail returns the next mail to to whose subject contains subject,
+// skipping others. Mail is sent in the background.
+func (s *testServer) waitMail(to, subject string) mail.Message {
+	s.t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-s.mail:
+			if msg.To == to && strings.Contains(msg.Subject, subject) {
+				return msg
+			}
+		case <-timeout:
+			s.t.Fatalf("no mail %q to %s", subject, to)
+		}
+	}
+}
+
+// noMail fails the test if mail to to with subject arrives soon.
+func (s *testServer) noMail(to, subject string) {
+	s.t.Helper()
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg := <-s.mail:
+			if msg.To == to && strings.Contains(msg.Subject, subject) {
+				s.t.Fatalf("unexpected mail %q to %s", subject, to)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+var linkToken = regexp.MustCompile(`[?&]token=([^&\s]+)`)
+
+// mailToken returns the token of the link in msg.
+func (s *testServer) mailToken(msg mail.Message) string {
+	s.t.Helper()
+	match := linkToken.FindStringSubmatch(msg.Body)
+	if match == nil {
+		s.t.Fatalf("no link in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
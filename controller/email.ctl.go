@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fiet/auth"
+	"fiet/mail"
+	"fiet/model"
+	"fiet/repository"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Verify an email address
+// @Summary      Verify Email
+// @Description  Confirm the email address of an account with the token from a verification email.
+// @Tags         user
+// @Produce      json
+// @Param        token  query     string  true  "Verification token"
+// @Success      200  {string}  "Email verified"
+// @Failure      400  {string}  "Invalid or expired token"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /verify-email [get]
+func (db *DBController) VerifyEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	token, err := db.UserTokens.GetValid(ctx, model.TokenPurposeEmailVerification, auth.HashToken(c.Query("token")))
+	if err == nil {
+		err = db.UserTokens.Use(ctx, token.ID)
+	}
+	if err == nil {
+		err = db.Users.MarkEmailVerified(ctx, token.UserUUID)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		log.Println("Error verifying email:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// Resend the verification email
+// @Summary      Resend Verification Email
+// @Description  Email a new verification link to an unverified account. The response is the same whether or not the email is registered.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.ResendVerificationRequest  true  "Account email"
+// @Success      202  {string}  "If the email is registered and unverified, a verification link has been sent"
+// @Failure      400  {string}  "Invalid input"
+// @Router       /verify-email/resend [post]
+func (db *DBController) ResendVerification(c *gin.Context) {
+	var req model.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	db.mailAccount(c.Request.Context(), req.Email, func(ctx context.Context, user *model.User) error {
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		return db.sendEmailVerification(ctx, user)
+	})
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered and unverified, a verification link has been sent"})
+}
+
+// sendEmailVerification replaces any pending verification token of user
+// with a new one and mails the link to user.Email in the background.
+func (db *DBController) sendEmailVerification(ctx context.Context, user *model.User) error {
+	token, err := db.newUserToken(ctx, user, model.TokenPurposeEmailVerification, auth.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := db.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	db.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Please confirm that this is your email address by opening this link within %s:\n\n%s\n\n"+
+			"If you did not sign up, ignore this email.\n",
+			auth.EmailVerificationTTL, link),
+	})
+	return nil
+}
+
+// mailAccount has send mail a link to the active account with email, if
+// there is one. The handlers that call it answer 202 either way, so errors
+// are only logged; the response must never tell registered addresses
+// apart.
+func (db *DBController) mailAccount(ctx context.Context, email string, send func(ctx context.Context, user *model.User) error) {
+	user, err := db.Users.GetByEmail(ctx, email)
+	switch {
+	case err == nil && user.DisabledAt == nil:
+		if err := send(ctx, user); err != nil {
+			log.Println("Error creating emailed token:", err)
+		}
+	case err != nil && !errors.Is(err, repository.ErrNotFound):
+		log.Println("Error fetching user by email:", err)
+	}
+}
+
+// verifyMailedAddress marks the email of user verified once they redeemed
+// token, which was mailed to them: opening the link proves the address it
+// was sent to. That is no proof for a different address the account has
+// moved to since.
+func (db *DBController) verifyMailedAddress(ctx context.Context, user *model.User, token *model.UserToken) {
+	if user.EmailVerifiedAt != nil || token.Email != user.Email {
+		return
+	}
+	if err := db.Users.MarkEmailVerified(ctx, user.UUID); err != nil {
+		log.Println("Error marking email verified:", err)
+		return
+	}
+	now := time.Now().UTC()
+	user.EmailVerifiedAt = &now
+}
+
+// newUserToken revokes the pending tokens of user for purpose and returns
+// a new one for user.Email valid for ttl.
+func (db *DBController) newUserToken(ctx context.Context, user *model.User, purpose string, ttl time.Duration) (string, error) {
+	if err := db.UserTokens.RevokeAll(ctx, user.ID, purpose); err != nil {
+		return "", err
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = db.UserTokens.Create(ctx, &model.UserToken{
+		UserID:    user.ID,
+		UserUUID:  user.UUID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendMail delivers msg in the background, so that response times do not
+// depend on the mail server (or reveal whether a message was sent).
+func (db *DBController) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := db.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// validEmail reports whether s is a bare email address, without a display
+// name or angle brackets.
+func validEmail(s string) bool {
+	addr, err := netmail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
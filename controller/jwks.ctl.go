@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"fiet/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS serves the public keys that verify our access tokens at
+// /.well-known/jwks.json (outside /api/v1, so not in the swagger docs).
+func JWKS(c *gin.Context) {
+	// Verifiers may cache the keys for a while; rotation keeps the old
+	// key published for at least one access token lifetime.
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": auth.Keys().JWKS()})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"fiet/auth"
+	"fiet/mail"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request a sign-in link
+// @Summary      Request Magic Link
+// @Description  Email a single-use link that logs in without a password. The response is the same whether or not the email is registered.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.MagicLinkRequest  true  "Account email"
+// @Success      202  {string}  "If the email is registered, a sign-in link has been sent"
+// @Failure      400  {string}  "Invalid input"
+// @Router       /login/magic-link [post]
+func (db *DBController) RequestMagicLink(c *gin.Context) {
+	var req model.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	db.mailAccount(c.Request.Context(), req.Email, db.sendMagicLink)
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a sign-in link has been sent"})
+}
+
+// sendMagicLink replaces any pending sign-in link of user with a new one
+// and mails it in the background.
+func (db *DBController) sendMagicLink(ctx context.Context, user *model.User) error {
+	token, err := db.newUserToken(ctx, user, model.TokenPurposeMagicLink, auth.MagicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	// The frontend page exchanges the token, so mail scanners that follow
+	// links do not use it up
+	link := db.AppURL + "/login/magic-link?token=" + url.QueryEscape(token)
+	db.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Someone asked to log in to your account without a password.\n\n"+
+			"Open this link within %s to log in; it works once:\n\n%s\n\n"+
+			"If it was not you, ignore this email.\n",
+			auth.MagicLinkTTL, link),
+	})
+	return nil
+}
+
+// Log in with a sign-in link
+// @Summary      Verify Magic Link
+// @Description  Exchange the token from a sign-in email for the same response as /login: tokens, the session cookie in session mode, or an MFA token when the account has a second factor.
+// @Description  The token works once and proves the email address it was sent to.
+// @Tags         user
+// @Produce      json
+// @Param        token  query     string  true  "Token from the sign-in link"
+// @Success      200  {object}  model.TokenResponse "Successful login"
+// @Success      202  {object}  model.MFAChallengeResponse "Second factor required"
+// @Failure      401  {string}  "Invalid or expired link"
+// @Failure      403  {string}  "Account is disabled or requires a password reset"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/magic-link/verify [get]
+func (db *DBController) VerifyMagicLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	token, err := db.UserTokens.GetValid(ctx, model.TokenPurposeMagicLink, auth.HashToken(c.Query("token")))
+	if err == nil {
+		err = db.UserTokens.Use(ctx, token.ID)
+	}
+	var user *model.User
+	if err == nil {
+		user, err = db.Users.GetByUUID(ctx, token.UserUUID)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		log.Println("Error verifying magic link:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+
+	db.verifyMailedAddress(ctx, user, token)
+	if db.accountBlocked(c, user) {
+		return
+	}
+	db.completeLogin(c, user)
+}
@@ -0,0 +1,89 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifyMagicLink logs in with the token of a sign-in link.
+func (s *testServer) verifyMagicLink(token string) *httptest.ResponseRecorder {
+	return s.call(http.MethodGet, "/api/v1/login/magic-link/verify?token="+url.QueryEscape(token), "", nil)
+}
+
+// requestMagicLink asks for a sign-in link to email and returns its token.
+func (s *testServer) requestMagicLink(email string) string {
+	s.t.Helper()
+	expect(s.t, s.call(http.MethodPost, "/api/v1/login/magic-link", "", gin.H{"email": email}), http.StatusAccepted)
+	return s.mailToken(s.waitMail(email, "Your sign-in link"))
+}
+
+func TestMagicLink(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+
+	token := s.requestMagicLink("ada@example.com")
+	body := expect(t, s.verifyMagicLink(token), http.StatusOK)
+	me := expect(t, s.call(http.MethodGet, "/api/v1/user", body["token"].(string), nil), http.StatusOK)
+	if me["uuid"] != user.UUID || me["email_verified_at"] == nil {
+		t.Errorf("logged in as %v, want %s with a verified email", me, user.UUID)
+	}
+	expect(t, s.verifyMagicLink(token), http.StatusUnauthorized)
+	expect(t, s.verifyMagicLink("not-a-token"), http.StatusUnauthorized)
+
+	// Unknown addresses get the same answer and no mail
+	expect(t, s.call(http.MethodPost, "/api/v1/login/magic-link", "", gin.H{"email": "bob@example.com"}), http.StatusAccepted)
+	s.noMail("bob@example.com", "Your sign-in link")
+}
+
+func TestMagicLinkAfterEmailChange(t *testing.T) {
+	s, provider := oidcServer(t)
+	user := s.signup("eve@example.com")
+	token := s.login("eve@example.com")
+	ctx := context.Background()
+
+	// A link requested for an address the attacker controls, then the
+	// account moved to the victim's address
+	link := s.requestMagicLink("eve@example.com")
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", token, gin.H{"email": "ada@campus.example"}), http.StatusOK)
+	expect(t, s.verifyMagicLink(link), http.StatusUnauthorized)
+	updated, _ := s.store.Users.GetByUUID(ctx, user.UUID)
+	if updated.EmailVerifiedAt != nil {
+		t.Fatal("a sign-in link sent to the old address verified the new one")
+	}
+
+	// So the victim's provider login is not linked to the attacker's account
+	code, state := provider.authorize(s.beginOIDC(), fakeLogin{Subject: "1001", Email: "ada@campus.example", EmailVerified: true})
+	expect(t, s.finishOIDC(code, state), http.StatusConflict)
+
+	// A token mailed to another address logs in but does not verify the
+	// current one
+	plain, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.store.UserTokens.Create(ctx, &model.UserToken{
+		UserID:    user.ID,
+		UserUUID:  user.UUID,
+		Purpose:   model.TokenPurposeMagicLink,
+		Email:     "eve@example.com",
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, s.verifyMagicLink(plain), http.StatusOK)
+	updated, _ = s.store.Users.GetByUUID(ctx, user.UUID)
+	if updated.EmailVerifiedAt != nil {
+		t.Error("a sign-in link sent to the old address verified the new one")
+	}
+}
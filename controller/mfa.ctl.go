@@ -0,0 +1,410 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fiet/auth"
+	"fiet/middleware"
+	"fiet/model"
+	"fiet/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMFAAttempts is how many wrong codes an MFA challenge survives.
+const maxMFAAttempts = 5
+
+// completeLogin finishes a login once the password has been checked: it
+// issues a token pair, or an MFA challenge when the account has a second
+// factor.
+func (db *DBController) completeLogin(c *gin.Context, user *model.User) {
+	ctx := c.Request.Context()
+	methods, err := db.secondFactors(ctx, user)
+	if err != nil {
+		log.Println("Error fetching second factors:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if len(methods) == 0 {
+		db.issueTokens(c, user)
+		return
+	}
+
+	token, err := db.newUserToken(ctx, user, model.TokenPurposeMFAChallenge, auth.MFAChallengeTTL)
+	if err != nil {
+		log.Println("Error creating MFA challenge:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	c.JSON(http.StatusAccepted, model.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(auth.MFAChallengeTTL.Seconds()),
+		Methods:     methods,
+	})
+}
+
+// secondFactors lists the MFA methods the user has set up: a confirmed
+// TOTP credential and registered passkeys.
+func (db *DBController) secondFactors(ctx context.Context, user *model.User) ([]string, error) {
+	methods := []string{}
+	credential, err := db.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if credential != nil && credential.ConfirmedAt != nil {
+		methods = append(methods, model.MFAMethodTOTP)
+	}
+	passkeys, err := db.WebAuthn.ListCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(passkeys) > 0 {
+		methods = append(methods, model.MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// Complete a login with the second factor
+// @Summary      Login MFA
+// @Description  Exchange the MFA token from login and a TOTP or recovery code for a token pair. The MFA token is discarded after too many wrong codes.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.MFALoginRequest  true  "MFA token and code"
+// @Success      200  {object}  model.TokenResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired MFA token, or wrong code"
+// @Failure      403  {string}  "Account is disabled, requires a password reset or has an unverified email"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/mfa [post]
+func (db *DBController) LoginMFA(c *gin.Context) {
+	var req model.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	token, user, ok := db.mfaChallenge(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	ok, err := db.verifySecondFactor(ctx, user, req.Code)
+	if err != nil {
+		log.Println("Error verifying second factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if !ok {
+		if err := db.UserTokens.Fail(ctx, token.ID, maxMFAAttempts); err != nil {
+			log.Println("Error counting MFA attempt:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+	if err := db.UserTokens.Use(ctx, token.ID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	db.issueTokens(c, user)
+}
+
+// verifySecondFactor checks code as a TOTP code, then as a recovery code,
+// and consumes it. It reports false for wrong or already used codes and
+// when the user has no confirmed TOTP.
+func (db *DBController) verifySecondFactor(ctx context.Context, user *model.User, code string) (bool, error) {
+	credential, err := db.MFA.GetTOTP(ctx, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if credential.ConfirmedAt == nil {
+		return false, nil
+	}
+
+	if step, ok := auth.ValidateTOTP(credential.Secret, code, time.Now()); ok {
+		err = db.MFA.UseTOTPStep(ctx, user.ID, step)
+	} else {
+		err = db.MFA.UseRecoveryCode(ctx, user.ID, auth.HashRecoveryCode(code))
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get the MFA status
+// @Summary      MFA Status
+// @Description  Whether TOTP is enabled for the caller, how many recovery codes are left and how many passkeys are registered.
+// @Tags         mfa
+// @Produce      json
+// @Success      200  {object}  model.MFAStatus
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/mfa [get]
+// @Security 	 BearerAuth
+func (db *DBController) GetMFAStatus(c *gin.Context) {
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var status model.MFAStatus
+	credential, err := db.MFA.GetTOTP(ctx, user.ID)
+	if err == nil {
+		status.TOTPEnabled = credential.ConfirmedAt != nil
+		status.RecoveryCodesRemaining, err = db.MFA.CountRecoveryCodes(ctx, user.ID)
+	}
+	if err == nil || errors.Is(err, repository.ErrNotFound) {
+		var passkeys []model.WebAuthnCredential
+		passkeys, err = db.WebAuthn.ListCredentials(ctx, user.ID)
+		status.WebAuthnCredentials = len(passkeys)
+	}
+	if err != nil {
+		log.Println("Error fetching MFA status:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch MFA status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// Start TOTP enrollment
+// @Summary      Enroll TOTP
+// @Description  Generate a TOTP secret for the caller. It takes effect once confirmed with a code from the authenticator app; enrolling again before that replaces the secret.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.PasswordConfirmation  true  "Current password"
+// @Success      200  {object}  model.TOTPEnrollment
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Incorrect password"
+// @Failure      409  {string}  "TOTP is already enabled"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/mfa/totp [post]
+// @Security 	 BearerAuth
+func (db *DBController) EnrollTOTP(c *gin.Context) {
+	var req model.PasswordConfirmation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || !db.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	key, err := auth.NewTOTPKey(user.Email)
+	if err == nil {
+		err = db.MFA.SetPendingTOTP(c.Request.Context(), user.ID, key.Secret)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "TOTP is already enabled"})
+			return
+		}
+		log.Println("Error enrolling TOTP:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll TOTP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.TOTPEnrollment{Secret: key.Secret, URI: key.URI, QRCode: key.QRCode})
+}
+
+// Confirm TOTP enrollment
+// @Summary      Confirm TOTP
+// @Description  Enable TOTP with a code from the authenticator app and return one-time recovery codes. They are shown only once.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success      200  {object}  model.RecoveryCodesResponse
+// @Failure      400  {string}  "Invalid code"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      409  {string}  "No pending TOTP enrollment"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/mfa/totp/confirm [post]
+// @Security 	 BearerAuth
+func (db *DBController) ConfirmTOTP(c *gin.Context) {
+	var req model.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	credential, err := db.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching TOTP credential:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm TOTP"})
+		return
+	}
+	if credential == nil || credential.ConfirmedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No pending TOTP enrollment"})
+		return
+	}
+	step, valid := auth.ValidateTOTP(credential.Secret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	// Store the codes first; they are useless until TOTP is confirmed
+	codes, ok := db.newRecoveryCodes(c, user)
+	if !ok {
+		return
+	}
+	if err := db.MFA.ConfirmTOTP(ctx, user.ID, step); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No pending TOTP enrollment"})
+			return
+		}
+		log.Println("Error confirming TOTP:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm TOTP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable TOTP
+// @Summary      Disable TOTP
+// @Description  Turn off TOTP for the caller and discard the recovery codes.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.DisableTOTPRequest  true  "Current password and a TOTP or recovery code"
+// @Success      200  {string}  "TOTP disabled"
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Incorrect password or code"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/mfa/totp/disable [post]
+// @Security 	 BearerAuth
+func (db *DBController) DisableTOTP(c *gin.Context) {
+	var req model.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || !db.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	valid, err := db.verifySecondFactor(ctx, user, req.Code)
+	if err == nil && valid {
+		err = db.MFA.DeleteTOTP(ctx, user.ID)
+	}
+	if err != nil {
+		log.Println("Error disabling TOTP:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable TOTP"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP disabled"})
+}
+
+// Regenerate recovery codes
+// @Summary      Regenerate Recovery Codes
+// @Description  Replace the recovery codes of the caller with a new set. The old codes stop working.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.PasswordConfirmation  true  "Current password"
+// @Success      200  {object}  model.RecoveryCodesResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Incorrect password"
+// @Failure      409  {string}  "TOTP is not enabled"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/mfa/recovery-codes [post]
+// @Security 	 BearerAuth
+func (db *DBController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req model.PasswordConfirmation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || !db.confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	credential, err := db.MFA.GetTOTP(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching TOTP credential:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	if credential == nil || credential.ConfirmedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "TOTP is not enabled"})
+		return
+	}
+
+	codes, ok := db.newRecoveryCodes(c, user)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// newRecoveryCodes replaces the recovery codes of user and returns the new
+// ones. It writes the error response and returns false if that fails.
+func (db *DBController) newRecoveryCodes(c *gin.Context, user *model.User) ([]string, bool) {
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err == nil {
+		err = db.MFA.ReplaceRecoveryCodes(c.Request.Context(), user.ID, hashes)
+	}
+	if err != nil {
+		log.Println("Error creating recovery codes:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return nil, false
+	}
+	return codes, true
+}
+
+// sessionUser fetches the user of the access token; service accounts have
+// none. It writes the error response and returns false if that fails.
+func (db *DBController) sessionUser(c *gin.Context) (*model.User, bool) {
+	if principal := middleware.CurrentPrincipal(c); principal != nil && !principal.IsUser() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Service accounts have no user account"})
+		return nil, false
+	}
+	user, err := db.Users.GetByUUID(c.Request.Context(), c.GetString("user_uuid"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return nil, false
+		}
+		log.Println("Error fetching user by UUID:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+	return user, true
+}
+
+// confirmPassword re-checks the password before a sensitive change, so a
+// stolen access token alone is not enough. It writes a 401 and returns
+// false if the password is wrong.
+func (db *DBController) confirmPassword(c *gin.Context, user *model.User, password string) bool {
+	if !db.PasswordHasher.Verify(password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+		return false
+	}
+	return true
+}
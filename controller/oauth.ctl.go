@@ -0,0 +1,566 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OpenIDConfiguration serves the provider metadata at
+// /.well-known/openid-configuration (outside /api/v1, so not in the
+// swagger docs).
+func (db *DBController) OpenIDConfiguration(c *gin.Context) {
+	if !db.oauthEnabled(c) {
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, db.OAuthServer.Discovery())
+}
+
+// Start an OAuth authorization
+// @Summary      Authorize
+// @Description  OpenID Connect authorization endpoint for the code flow. PKCE with S256 and the openid scope are required.
+// @Description  Valid requests redirect to the consent page of the frontend (OAUTH_CONSENT_URL) with a request token; errors redirect back to the client, unless the client or redirect_uri is unknown.
+// @Tags         oauth
+// @Produce      json
+// @Param        client_id              query  string  true   "Client ID"
+// @Param        redirect_uri           query  string  true   "Registered redirect URI"
+// @Param        response_type          query  string  true   "code"
+// @Param        scope                  query  string  true   "e.g. openid profile email"
+// @Param        state                  query  string  false  "Returned to the client as is"
+// @Param        nonce                  query  string  false  "Copied into the ID token"
+// @Param        code_challenge         query  string  true   "PKCE challenge"
+// @Param        code_challenge_method  query  string  true   "S256"
+// @Success      302  {string}  "Redirect to the consent page or back to the client"
+// @Failure      400  {object}  model.OAuthError "Unknown client or redirect_uri"
+// @Failure      404  {string}  "OpenID Connect provider is not enabled"
+// @Router       /oauth/authorize [get]
+func (db *DBController) Authorize(c *gin.Context) {
+	if !db.oauthEnabled(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	client, err := db.OAuth.GetClient(ctx, c.Query("client_id"))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error fetching OAuth client:", err)
+			c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_request", Description: "Unknown client"})
+		return
+	}
+	// Never redirect anywhere that is not registered
+	redirectURI := c.Query("redirect_uri")
+	if !client.AllowsRedirect(redirectURI) {
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_request", Description: "Unregistered redirect_uri"})
+		return
+	}
+
+	state := c.Query("state")
+	fail := func(code string, description string) {
+		c.Redirect(http.StatusFound, db.clientRedirect(redirectURI, state, url.Values{
+			"error":             {code},
+			"error_description": {description},
+		}))
+	}
+	scopes := auth.ParseScope(c.Query("scope"))
+	switch {
+	case c.Query("response_type") != "code":
+		fail("unsupported_response_type", "Only response_type=code is supported")
+		return
+	case !slices.Contains(scopes, model.ScopeOpenID):
+		fail("invalid_scope", "The openid scope is required")
+		return
+	case c.Query("code_challenge") == "" || c.Query("code_challenge_method") != "S256":
+		fail("invalid_request", "PKCE with code_challenge_method=S256 is required")
+		return
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err == nil {
+		err = db.OAuth.CreateRequest(ctx, &model.OAuthRequest{
+			RequestHash:   hash,
+			ClientID:      client.ClientID,
+			RedirectURI:   redirectURI,
+			Scope:         strings.Join(scopes, " "),
+			State:         state,
+			Nonce:         c.Query("nonce"),
+			CodeChallenge: c.Query("code_challenge"),
+			ExpiresAt:     time.Now().UTC().Add(db.OAuthServer.RequestTTL),
+		})
+	}
+	if err != nil {
+		log.Println("Error storing OAuth request:", err)
+		fail("server_error", "Failed to start authorization")
+		return
+	}
+	c.Redirect(http.StatusFound, withQuery(db.OAuthServer.ConsentURL, url.Values{"request": {token}}))
+}
+
+// Show an authorization request
+// @Summary      Get Authorization Request
+// @Description  What the consent page shows: the client and the scopes it asks for. consented is true when the user already agreed to them.
+// @Tags         oauth
+// @Produce      json
+// @Param        request  path      string  true  "Request token from the consent page URL"
+// @Success      200  {object}  model.OAuthRequestInfo
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      404  {string}  "Authorization request not found or expired"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /oauth/requests/{request} [get]
+// @Security 	 BearerAuth
+func (db *DBController) GetOAuthRequest(c *gin.Context) {
+	if !db.oauthEnabled(c) {
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	request, err := db.OAuth.GetRequest(ctx, auth.HashToken(c.Param("request")))
+	var client *model.OAuthClient
+	if err == nil {
+		client, err = db.OAuth.GetClient(ctx, request.ClientID)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Authorization request not found or expired"})
+			return
+		}
+		log.Println("Error fetching OAuth request:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authorization request"})
+		return
+	}
+
+	scopes := strings.Fields(request.Scope)
+	consent, err := db.OAuth.GetConsent(ctx, user.ID, client.ClientID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching OAuth consent:", err)
+	}
+	granted := strings.Fields(consent)
+	consented := true
+	for _, scope := range scopes {
+		consented = consented && slices.Contains(granted, scope)
+	}
+
+	c.JSON(http.StatusOK, model.OAuthRequestInfo{
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Consented:  consented,
+		ExpiresAt:  request.ExpiresAt,
+	})
+}
+
+// Approve or deny an authorization request
+// @Summary      Answer Authorization Request
+// @Description  Approve or deny the request on behalf of the caller, and get the client URL to send the browser back to, with a code or with error=access_denied.
+// @Tags         oauth
+// @Accept       json
+// @Produce      json
+// @Param        request  path     string                     true  "Request token from the consent page URL"
+// @Param        body     body     model.OAuthConsentRequest  true  "Whether the user approves"
+// @Success      200  {object}  model.OAuthConsentResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Account is disabled, requires a password reset or has an unverified email"
+// @Failure      404  {string}  "Authorization request not found or expired"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /oauth/requests/{request} [post]
+// @Security 	 BearerAuth
+func (db *DBController) AnswerOAuthRequest(c *gin.Context) {
+	if !db.oauthEnabled(c) {
+		return
+	}
+	var req model.OAuthConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || db.accountBlocked(c, user) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	request, err := db.OAuth.TakeRequest(ctx, auth.HashToken(c.Param("request")))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Authorization request not found or expired"})
+			return
+		}
+		log.Println("Error fetching OAuth request:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch authorization request"})
+		return
+	}
+	if !req.Approve {
+		c.JSON(http.StatusOK, model.OAuthConsentResponse{
+			RedirectTo: db.clientRedirect(request.RedirectURI, request.State, url.Values{
+				"error":             {"access_denied"},
+				"error_description": {"The user denied the request"},
+			}),
+		})
+		return
+	}
+
+	code, hash, err := auth.NewOpaqueToken()
+	if err == nil {
+		err = db.OAuth.CreateCode(ctx, &model.OAuthCode{
+			CodeHash:      hash,
+			ClientID:      request.ClientID,
+			UserID:        user.ID,
+			UserUUID:      user.UUID,
+			RedirectURI:   request.RedirectURI,
+			Scope:         request.Scope,
+			Nonce:         request.Nonce,
+			CodeChallenge: request.CodeChallenge,
+			ExpiresAt:     time.Now().UTC().Add(db.OAuthServer.CodeTTL),
+		})
+	}
+	if err != nil {
+		log.Println("Error storing OAuth code:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve authorization request"})
+		return
+	}
+
+	// Remember the consent on top of earlier ones; asking again is harmless
+	consent, err := db.OAuth.GetConsent(ctx, user.ID, request.ClientID)
+	if err == nil || errors.Is(err, repository.ErrNotFound) {
+		err = db.OAuth.SaveConsent(ctx, user.ID, request.ClientID,
+			strings.Join(auth.ParseScope(consent+" "+request.Scope), " "))
+	}
+	if err != nil {
+		log.Println("Error saving OAuth consent:", err)
+	}
+
+	c.JSON(http.StatusOK, model.OAuthConsentResponse{
+		RedirectTo: db.clientRedirect(request.RedirectURI, request.State, url.Values{"code": {code}}),
+	})
+}
+
+// Redeem an authorization code
+// @Summary      Token
+// @Description  OpenID Connect token endpoint. Exchanges an authorization code and its PKCE code_verifier for an ID token and an access token for the userinfo endpoint.
+// @Description  Confidential clients authenticate with HTTP Basic or client_secret in the form; public clients only send client_id.
+// @Description  Service accounts use grant_type=client_credentials with their credentials and get an access token for the API, optionally limited to the permissions in scope; this works without OAUTH_ISSUER.
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        grant_type     formData  string  true   "authorization_code or client_credentials"
+// @Param        code           formData  string  false  "Code from the redirect"
+// @Param        redirect_uri   formData  string  false  "Same redirect_uri as in the authorization request"
+// @Param        code_verifier  formData  string  false  "PKCE verifier"
+// @Param        scope          formData  string  false  "client_credentials: space separated permissions"
+// @Param        client_id      formData  string  false  "Unless sent with HTTP Basic"
+// @Param        client_secret  formData  string  false  "Unless sent with HTTP Basic"
+// @Success      200  {object}  model.OAuthTokenResponse
+// @Failure      400  {object}  model.OAuthError
+// @Failure      401  {object}  model.OAuthError "invalid_client"
+// @Failure      404  {string}  "OpenID Connect provider is not enabled"
+// @Router       /oauth/token [post]
+func (db *DBController) OAuthToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	// Service accounts work without the OpenID Connect provider
+	if c.PostForm("grant_type") == "client_credentials" {
+		db.serviceToken(c)
+		return
+	}
+	if !db.oauthEnabled(c) {
+		return
+	}
+	client, ok := db.oauthClient(c)
+	if !ok {
+		return
+	}
+	if c.PostForm("grant_type") != "authorization_code" {
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "unsupported_grant_type"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	code, err := db.OAuth.TakeCode(ctx, auth.HashToken(c.PostForm("code")))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error fetching OAuth code:", err)
+			c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_grant", Description: "Invalid or expired code"})
+		return
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != c.PostForm("redirect_uri") ||
+		!auth.VerifyPKCE(c.PostForm("code_verifier"), code.CodeChallenge) {
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_grant", Description: "Invalid or expired code"})
+		return
+	}
+	user, err := db.Users.GetByUUID(ctx, code.UserUUID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_grant", Description: "Invalid or expired code"})
+		return
+	}
+
+	accessToken, err := db.OAuthServer.AccessToken(user.UUID, client.ClientID, code.Scope)
+	var idToken string
+	if err == nil {
+		idToken, err = db.OAuthServer.IDToken(user, client.ClientID, code.Nonce, strings.Fields(code.Scope))
+	}
+	if err != nil {
+		log.Println("Error signing OAuth tokens:", err)
+		c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+		return
+	}
+	c.JSON(http.StatusOK, model.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(auth.AccessTokenTTL.Seconds()),
+		IDToken:     idToken,
+		Scope:       code.Scope,
+	})
+}
+
+// Get the claims of the signed in user
+// @Summary      UserInfo
+// @Description  OpenID Connect userinfo endpoint. Takes an access token from the token endpoint, not one from /login, and returns the public fields of the user as claims for the granted scopes.
+// @Tags         oauth
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {string}  "invalid_token"
+// @Failure      404  {string}  "OpenID Connect provider is not enabled"
+// @Router       /oauth/userinfo [get]
+// @Security 	 BearerAuth
+func (db *DBController) OAuthUserInfo(c *gin.Context) {
+	if !db.oauthEnabled(c) {
+		return
+	}
+	invalid := func() {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, model.OAuthError{Error: "invalid_token"})
+	}
+
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(http.StatusUnauthorized, model.OAuthError{Error: "invalid_request", Description: "Access token required"})
+		return
+	}
+	claims, err := db.OAuthServer.ValidateAccessToken(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		invalid()
+		return
+	}
+	// Signing out everywhere also ends the sessions of client apps
+	if db.Revoker.IsRevoked(claims.ID, claims.Subject, claims.IssuedAt.Time.Round(time.Millisecond)) {
+		invalid()
+		return
+	}
+	user, err := db.Users.GetByUUID(c.Request.Context(), claims.Subject)
+	if err != nil || user.DisabledAt != nil {
+		invalid()
+		return
+	}
+	c.JSON(http.StatusOK, auth.UserClaims(user, strings.Fields(claims.Scope)))
+}
+
+// Register an OAuth client
+// @Summary      Create OAuth Client (admin)
+// @Description  Register an app that signs users in with their account. The client_secret of a confidential client is only returned here. Requires oauth_clients:manage.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.OAuthClientRequest  true  "Client"
+// @Success      201  {object}  model.OAuthClientResponse
+// @Failure      400  {string}  "Invalid input or redirect URI"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/oauth/clients [post]
+// @Security 	 BearerAuth
+func (db *DBController) CreateOAuthClient(c *gin.Context) {
+	var req model.OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Redirect URIs must be https (or http on localhost) without a fragment", "details": uri})
+			return
+		}
+	}
+
+	client := &model.OAuthClient{
+		ClientID:     uuid.New().String(),
+		Name:         req.Name,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+	}
+	var secret string
+	if !req.Public {
+		var hash string
+		var err error
+		if secret, hash, err = auth.NewOpaqueToken(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+			return
+		}
+		client.SecretHash = &hash
+	}
+	if err := db.OAuth.CreateClient(c.Request.Context(), client); err != nil {
+		log.Println("Error creating OAuth client:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+
+	response := client.Public()
+	response.ClientSecret = secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// List OAuth clients
+// @Summary      List OAuth Clients (admin)
+// @Description  The registered apps. Requires oauth_clients:manage.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   model.OAuthClientResponse
+// @Failure      403  {string}  "Missing permission"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/oauth/clients [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListOAuthClients(c *gin.Context) {
+	clients, err := db.OAuth.ListClients(c.Request.Context())
+	if err != nil {
+		log.Println("Error listing OAuth clients:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clients"})
+		return
+	}
+	response := make([]model.OAuthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		response = append(response, client.Public())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Remove an OAuth client
+// @Summary      Delete OAuth Client (admin)
+// @Description  Remove an app along with its pending codes and the consents given to it. Access tokens it holds expire on their own. Requires oauth_clients:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        client_id  path      string  true  "Client ID"
+// @Success      200  {string}  "Client deleted"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "Client not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/oauth/clients/{client_id} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteOAuthClient(c *gin.Context) {
+	if err := db.OAuth.DeleteClient(c.Request.Context(), c.Param("client_id")); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+		log.Println("Error deleting OAuth client:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
+}
+
+// oauthEnabled writes a 404 and returns false unless OAUTH_ISSUER is set.
+func (db *DBController) oauthEnabled(c *gin.Context) bool {
+	if db.OAuthServer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OpenID Connect provider is not enabled"})
+		return false
+	}
+	return true
+}
+
+// oauthClient authenticates the client at the token endpoint, with HTTP
+// Basic or with client_id and client_secret in the form. It writes an
+// invalid_client error and returns false if that fails.
+func (db *DBController) oauthClient(c *gin.Context) (*model.OAuthClient, bool) {
+	clientID, secret, basic := clientCredentials(c)
+	client, err := db.OAuth.GetClient(c.Request.Context(), clientID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching OAuth client:", err)
+		c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+		return nil, false
+	}
+	if err != nil || (client.SecretHash != nil &&
+		subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(*client.SecretHash)) != 1) {
+		invalidClient(c, basic)
+		return nil, false
+	}
+	return client, true
+}
+
+// invalidClient writes the invalid_client error of the token endpoint.
+func invalidClient(c *gin.Context, basic bool) {
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(http.StatusUnauthorized, model.OAuthError{Error: "invalid_client"})
+}
+
+// clientCredentials reads the client ID and secret from HTTP Basic, or
+// else from the form; basic reports which.
+func clientCredentials(c *gin.Context) (clientID string, secret string, basic bool) {
+	clientID, secret, basic = c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes both before Basic
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+		return clientID, secret, true
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret"), false
+}
+
+// clientRedirect adds params, the state and the issuer (RFC 9207) to a
+// registered redirect URI.
+func (db *DBController) clientRedirect(redirectURI string, state string, params url.Values) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	params.Set("iss", db.OAuthServer.Issuer)
+	return withQuery(redirectURI, params)
+}
+
+// withQuery adds params to the query of rawURL.
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// validRedirectURI allows absolute https URIs, and http ones on the local
+// machine for development.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return u.Scheme == "https" || u.Scheme == "http"
+	}
+	return u.Scheme == "https"
+}
@@ -0,0 +1,261 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clientRedirectURL = "https://client.example/callback"
+
+// oauthServer is a test server acting as OpenID Connect provider to a
+// confidential client "app" and another client "other", both redirecting
+// to clientRedirectURL and https://client.example/other.
+func oauthServer(t *testing.T) (s *testServer, secrets map[string]string) {
+	s = newTestServer(t)
+	// Built by hand, since NewOAuthServer refuses the HS256 test keys
+	s.ctls.OAuthServer = &auth.OAuthServer{
+		Issuer:     "https://fiet.test",
+		ConsentURL: testAppURL + "/oauth/consent",
+		RequestTTL: 10 * time.Minute,
+		CodeTTL:    time.Minute,
+	}
+
+	secrets = make(map[string]string)
+	for _, clientID := range []string{"app", "other"} {
+		secret, hash, err := auth.NewOpaqueToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.store.OAuth.CreateClient(context.Background(), &model.OAuthClient{
+			ClientID:     clientID,
+			SecretHash:   &hash,
+			Name:         clientID,
+			RedirectURIs: clientRedirectURL + " https://client.example/other",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[clientID] = secret
+	}
+	return s, secrets
+}
+
+// pkce returns a code verifier and its S256 challenge.
+func pkce() (verifier string, challenge string) {
+	verifier = rand.Text() + rand.Text()
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizeQuery is a valid authorization request of client "app".
+func authorizeQuery(challenge string) url.Values {
+	return url.Values{
+		"client_id":             {"app"},
+		"redirect_uri":          {clientRedirectURL},
+		"response_type":         {"code"},
+		"scope":                 {"openid email"},
+		"state":                 {"xyz"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// authorize sends an authorization request and returns the response.
+func (s *testServer) authorize(query url.Values) *httptest.ResponseRecorder {
+	return s.call(http.MethodGet, "/api/v1/oauth/authorize?"+query.Encode(), "", nil)
+}
+
+// answer approves or denies the request the response of authorize sent
+// the browser to, and returns where the client is sent next.
+func (s *testServer) answer(w *httptest.ResponseRecorder, token string, approve bool) *url.URL {
+	s.t.Helper()
+	location := redirectLocation(s.t, w)
+	request := location.Query().Get("request")
+	if location.Path != "/oauth/consent" || request == "" {
+		s.t.Fatalf("authorization sent the browser to %s, want the consent page", location)
+	}
+	body := expect(s.t, s.call(http.MethodPost, "/api/v1/oauth/requests/"+request, token, gin.H{"approve": approve}), http.StatusOK)
+	redirect, err := url.Parse(body["redirect_to"].(string))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return redirect
+}
+
+// redirectLocation returns the Location of a 302 response.
+func redirectLocation(t *testing.T, w *httptest.ResponseRecorder) *url.URL {
+	t.Helper()
+	if w.Code != http.StatusFound {
+		t.Fatalf("got status %d, want a redirect: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// redeem exchanges a code at the token endpoint, authenticating as
+// clientID with HTTP Basic.
+func (s *testServer) redeem(clientID, secret string, form url.Values) *httptest.ResponseRecorder {
+	form.Set("grant_type", "authorization_code")
+	basic := base64.StdEncoding.EncodeToString([]byte(url.QueryEscape(clientID) + ":" + url.QueryEscape(secret)))
+	return s.call(http.MethodPost, "/api/v1/oauth/token", "", form.Encode(),
+		"Content-Type", "application/x-www-form-urlencoded", "Authorization", "Basic "+basic)
+}
+
+func TestOAuthAuthorizationCode(t *testing.T) {
+	s, secrets := oauthServer(t)
+	s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+
+	verifier, challenge := pkce()
+	redirect := s.answer(s.authorize(authorizeQuery(challenge)), token, true)
+	code := redirect.Query().Get("code")
+	if redirect.Scheme+"://"+redirect.Host+redirect.Path != clientRedirectURL || code == "" || redirect.Query().Get("state") != "xyz" {
+		t.Fatalf("approval redirects to %s, want the client with a code and the state", redirect)
+	}
+
+	form := url.Values{"code": {code}, "redirect_uri": {clientRedirectURL}, "code_verifier": {verifier}}
+	body := expect(t, s.redeem("app", secrets["app"], form), http.StatusOK)
+	if body["access_token"] == nil || body["id_token"] == nil {
+		t.Errorf("token response = %v, want an access and an ID token", body)
+	}
+
+	// Codes are single use
+	body = expect(t, s.redeem("app", secrets["app"], form), http.StatusBadRequest)
+	if body["error"] != "invalid_grant" {
+		t.Errorf("second redemption = %v, want invalid_grant", body)
+	}
+
+	expect(t, s.redeem("app", "wrong-secret", form), http.StatusUnauthorized)
+}
+
+func TestOAuthAuthorizeRedirectURI(t *testing.T) {
+	s, _ := oauthServer(t)
+	_, challenge := pkce()
+
+	// Unregistered URIs are refused outright rather than redirected to
+	for _, uri := range []string{
+		"",
+		"https://client.example/callback/",
+		"https://client.example/callback?next=/",
+		"https://CLIENT.example/callback",
+		"http://client.example/callback",
+		"https://evil.example/callback",
+	} {
+		t.Run(uri, func(t *testing.T) {
+			query := authorizeQuery(challenge)
+			query.Set("redirect_uri", uri)
+			body := expect(t, s.authorize(query), http.StatusBadRequest)
+			if body["error"] != "invalid_request" {
+				t.Errorf("error = %v, want invalid_request", body)
+			}
+		})
+	}
+
+	t.Run("unknown client", func(t *testing.T) {
+		query := authorizeQuery(challenge)
+		query.Set("client_id", "eve")
+		expect(t, s.authorize(query), http.StatusBadRequest)
+	})
+}
+
+func TestOAuthAuthorizeRequiresPKCE(t *testing.T) {
+	s, _ := oauthServer(t)
+	verifier, challenge := pkce()
+
+	tests := []struct {
+		name   string
+		method string
+		value  string
+	}{
+		{"missing challenge", "S256", ""},
+		{"missing method", "", challenge},
+		{"plain", "plain", verifier},
+		{"unknown method", "S512", challenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := authorizeQuery(tt.value)
+			query.Set("code_challenge_method", tt.method)
+			location := redirectLocation(t, s.authorize(query))
+			if location.Host != "client.example" || location.Query().Get("error") != "invalid_request" ||
+				location.Query().Get("state") != "xyz" {
+				t.Errorf("redirect = %s, want invalid_request at the client", location)
+			}
+		})
+	}
+}
+
+func TestOAuthTokenBindsCode(t *testing.T) {
+	s, secrets := oauthServer(t)
+	s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+
+	tests := []struct {
+		name     string
+		clientID string
+		redirect string
+		verifier func(verifier string) string
+	}{
+		{"other client", "other", clientRedirectURL, nil},
+		{"other redirect_uri", "app", "https://client.example/other", nil},
+		{"missing redirect_uri", "app", "", nil},
+		{"wrong verifier", "app", clientRedirectURL, func(string) string { v, _ := pkce(); return v }},
+		{"challenge as verifier", "app", clientRedirectURL, func(v string) string {
+			sum := sha256.Sum256([]byte(v))
+			return base64.RawURLEncoding.EncodeToString(sum[:])
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, challenge := pkce()
+			code := s.answer(s.authorize(authorizeQuery(challenge)), token, true).Query().Get("code")
+			sent := verifier
+			if tt.verifier != nil {
+				sent = tt.verifier(verifier)
+			}
+			form := url.Values{"code": {code}, "redirect_uri": {tt.redirect}, "code_verifier": {sent}}
+			body := expect(t, s.redeem(tt.clientID, secrets[tt.clientID], form), http.StatusBadRequest)
+			if body["error"] != "invalid_grant" {
+				t.Errorf("error = %v, want invalid_grant", body)
+			}
+
+			// A failed attempt uses up the code
+			form = url.Values{"code": {code}, "redirect_uri": {clientRedirectURL}, "code_verifier": {verifier}}
+			expect(t, s.redeem("app", secrets["app"], form), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestOAuthConsentDenied(t *testing.T) {
+	s, _ := oauthServer(t)
+	s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+	_, challenge := pkce()
+
+	w := s.authorize(authorizeQuery(challenge))
+	redirect := s.answer(w, token, false)
+	query := redirect.Query()
+	if redirect.Host != "client.example" || query.Get("error") != "access_denied" ||
+		query.Get("state") != "xyz" || query.Get("code") != "" {
+		t.Errorf("denial redirects to %s, want access_denied at the client", redirect)
+	}
+
+	// The request is answered once
+	request := redirectLocation(t, w).Query().Get("request")
+	expect(t, s.call(http.MethodPost, "/api/v1/oauth/requests/"+request, token, gin.H{"approve": true}), http.StatusNotFound)
+}
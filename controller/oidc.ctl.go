@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// List identity providers
+// @Summary      List Identity Providers
+// @Description  The OpenID Connect providers users can log in with.
+// @Tags         oidc
+// @Produce      json
+// @Success      200  {array}   model.OIDCProviderInfo
+// @Router       /login/oidc/providers [get]
+func (db *DBController) GetOIDCProviders(c *gin.Context) {
+	providers := []model.OIDCProviderInfo{}
+	for _, p := range db.OIDCProviders {
+		providers = append(providers, model.OIDCProviderInfo{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	c.JSON(http.StatusOK, providers)
+}
+
+// Start logging in with an identity provider
+// @Summary      Begin OIDC Login
+// @Description  Return the provider URL to send the browser to. The provider redirects back to OIDC_REDIRECT_URL with a code and state, which the frontend passes to /login/oidc/finish.
+// @Tags         oidc
+// @Produce      json
+// @Param        provider  path      string  true  "Provider name"
+// @Success      200  {object}  model.OIDCBeginResponse
+// @Failure      404  {string}  "Unknown identity provider"
+// @Failure      502  {string}  "Identity provider unavailable"
+// @Router       /login/oidc/{provider}/begin [post]
+func (db *DBController) BeginOIDCLogin(c *gin.Context) {
+	provider, ok := db.oidcProvider(c)
+	if !ok {
+		return
+	}
+	db.startOIDC(c, provider, model.OIDCLogin, nil)
+}
+
+// Finish logging in with an identity provider
+// @Summary      Finish OIDC Login
+// @Description  Redeem the code from the provider and return a token pair, or an MFA token when the account has a second factor.
+// @Description  An unknown identity is linked to the account with its email, or a new account is created, as long as the provider verified the email.
+// @Tags         oidc
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.OIDCFinishRequest  true  "Code and state from the redirect"
+// @Success      200  {object}  model.TokenResponse
+// @Success      202  {object}  model.MFAChallengeResponse "Second factor required"
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired state, or the provider did not confirm the login"
+// @Failure      403  {string}  "Email not verified or not allowed, or the account is blocked"
+// @Failure      409  {string}  "An account with this email exists but its email is unverified"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/oidc/finish [post]
+func (db *DBController) FinishOIDCLogin(c *gin.Context) {
+	var req model.OIDCFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	provider, claims, ok := db.finishOIDC(c, model.OIDCLogin, req, nil)
+	if !ok {
+		return
+	}
+	user, ok := db.oidcUser(c, provider, claims)
+	if !ok || db.accountBlocked(c, user) {
+		return
+	}
+	db.completeLogin(c, user)
+}
+
+// List linked identities
+// @Summary      List Linked Identities
+// @Description  The identity provider accounts that can log in as the caller.
+// @Tags         oidc
+// @Produce      json
+// @Success      200  {array}   model.UserIdentity
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/identities [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListIdentities(c *gin.Context) {
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	identities, err := db.Identities.List(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error listing identities:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identities"})
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// Start linking an identity provider
+// @Summary      Begin Linking Identity
+// @Description  Return the provider URL to send the browser to. The frontend passes the code and state from the redirect to /user/identities/finish.
+// @Tags         oidc
+// @Accept       json
+// @Produce      json
+// @Param        provider  path     string                 true  "Provider name"
+// @Param        body      body     model.OIDCLinkRequest  true  "Current password"
+// @Success      200  {object}  model.OIDCBeginResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Incorrect password"
+// @Failure      404  {string}  "Unknown identity provider"
+// @Failure      502  {string}  "Identity provider unavailable"
+// @Router       /user/identities/{provider}/begin [post]
+// @Security 	 BearerAuth
+func (db *DBController) BeginOIDCLink(c *gin.Context) {
+	var req model.OIDCLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	provider, ok := db.oidcProvider(c)
+	if !ok {
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || !db.confirmPassword(c, user, req.Password) {
+		return
+	}
+	db.startOIDC(c, provider, model.OIDCLink, &user.ID)
+}
+
+// Finish linking an identity provider
+// @Summary      Finish Linking Identity
+// @Description  Redeem the code from the provider and link its account to the caller.
+// @Tags         oidc
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.OIDCFinishRequest  true  "Code and state from the redirect"
+// @Success      201  {object}  model.UserIdentity
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired state, or the provider did not confirm the login"
+// @Failure      403  {string}  "Email not allowed"
+// @Failure      409  {string}  "Identity already linked"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/identities/finish [post]
+// @Security 	 BearerAuth
+func (db *DBController) FinishOIDCLink(c *gin.Context) {
+	var req model.OIDCFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	provider, claims, ok := db.finishOIDC(c, model.OIDCLink, req, user)
+	if !ok {
+		return
+	}
+
+	identity := &model.UserIdentity{
+		UserID:   user.ID,
+		UserUUID: user.UUID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := db.Identities.Create(c.Request.Context(), identity); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Identity already linked"})
+			return
+		}
+		log.Println("Error linking identity:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+	c.JSON(http.StatusCreated, identity)
+}
+
+// Unlink an identity provider
+// @Summary      Unlink Identity
+// @Description  Remove one of the caller's linked identities. The last way to log in cannot be removed; set a password first.
+// @Tags         oidc
+// @Produce      json
+// @Param        id   path      int  true  "Identity ID"
+// @Success      200  {string}  "Identity unlinked"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      404  {string}  "Identity not found"
+// @Failure      409  {string}  "Last way to log in"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/identities/{id} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteIdentity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if user.Password == "" {
+		identities, err := db.Identities.List(ctx, user.ID)
+		if err == nil && len(identities) == 1 && identities[0].ID == id {
+			var passkeys []model.WebAuthnCredential
+			passkeys, err = db.WebAuthn.ListCredentials(ctx, user.ID)
+			if err == nil && len(passkeys) == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Set a password before unlinking the last way to log in"})
+				return
+			}
+		}
+		if err != nil {
+			log.Println("Error checking login methods:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+			return
+		}
+	}
+
+	if err := db.Identities.Delete(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+			return
+		}
+		log.Println("Error unlinking identity:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+}
+
+// oidcProvider looks up the provider named in the path. It writes a 404
+// and returns false if there is none.
+func (db *DBController) oidcProvider(c *gin.Context) (*auth.OIDCProvider, bool) {
+	provider, ok := db.OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return nil, false
+	}
+	return provider, true
+}
+
+// startOIDC stores a new authorization request and responds with the URL
+// that sends the browser to the provider.
+func (db *DBController) startOIDC(c *gin.Context, provider *auth.OIDCProvider, purpose string, userID *int) {
+	// Only the hash of the state is stored, like any other token
+	state, stateHash, err := auth.NewOpaqueToken()
+	var nonce, verifier string
+	if err == nil {
+		nonce, _, err = auth.NewOpaqueToken()
+	}
+	if err == nil {
+		verifier, _, err = auth.NewOpaqueToken()
+	}
+	if err != nil {
+		log.Println("Error generating OIDC state:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error contacting OIDC provider %s: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+	err = db.Identities.CreateState(ctx, &model.OIDCState{
+		StateHash:    stateHash,
+		Provider:     provider.Name,
+		Purpose:      purpose,
+		UserID:       userID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(auth.OIDCStateTTL),
+	})
+	if err != nil {
+		log.Println("Error storing OIDC state:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	c.JSON(http.StatusOK, model.OIDCBeginResponse{
+		AuthorizationURL: authURL,
+		ExpiresIn:        int64(auth.OIDCStateTTL.Seconds()),
+	})
+}
+
+// finishOIDC consumes the authorization request of purpose, which must
+// belong to user unless user is nil, and redeems the code at its provider.
+// It writes the error response and returns false if that fails.
+func (db *DBController) finishOIDC(c *gin.Context, purpose string, req model.OIDCFinishRequest, user *model.User) (*auth.OIDCProvider, *auth.OIDCClaims, bool) {
+	ctx := c.Request.Context()
+	state, err := db.Identities.TakeState(ctx, purpose, auth.HashToken(req.State))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching OIDC state:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login state"})
+		return nil, nil, false
+	}
+	if err != nil || (user != nil && (state.UserID == nil || *state.UserID != user.ID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired state"})
+		return nil, nil, false
+	}
+	provider, ok := db.OIDCProviders[state.Provider]
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired state"})
+		return nil, nil, false
+	}
+
+	claims, err := provider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login with the identity provider failed"})
+		return nil, nil, false
+	}
+	if !provider.Allows(claims) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email domain not allowed"})
+		return nil, nil, false
+	}
+	return provider, claims, true
+}
+
+// oidcUser finds the user an identity logs in as. An unknown identity is
+// linked to the user with the same email, or to a new user, but only when
+// the provider verified the email. An existing account must have verified
+// its email too, so that whoever signed up with an address they do not own
+// cannot take over the account of its real owner. It writes the error
+// response and returns false if the login cannot go on.
+func (db *DBController) oidcUser(c *gin.Context, provider *auth.OIDCProvider, claims *auth.OIDCClaims) (*model.User, bool) {
+	ctx := c.Request.Context()
+	identity, err := db.Identities.Get(ctx, provider.Name, claims.Subject)
+	if err == nil {
+		user, err := db.Users.GetByUUID(ctx, identity.UserUUID)
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				log.Println("Error fetching user by UUID:", err)
+			}
+			// Deleted accounts are restored with their password
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login with the identity provider failed"})
+			return nil, false
+		}
+		if err := db.Identities.RecordLogin(ctx, identity.ID, claims.Email); err != nil {
+			log.Println("Error recording identity login:", err)
+		}
+		return user, true
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching identity:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return nil, false
+	}
+
+	email, ok := provider.VerifiedEmail(claims)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The identity provider did not verify the email address"})
+		return nil, false
+	}
+	user, err := db.Users.GetByEmail(ctx, email)
+	switch {
+	case err == nil && user.EmailVerifiedAt == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "Log in with your password and verify your email to use this provider"})
+		return nil, false
+	case errors.Is(err, repository.ErrNotFound):
+		if user, ok = db.createOIDCUser(c, email, claims.Name); !ok {
+			return nil, false
+		}
+	case err != nil:
+		log.Println("Error fetching user by email:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return nil, false
+	}
+
+	err = db.Identities.Create(ctx, &model.UserIdentity{
+		UserID:   user.ID,
+		UserUUID: user.UUID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		log.Println("Error linking identity:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return nil, false
+	}
+	return user, true
+}
+
+// createOIDCUser signs up a user whose email the identity provider
+// verified. The account has no password until one is set through a
+// password reset. It writes the error response and returns false if that
+// fails.
+func (db *DBController) createOIDCUser(c *gin.Context, email string, name string) (*model.User, bool) {
+	ctx := c.Request.Context()
+	user := &model.User{UUID: uuid.New().String(), Email: email}
+	if err := db.Users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			// e.g. a deleted account that can still be restored
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return nil, false
+		}
+		log.Println("Error creating user:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User creation failed"})
+		return nil, false
+	}
+
+	if err := db.Users.MarkEmailVerified(ctx, user.UUID); err != nil {
+		log.Println("Error marking email verified:", err)
+	}
+	if name != "" {
+		if err := db.Users.Update(ctx, user.UUID, map[string]interface{}{"name": name}); err != nil {
+			log.Println("Error setting name:", err)
+		}
+	}
+	// Every new account starts as a student
+	if err := db.Roles.AssignRole(ctx, user.ID, model.RoleStudent); err != nil {
+		log.Println("Error assigning default role:", err)
+	}
+
+	// Reload for the fields set above
+	reloaded, err := db.Users.GetByUUID(ctx, user.UUID)
+	if err != nil {
+		log.Println("Error fetching user by UUID:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return nil, false
+	}
+	return reloaded, true
+}
@@ -0,0 +1,279 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"fiet/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fakeClientID     = "fiet"
+	fakeClientSecret = "fiet-secret"
+	fakeRedirectURL  = testAppURL + "/login/oidc/callback"
+)
+
+// fakeProvider is an OpenID Connect provider with discovery, token and
+// JWKS endpoints, at which tests log in as whichever account they like.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeLogin // by authorization code
+}
+
+// fakeLogin is an account logged in at the provider.
+type fakeLogin struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Nonce replaces the nonce of the authorization request when set
+	Nonce string
+
+	challenge string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{t: t, key: key, codes: make(map[string]fakeLogin)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "use": "sig", "alg": "RS256",
+			"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// provider configures the fake as provider "campus".
+func (p *fakeProvider) provider() map[string]*auth.OIDCProvider {
+	return map[string]*auth.OIDCProvider{"campus": {
+		Name:         "campus",
+		DisplayName:  "Campus",
+		Issuer:       p.server.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		RedirectURL:  fakeRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		HTTPClient:   p.server.Client(),
+	}}
+}
+
+// authorize logs login in for the authorization URL from a begin request
+// and returns the code and state the provider redirects back with.
+func (p *fakeProvider) authorize(authURL string, login fakeLogin) (code string, state string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != fakeClientID || query.Get("redirect_uri") != fakeRedirectURL ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		p.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	login.challenge = query.Get("code_challenge")
+	if login.Nonce == "" {
+		login.Nonce = query.Get("nonce")
+	}
+	code = rand.Text()
+	p.mu.Lock()
+	p.codes[code] = login
+	p.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != fakeClientID || secret != fakeClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	login, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("redirect_uri") != fakeRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != login.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            fakeClientID,
+		"sub":            login.Subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          login.Nonce,
+		"email":          login.Email,
+		"email_verified": login.EmailVerified,
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": rand.Text(), "token_type": "Bearer", "id_token": signed})
+}
+
+// oidcServer is a test server with the fake provider as provider "campus".
+func oidcServer(t *testing.T) (*testServer, *fakeProvider) {
+	s := newTestServer(t)
+	provider := newFakeProvider(t)
+	s.ctls.OIDCProviders = provider.provider()
+	return s, provider
+}
+
+// beginOIDC starts a login with the fake provider and returns the URL the
+// browser is sent to.
+func (s *testServer) beginOIDC() string {
+	s.t.Helper()
+	body := expect(s.t, s.call(http.MethodPost, "/api/v1/login/oidc/campus/begin", "", nil), http.StatusOK)
+	return body["authorization_url"].(string)
+}
+
+// finishOIDC finishes a login with the code and state from the provider.
+func (s *testServer) finishOIDC(code, state string) *httptest.ResponseRecorder {
+	return s.call(http.MethodPost, "/api/v1/login/oidc/finish", "", gin.H{"code": code, "state": state})
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s, provider := oidcServer(t)
+	login := fakeLogin{Subject: "1001", Email: "ada@campus.example", EmailVerified: true}
+
+	code, state := provider.authorize(s.beginOIDC(), login)
+	body := expect(t, s.finishOIDC(code, state), http.StatusOK)
+	me := expect(t, s.call(http.MethodGet, "/api/v1/user", body["token"].(string), nil), http.StatusOK)
+	if me["email"] != "ada@campus.example" || me["email_verified_at"] == nil {
+		t.Errorf("new user = %v, want ada@campus.example with a verified email", me)
+	}
+
+	// The identity logs in to the same account, whatever its email now is
+	login.Email = "ada.lovelace@campus.example"
+	code, state = provider.authorize(s.beginOIDC(), login)
+	body = expect(t, s.finishOIDC(code, state), http.StatusOK)
+	again := expect(t, s.call(http.MethodGet, "/api/v1/user", body["token"].(string), nil), http.StatusOK)
+	if again["uuid"] != me["uuid"] {
+		t.Errorf("second login as %v, want %v", again["uuid"], me["uuid"])
+	}
+}
+
+func TestOIDCRejectsForgedResponses(t *testing.T) {
+	s, provider := oidcServer(t)
+	login := fakeLogin{Subject: "1001", Email: "ada@campus.example", EmailVerified: true}
+
+	t.Run("unknown state", func(t *testing.T) {
+		code, _ := provider.authorize(s.beginOIDC(), login)
+		expect(t, s.finishOIDC(code, rand.Text()), http.StatusUnauthorized)
+	})
+
+	t.Run("replayed state", func(t *testing.T) {
+		code, state := provider.authorize(s.beginOIDC(), login)
+		expect(t, s.finishOIDC(code, state), http.StatusOK)
+		code, _ = provider.authorize(s.beginOIDC(), login)
+		expect(t, s.finishOIDC(code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("state of a link request", func(t *testing.T) {
+		s.signup("bob@example.com")
+		token := s.login("bob@example.com")
+		body := expect(t, s.call(http.MethodPost, "/api/v1/user/identities/campus/begin", token, gin.H{"password": testPassword}), http.StatusOK)
+		code, state := provider.authorize(body["authorization_url"].(string), fakeLogin{Subject: "1002", Email: "bob@campus.example", EmailVerified: true})
+		expect(t, s.finishOIDC(code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		forged := login
+		forged.Nonce = rand.Text()
+		code, state := provider.authorize(s.beginOIDC(), forged)
+		expect(t, s.finishOIDC(code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("PKCE mismatch", func(t *testing.T) {
+		// A code issued for another login, e.g. the attacker's own,
+		// injected into the victim's callback
+		code, _ := provider.authorize(s.beginOIDC(), login)
+		_, state := provider.authorize(s.beginOIDC(), login)
+		expect(t, s.finishOIDC(code, state), http.StatusUnauthorized)
+	})
+}
+
+func TestOIDCAccountLinking(t *testing.T) {
+	s, provider := oidcServer(t)
+	ctx := context.Background()
+
+	t.Run("email not verified by the provider", func(t *testing.T) {
+		code, state := provider.authorize(s.beginOIDC(), fakeLogin{Subject: "1001", Email: "ada@campus.example"})
+		expect(t, s.finishOIDC(code, state), http.StatusForbidden)
+		if _, err := s.store.Users.GetByEmail(ctx, "ada@campus.example"); err == nil {
+			t.Error("an account was created for an unverified email")
+		}
+	})
+
+	t.Run("unverified local account", func(t *testing.T) {
+		user := s.signup("bob@campus.example")
+		code, state := provider.authorize(s.beginOIDC(), fakeLogin{Subject: "1002", Email: "bob@campus.example", EmailVerified: true})
+		expect(t, s.finishOIDC(code, state), http.StatusConflict)
+		if identities, _ := s.store.Identities.List(ctx, user.ID); len(identities) != 0 {
+			t.Errorf("identity linked to an unverified account: %v", identities)
+		}
+	})
+
+	t.Run("verified local account", func(t *testing.T) {
+		user := s.signup("carol@campus.example")
+		if err := s.store.Users.MarkEmailVerified(ctx, user.UUID); err != nil {
+			t.Fatal(err)
+		}
+		code, state := provider.authorize(s.beginOIDC(), fakeLogin{Subject: "1003", Email: "carol@campus.example", EmailVerified: true})
+		body := expect(t, s.finishOIDC(code, state), http.StatusOK)
+		me := expect(t, s.call(http.MethodGet, "/api/v1/user", body["token"].(string), nil), http.StatusOK)
+		if me["uuid"] != user.UUID {
+			t.Errorf("logged in as %v, want the existing account %s", me["uuid"], user.UUID)
+		}
+		identities, err := s.store.Identities.List(ctx, user.ID)
+		if err != nil || len(identities) != 1 || identities[0].Subject != "1003" {
+			t.Errorf("identities = %v, %v; want the campus identity", identities, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageCursor is the opaque cursor handed out for keyset pagination. It
+// remembers the sort order it was issued for so it cannot be replayed
+// against a different one.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	After string `json:"a"` // UUID of the last item of the previous page
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.After == "" {
+		return cursor, fmt.Errorf("cursor without position")
+	}
+	return cursor, nil
+}
+
+// pageLink is one entry of an RFC 8288 Link header.
+type pageLink struct {
+	Rel   string
+	Query map[string]string // parameters to replace; "" removes one
+}
+
+// setLinkHeader writes a Link header whose targets are the current request
+// URL with each link's query parameters replaced.
+func setLinkHeader(c *gin.Context, links []pageLink) {
+	if len(links) == 0 {
+		return
+	}
+
+	values := make([]string, len(links))
+	for i, link := range links {
+		u := *c.Request.URL
+		query := u.Query()
+		for key, value := range link.Query {
+			if value == "" {
+				query.Del(key)
+			} else {
+				query.Set(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		values[i] = fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), link.Rel)
+	}
+	c.Header("Link", strings.Join(values, ", "))
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fiet/auth"
+	"fiet/mail"
+	"fiet/model"
+	"fiet/repository"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request a password reset
+// @Summary      Forgot Password
+// @Description  Email a single-use password reset link. The response is the same whether or not the email is registered.
+// @Tags         password
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.ForgotPasswordRequest  true  "Account email"
+// @Success      202  {string}  "If the email is registered, a reset link has been sent"
+// @Failure      400  {string}  "Invalid input"
+// @Router       /password/forgot [post]
+func (db *DBController) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	db.mailAccount(c.Request.Context(), req.Email, db.sendPasswordReset)
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// sendPasswordReset replaces any pending reset token of user with a new
+// one and mails the link in the background.
+func (db *DBController) sendPasswordReset(ctx context.Context, user *model.User) error {
+	token, err := db.newUserToken(ctx, user, model.TokenPurposePasswordReset, auth.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := db.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	db.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"Open this link within %s to choose a new password:\n\n%s\n\n"+
+			"If it was not you, ignore this email; your password has not changed.\n",
+			auth.PasswordResetTTL, link),
+	})
+	return nil
+}
+
+// Reset the password
+// @Summary      Reset Password
+// @Description  Set a new password with the token from a reset email. The token works once; every session of the account is signed out.
+// @Tags         password
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.ResetPasswordRequest  true  "Reset token and new password"
+// @Success      200  {string}  "Password has been reset"
+// @Failure      400  {string}  "Invalid or expired token, or the new password breaks the policy"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /password/reset [post]
+func (db *DBController) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := db.UserTokens.GetValid(ctx, model.TokenPurposePasswordReset, auth.HashToken(req.Token))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error fetching reset token:", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	user, err := db.Users.GetByUUID(ctx, token.UserUUID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	// Validate first so a rejected password does not burn the token
+	if !db.checkNewPassword(c, user, req.NewPassword) {
+		return
+	}
+	if err := db.UserTokens.Use(ctx, token.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Redeemed concurrently
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		log.Println("Error using reset token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if !db.storePassword(c, user, req.NewPassword) {
+		return
+	}
+
+	if err := db.UserTokens.RevokeAll(ctx, user.ID, model.TokenPurposePasswordReset); err != nil {
+		log.Println("Error revoking reset tokens:", err)
+	}
+	db.verifyMailedAddress(ctx, user, token)
+	// Whoever knew the old password is signed out
+	if err := db.revokeAllSessions(c, user); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
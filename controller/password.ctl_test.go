@@ -0,0 +1,76 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const newPassword = "correct-horse-battery"
+
+func TestResetPassword(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+	oldToken := s.login("ada@example.com")
+
+	expect(t, s.call(http.MethodPost, "/api/v1/password/forgot", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted)
+	token := s.mailToken(s.waitMail("ada@example.com", "Reset your password"))
+
+	// A rejected password does not use up the token
+	expect(t, s.call(http.MethodPost, "/api/v1/password/reset", "", gin.H{"token": token, "new_password": "short"}), http.StatusBadRequest)
+	expect(t, s.call(http.MethodPost, "/api/v1/password/reset", "", gin.H{"token": token, "new_password": newPassword}), http.StatusOK)
+	expect(t, s.call(http.MethodPost, "/api/v1/password/reset", "", gin.H{"token": token, "new_password": "another-horse-battery"}), http.StatusBadRequest)
+
+	expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": newPassword}), http.StatusOK)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", oldToken, nil), http.StatusUnauthorized)
+	updated, _ := s.store.Users.GetByUUID(context.Background(), user.UUID)
+	if updated.EmailVerifiedAt == nil {
+		t.Error("resetting with a link to the account email did not verify it")
+	}
+
+	// Unknown addresses get the same answer and no mail
+	expect(t, s.call(http.MethodPost, "/api/v1/password/forgot", "", gin.H{"email": "bob@example.com"}), http.StatusAccepted)
+	s.noMail("bob@example.com", "Reset your password")
+}
+
+func TestResetPasswordAfterEmailChange(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+	ctx := context.Background()
+
+	expect(t, s.call(http.MethodPost, "/api/v1/password/forgot", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted)
+	resetToken := s.mailToken(s.waitMail("ada@example.com", "Reset your password"))
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", token, gin.H{"email": "victim@example.com"}), http.StatusOK)
+
+	expect(t, s.call(http.MethodPost, "/api/v1/password/reset", "", gin.H{"token": resetToken, "new_password": newPassword}), http.StatusBadRequest)
+
+	// A token mailed to another address resets the password but does not
+	// verify the current one
+	plain, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.store.UserTokens.Create(ctx, &model.UserToken{
+		UserID:    user.ID,
+		UserUUID:  user.UUID,
+		Purpose:   model.TokenPurposePasswordReset,
+		Email:     "ada@example.com",
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, s.call(http.MethodPost, "/api/v1/password/reset", "", gin.H{"token": plain, "new_password": newPassword}), http.StatusOK)
+	updated, _ := s.store.Users.GetByUUID(ctx, user.UUID)
+	if updated.EmailVerifiedAt != nil {
+		t.Error("a reset link sent to the old address verified the new one")
+	}
+}
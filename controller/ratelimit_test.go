@@ -0,0 +1,37 @@
+package controller_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	// newTestServer turns the limits off for every other test
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	s.routes()
+	forgot := gin.H{"email": "ada@example.com"}
+
+	// The email policy allows 5 requests an hour per client IP
+	for i := range 5 {
+		w := s.call(http.MethodPost, "/api/v1/password/forgot", "", forgot)
+		expect(t, w, http.StatusAccepted)
+		if remaining := w.Header().Get("RateLimit-Remaining"); remaining != strconv.Itoa(4-i) {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %d", i+1, remaining, 4-i)
+		}
+	}
+	w := s.call(http.MethodPost, "/api/v1/password/forgot", "", forgot)
+	expect(t, w, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry <= 0 {
+		t.Errorf("Retry-After = %q, want the seconds until the next request", w.Header().Get("Retry-After"))
+	}
+
+	// Routes of the same policy share the bucket, while other clients and
+	// other policies have their own
+	expect(t, s.call(http.MethodPost, "/api/v1/verify-email/resend", "", forgot), http.StatusTooManyRequests)
+	expect(t, s.call(http.MethodPost, "/api/v1/password/forgot", "", forgot, "X-Forwarded-For", "198.51.100.7"), http.StatusAccepted)
+	expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword}), http.StatusUnauthorized)
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"errors"
+	"fiet/model"
+	"fiet/repository"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List roles
+// @Summary      List Roles
+// @Description  List every role and its permissions. Requires roles:list.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   model.Role
+// @Failure      403  {string}  "Missing permission"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/roles [get]
+// @Security 	 BearerAuth
+func (db *DBController) GetRoles(c *gin.Context) {
+	roles, err := db.Roles.ListRoles(c.Request.Context())
+	if err != nil {
+		log.Println("Error fetching roles:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// Get roles of a user
+// @Summary      Get User Roles
+// @Description  List the roles of a user. Requires roles:list.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Success      200  {array}   string
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/roles [get]
+// @Security 	 BearerAuth
+func (db *DBController) GetUserRoles(c *gin.Context) {
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	roles, err := db.Roles.GetUserRoles(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error fetching user roles:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// Assign a role
+// @Summary      Assign Role
+// @Description  Give a user a role. Requires roles:assign.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        uuid  path      string               true  "User UUID"
+// @Param        role  body      model.RoleAssignment true  "Role to assign"
+// @Success      200  {string}  "Role assigned"
+// @Failure      400  {string}  "Invalid input"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User or role not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/roles [post]
+// @Security 	 BearerAuth
+func (db *DBController) AssignRole(c *gin.Context) {
+	var req model.RoleAssignment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := db.Roles.AssignRole(c.Request.Context(), user.ID, req.Role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+		log.Println("Error assigning role:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+
+	// New permissions show up in the user's next access token
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+// Remove a role
+// @Summary      Remove Role
+// @Description  Take a role away from a user. Their current access tokens are revoked so the change applies immediately. Requires roles:assign.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "User UUID"
+// @Param        role  path      string  true  "Role name"
+// @Success      200  {string}  "Role removed"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "User or role assignment not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/users/{uuid}/roles/{role} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) RemoveRole(c *gin.Context) {
+	user, ok := db.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := db.Roles.RemoveRole(c.Request.Context(), user.ID, c.Param("role")); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User does not have this role"})
+			return
+		}
+		log.Println("Error removing role:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+
+	// Access tokens still carry the old permissions; cut them off. The
+	// refresh token keeps working and yields a token with the new ones.
+	if err := db.Revoker.RevokeUser(c.Request.Context(), user.UUID); err != nil {
+		log.Println("Error revoking access tokens:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed"})
+}
+
+// userFromParam loads the user named by the :uuid path parameter and
+// writes the error response if that fails.
+func (db *DBController) userFromParam(c *gin.Context) (*model.User, bool) {
+	user, err := db.Users.GetByUUID(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return nil, false
+		}
+		log.Println("Error fetching user by UUID:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"fiet/auth"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// serviceClientIDPrefix starts the client ID of every service account.
+const serviceClientIDPrefix = "svc_"
+
+// serviceToken answers the client_credentials grant of the token endpoint
+// with an access token for a service account.
+func (db *DBController) serviceToken(c *gin.Context) {
+	clientID, secret, basic := clientCredentials(c)
+	ctx := c.Request.Context()
+	account, err := db.ServiceAccounts.GetByClientID(ctx, clientID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching service account:", err)
+		c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+		return
+	}
+	if err != nil || subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(account.SecretHash)) != 1 {
+		invalidClient(c, basic)
+		return
+	}
+
+	// A scope narrows the token down to some of the account's permissions
+	permissions := strings.Fields(account.Permissions)
+	if scope := c.PostForm("scope"); scope != "" {
+		requested := strings.Fields(scope)
+		for _, permission := range requested {
+			if !slices.Contains(permissions, permission) {
+				c.JSON(http.StatusBadRequest, model.OAuthError{Error: "invalid_scope", Description: permission})
+				return
+			}
+		}
+		permissions = requested
+	}
+
+	token, err := auth.GenerateServiceToken(account.UUID, permissions)
+	if err != nil {
+		log.Println("Error signing service token:", err)
+		c.JSON(http.StatusInternalServerError, model.OAuthError{Error: "server_error"})
+		return
+	}
+	if err := db.ServiceAccounts.RecordToken(ctx, account.ID, time.Now()); err != nil {
+		log.Println("Error recording service token:", err)
+	}
+	c.JSON(http.StatusOK, model.OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(auth.AccessTokenTTL.Seconds()),
+		Scope:       strings.Join(permissions, " "),
+	})
+}
+
+// Create a service account
+// @Summary      Create Service Account (admin)
+// @Description  Create a non-human principal for batch jobs. It gets access tokens from /oauth/token with grant_type=client_credentials; the client_secret is only returned here. Its permissions must be held by the caller. Requires service_accounts:manage.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.ServiceAccountRequest  true  "Service account"
+// @Success      201  {object}  model.ServiceAccountResponse
+// @Failure      400  {string}  "Invalid input or permission"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/service-accounts [post]
+// @Security 	 BearerAuth
+func (db *DBController) CreateServiceAccount(c *gin.Context) {
+	var req model.ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	held := c.GetStringSlice("permissions")
+	permissions := []string{}
+	for _, permission := range req.Permissions {
+		if !slices.Contains(held, permission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Permissions must be ones you hold", "details": permission})
+			return
+		}
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	clientID, _, err := auth.NewOpaqueToken()
+	var secret, hash string
+	if err == nil {
+		secret, hash, err = auth.NewOpaqueToken()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+	account := &model.ServiceAccount{
+		UUID:        uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		ClientID:    serviceClientIDPrefix + clientID[:16],
+		SecretHash:  hash,
+		Permissions: strings.Join(permissions, " "),
+	}
+	if err := db.ServiceAccounts.Create(c.Request.Context(), account); err != nil {
+		log.Println("Error creating service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	response := account.Public()
+	response.ClientSecret = secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// List service accounts
+// @Summary      List Service Accounts (admin)
+// @Description  Every service account, without secrets. Requires service_accounts:manage.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   model.ServiceAccountResponse
+// @Failure      403  {string}  "Missing permission"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/service-accounts [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListServiceAccounts(c *gin.Context) {
+	accounts, err := db.ServiceAccounts.List(c.Request.Context())
+	if err != nil {
+		log.Println("Error listing service accounts:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch service accounts"})
+		return
+	}
+	response := make([]model.ServiceAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, account.Public())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Rotate the secret of a service account
+// @Summary      Rotate Service Account Secret (admin)
+// @Description  Replace the client_secret and revoke the access tokens issued with the old one. The new secret is only returned here. Requires service_accounts:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "Service account UUID"
+// @Success      200  {object}  model.ServiceAccountResponse
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "Service account not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/service-accounts/{uuid}/secret [post]
+// @Security 	 BearerAuth
+func (db *DBController) RotateServiceAccountSecret(c *gin.Context) {
+	ctx := c.Request.Context()
+	secret, hash, err := auth.NewOpaqueToken()
+	if err == nil {
+		err = db.ServiceAccounts.UpdateSecret(ctx, c.Param("uuid"), hash)
+	}
+	var account *model.ServiceAccount
+	if err == nil {
+		err = db.Revoker.RevokeServiceAccount(ctx, c.Param("uuid"))
+	}
+	if err == nil {
+		account, err = db.ServiceAccounts.GetByUUID(ctx, c.Param("uuid"))
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+			return
+		}
+		log.Println("Error rotating service account secret:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate secret"})
+		return
+	}
+
+	response := account.Public()
+	response.ClientSecret = secret
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete a service account
+// @Summary      Delete Service Account (admin)
+// @Description  Remove a service account and revoke its access tokens. Requires service_accounts:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        uuid  path      string  true  "Service account UUID"
+// @Success      200  {string}  "Service account deleted"
+// @Failure      403  {string}  "Missing permission"
+// @Failure      404  {string}  "Service account not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /admin/service-accounts/{uuid} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteServiceAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := db.ServiceAccounts.Delete(ctx, c.Param("uuid"))
+	if err == nil {
+		err = db.Revoker.RevokeServiceAccount(ctx, c.Param("uuid"))
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+			return
+		}
+		log.Println("Error deleting service account:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted"})
+}
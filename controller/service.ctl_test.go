@@ -0,0 +1,42 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceToken gets an access token for a service account.
+func (s *testServer) serviceToken(clientID, secret string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "client_secret": {secret}}
+	return s.call(http.MethodPost, "/api/v1/oauth/token", "", form.Encode(),
+		"Content-Type", "application/x-www-form-urlencoded")
+}
+
+func TestServiceAccountRevocation(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.signup("ada@example.com")
+	s.grant(ada, model.RoleAdmin)
+	admin := s.login("ada@example.com")
+
+	// Repeated, so that tokens issued in the same millisecond as the
+	// revocation come up too
+	for range 20 {
+		body := expect(t, s.call(http.MethodPost, "/api/v1/admin/service-accounts", admin,
+			gin.H{"name": "LMS sync", "permissions": []string{"users:list"}}), http.StatusCreated)
+		uuid, clientID := body["uuid"].(string), body["client_id"].(string)
+
+		old := expect(t, s.serviceToken(clientID, body["client_secret"].(string)), http.StatusOK)["access_token"].(string)
+		body = expect(t, s.call(http.MethodPost, "/api/v1/admin/service-accounts/"+uuid+"/secret", admin, nil), http.StatusOK)
+		expect(t, s.call(http.MethodGet, "/api/v1/users", old, nil), http.StatusUnauthorized)
+
+		current := expect(t, s.serviceToken(clientID, body["client_secret"].(string)), http.StatusOK)["access_token"].(string)
+		expect(t, s.call(http.MethodDelete, "/api/v1/admin/service-accounts/"+uuid, admin, nil), http.StatusOK)
+		expect(t, s.call(http.MethodGet, "/api/v1/users", current, nil), http.StatusUnauthorized)
+	}
+}
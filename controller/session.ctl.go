@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"fiet/middleware"
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List active sessions
+// @Summary      List Sessions
+// @Description  The devices the caller is logged in on, most recently used first. current marks the session of this request.
+// @Tags         session
+// @Produce      json
+// @Success      200  {array}   model.SessionInfo
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Called with an API key"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/sessions [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListSessions(c *gin.Context) {
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	sessions, err := db.Sessions.List(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error listing sessions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := 0
+	if principal := middleware.CurrentPrincipal(c); principal != nil {
+		current = principal.SessionID
+	}
+	response := make([]model.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, session.Public(session.ID == current))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Sign out a session
+// @Summary      Delete Session
+// @Description  Log one of the caller's devices out, e.g. a lost lab computer. Its tokens stop working right away.
+// @Tags         session
+// @Produce      json
+// @Param        id   path      int  true  "Session ID"
+// @Success      200  {string}  "Session signed out"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      403  {string}  "Called with an API key"
+// @Failure      404  {string}  "Session not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/sessions/{id} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	session, err := db.Sessions.Get(ctx, user.ID, id)
+	if err == nil {
+		err = db.endSession(ctx, session)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.Println("Error ending session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out session"})
+		return
+	}
+
+	if principal := middleware.CurrentPrincipal(c); principal != nil && principal.SessionID == id {
+		db.clearTokenCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session signed out"})
+}
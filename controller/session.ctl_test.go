@@ -0,0 +1,44 @@
+package controller_test
+
+import (
+	"net/http"
+	"testing"
+
+	"fiet/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionCookieCSRF(t *testing.T) {
+	s := newTestServer(t)
+	s.ctls.SessionManager.Cookie = true
+	s.signup("ada@example.com")
+
+	w := s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword})
+	expect(t, w, http.StatusOK)
+	var session, csrf string
+	for _, cookie := range w.Result().Cookies() {
+		switch cookie.Name {
+		case auth.SessionCookie:
+			session = cookie.Name + "=" + cookie.Value
+		case auth.CSRFCookie:
+			csrf = cookie.Value
+		}
+	}
+	if session == "" || csrf == "" {
+		t.Fatalf("login set cookies %v, want the session and CSRF cookies", w.Result().Cookies())
+	}
+
+	// Reading needs the cookie alone
+	expect(t, s.call(http.MethodGet, "/api/v1/user", "", nil, "Cookie", session), http.StatusOK)
+
+	// Changes need the CSRF token too, which another site cannot send
+	update := gin.H{"name": "Ada"}
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", "", update, "Cookie", session), http.StatusForbidden)
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", "", update, "Cookie", session, auth.CSRFHeader, "forged"), http.StatusForbidden)
+	expect(t, s.call(http.MethodPost, "/api/v1/logout", "", nil, "Cookie", session), http.StatusForbidden)
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", "", update, "Cookie", session, auth.CSRFHeader, csrf), http.StatusOK)
+
+	expect(t, s.call(http.MethodPost, "/api/v1/logout", "", nil, "Cookie", session, auth.CSRFHeader, csrf), http.StatusOK)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", "", nil, "Cookie", session), http.StatusUnauthorized)
+}
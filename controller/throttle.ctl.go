@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginThrottled writes a 429 and returns true while logins to the
+// account with email, or from the client's IP, are locked. ClientIP only
+// trusts X-Forwarded-For from the proxies set with SetTrustedProxies.
+func (db *DBController) loginThrottled(c *gin.Context, email string) bool {
+	wait, err := db.Throttle.Check(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		// Rather let logins through than lock everyone out
+		log.Println("Error checking login throttle:", err)
+		return false
+	}
+	if wait <= 0 {
+		return false
+	}
+	tooManyAttempts(c, wait)
+	return true
+}
+
+// loginFailed counts a wrong email or password and writes the 401, or a
+// 429 if this failure locked the account or IP.
+func (db *DBController) loginFailed(c *gin.Context, email string) {
+	wait, err := db.Throttle.Fail(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		log.Println("Error recording failed login:", err)
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+}
+
+// loginSucceeded clears the failures of the account once its password was
+// right.
+func (db *DBController) loginSucceeded(c *gin.Context, email string) {
+	if err := db.Throttle.Reset(c.Request.Context(), email); err != nil {
+		log.Println("Error resetting login throttle:", err)
+	}
+}
+
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+}
@@ -0,0 +1,42 @@
+package controller_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"fiet/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.signup("ada@example.com")
+	s.signup("bob@example.com")
+	wrong := gin.H{"email": "ada@example.com", "password": "horse-battery-wrong"}
+
+	policy := s.ctls.Throttle.Account
+	for range policy.MaxFailures - 1 {
+		expect(t, s.call(http.MethodPost, "/api/v1/login", "", wrong), http.StatusUnauthorized)
+	}
+	w := s.call(http.MethodPost, "/api/v1/login", "", wrong)
+	expect(t, w, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry != int(policy.BaseDelay.Seconds()) {
+		t.Errorf("Retry-After = %q, want %v", w.Header().Get("Retry-After"), policy.BaseDelay)
+	}
+
+	// Not even the right password gets in until the lockout ends
+	w = s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword})
+	expect(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("locked login without Retry-After")
+	}
+
+	// The lockout is per account; an admin can lift it
+	s.login("bob@example.com")
+	admin := s.signup("carol@example.com")
+	s.grant(admin, model.RoleAdmin)
+	expect(t, s.call(http.MethodPost, "/api/v1/admin/users/"+ada.UUID+"/unlock", s.login("carol@example.com"), nil), http.StatusOK)
+	s.login("ada@example.com")
+}
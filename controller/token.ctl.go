@@ -0,0 +1,357 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fiet/auth"
+	"fiet/middleware"
+	"fiet/model"
+	"fiet/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const refreshCookiePath = "/api/v1/token"
+
+// Refresh access token
+// @Summary      Refresh Token
+// @Description  Exchange a refresh token for a new access token and a new refresh token. The presented refresh token is revoked; presenting it again revokes every token from the same login.
+// @Tags         token
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.RefreshRequest  false  "Refresh token (or the refresh_token cookie)"
+// @Success      200  {object}  model.TokenResponse
+// @Failure      401  {string}  "Invalid or expired refresh token"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /token/refresh [post]
+func (db *DBController) RefreshToken(c *gin.Context) {
+	var req model.RefreshRequest
+	// The body is optional when the cookie is present
+	_ = c.ShouldBindJSON(&req)
+	if req.RefreshToken == "" {
+		req.RefreshToken, _ = c.Cookie("refresh_token")
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	current, err := db.RefreshTokens.GetByHash(ctx, auth.HashToken(req.RefreshToken))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error fetching refresh token:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedBy != nil {
+			// An already rotated token came back: it was stolen or replayed,
+			// so nothing from this login can be trusted any more.
+			db.revokeFamily(c, current.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	user, err := db.Users.GetByUUID(ctx, current.UserUUID)
+	if err != nil || user.DisabledAt != nil || user.PasswordResetRequired {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	refreshToken, next, err := newRefreshToken(user, current.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	if err := db.RefreshTokens.Rotate(ctx, current, next); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			// Lost a race with another refresh of the same token
+			db.revokeFamily(c, current.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+			return
+		}
+		log.Println("Error rotating refresh token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token refresh failed"})
+		return
+	}
+
+	// The session of the login lasts as long as its refresh tokens
+	sessionID := 0
+	session, err := db.Sessions.GetByFamily(ctx, current.FamilyID)
+	if err == nil {
+		sessionID = session.ID
+		if err := db.Sessions.Touch(ctx, session.ID, time.Now().UTC(), next.ExpiresAt); err != nil {
+			log.Println("Error extending session:", err)
+		}
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching session:", err)
+	}
+
+	accessToken, err := db.accessToken(c, user, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	db.writeTokens(c, accessToken, refreshToken)
+}
+
+// revokeFamily revokes a refresh token family and ends the session of
+// its login.
+func (db *DBController) revokeFamily(c *gin.Context, familyID string) {
+	ctx := c.Request.Context()
+	session, err := db.Sessions.GetByFamily(ctx, familyID)
+	if err == nil {
+		err = db.endSession(ctx, session)
+	} else {
+		// Logins from before sessions were recorded have none
+		err = db.RefreshTokens.RevokeFamily(ctx, familyID)
+	}
+	if err != nil {
+		log.Println("Error revoking refresh token family:", err)
+	}
+}
+
+// endSession signs out one login: the session, its refresh tokens and the
+// access tokens issued for it.
+func (db *DBController) endSession(ctx context.Context, session *model.Session) error {
+	if session.FamilyID != nil {
+		if err := db.RefreshTokens.RevokeFamily(ctx, *session.FamilyID); err != nil {
+			return err
+		}
+		if err := db.Revoker.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return db.Sessions.Delete(ctx, session.ID)
+}
+
+// issueTokens records the login as a session, signs an access token for
+// user, starts a new refresh token family and writes both to the response.
+// In session mode the session cookie replaces the tokens.
+func (db *DBController) issueTokens(c *gin.Context, user *model.User) {
+	if db.SessionManager.Cookie {
+		db.startSession(c, user)
+		return
+	}
+
+	familyID := uuid.New().String()
+	_, _, session, err := db.SessionManager.Create(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP(), &familyID)
+	if err != nil {
+		log.Println("Error creating session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	accessToken, err := db.accessToken(c, user, session.ID)
+	if err != nil {
+		log.Println("Error generating access token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	refreshToken, record, err := newRefreshToken(user, familyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+	if err := db.RefreshTokens.Create(c.Request.Context(), record); err != nil {
+		log.Println("Error storing refresh token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	db.writeTokens(c, accessToken, refreshToken)
+}
+
+// startSession starts a server-side session for user. The session token is
+// only ever in an HttpOnly cookie; the CSRF token is returned in the body
+// and in a cookie the frontend can read.
+func (db *DBController) startSession(c *gin.Context, user *model.User) {
+	token, csrfToken, session, err := db.SessionManager.Create(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP(), nil)
+	if err != nil {
+		log.Println("Error creating session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Session creation failed"})
+		return
+	}
+
+	maxAge := int(db.SessionManager.MaxAge.Seconds())
+	http.SetCookie(c.Writer, db.Cookies.Cookie(auth.SessionCookie, token, "/", maxAge, true))
+	http.SetCookie(c.Writer, db.Cookies.Cookie(auth.CSRFCookie, csrfToken, "/", maxAge, false))
+	c.JSON(http.StatusOK, model.SessionResponse{
+		CSRFToken: csrfToken,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// accessToken signs an access token for the session sessionID carrying the
+// user's current roles and permissions.
+func (db *DBController) accessToken(c *gin.Context, user *model.User, sessionID int) (string, error) {
+	ctx := c.Request.Context()
+	roles, err := db.Roles.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	permissions, err := db.Roles.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return auth.GenerateToken(user.UUID, sessionID, roles, permissions)
+}
+
+// newRefreshToken returns a new refresh token and the record to store for it.
+func newRefreshToken(user *model.User, familyID string) (string, *model.RefreshToken, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &model.RefreshToken{
+		UserID:    user.ID,
+		UserUUID:  user.UUID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(auth.RefreshTokenTTL),
+	}, nil
+}
+
+// writeTokens returns the token pair in the body. The refresh token is
+// also set in an HttpOnly cookie that is only sent to the refresh endpoint;
+// the access token is not, since the API only reads it from the
+// Authorization header.
+func (db *DBController) writeTokens(c *gin.Context, accessToken string, refreshToken string) {
+	http.SetCookie(c.Writer, db.Cookies.Cookie("refresh_token", refreshToken, refreshCookiePath,
+		int(auth.RefreshTokenTTL.Seconds()), true))
+	c.JSON(http.StatusOK, model.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+// Logout
+// @Summary      Logout
+// @Description  End the session of the current login with all its tokens. Tokens from before sessions were recorded revoke the access token and, when given, the refresh token from the same login.
+// @Tags         token
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.RefreshRequest  false  "Refresh token (or the refresh_token cookie)"
+// @Success      200  {string}  "Logged out"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /logout [post]
+// @Security 	 BearerAuth
+func (db *DBController) Logout(c *gin.Context) {
+	userUUID := c.GetString("user_uuid")
+	ctx := c.Request.Context()
+
+	// End the login of the session cookie or access token with all its tokens
+	if principal := middleware.CurrentPrincipal(c); principal != nil && principal.SessionID != 0 {
+		user, ok := db.sessionUser(c)
+		if !ok {
+			return
+		}
+		session, err := db.Sessions.Get(ctx, user.ID, principal.SessionID)
+		if err == nil {
+			err = db.endSession(ctx, session)
+		}
+		switch {
+		case err == nil:
+			db.clearTokenCookies(c)
+			c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+			return
+		case !errors.Is(err, repository.ErrNotFound):
+			log.Println("Error ending session:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+			return
+		}
+		// Already ended; revoke the access token alone
+	}
+
+	if err := db.Revoker.RevokeToken(ctx, c.GetString("jti"), userUUID, c.GetTime("token_exp")); err != nil {
+		log.Println("Error revoking access token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	var req model.RefreshRequest
+	_ = c.ShouldBindJSON(&req)
+	if req.RefreshToken == "" {
+		req.RefreshToken, _ = c.Cookie("refresh_token")
+	}
+	if req.RefreshToken != "" {
+		// Only end the caller's own login
+		token, err := db.RefreshTokens.GetByHash(ctx, auth.HashToken(req.RefreshToken))
+		if err == nil && token.UserUUID == userUUID {
+			db.revokeFamily(c, token.FamilyID)
+		}
+	}
+
+	db.clearTokenCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// Logout everywhere
+// @Summary      Logout All
+// @Description  Revoke every access and refresh token and end every session of the current user
+// @Tags         token
+// @Produce      json
+// @Success      200  {string}  "Logged out from all sessions"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /logout-all [post]
+// @Security 	 BearerAuth
+func (db *DBController) LogoutAll(c *gin.Context) {
+	user, err := db.Users.GetByUUID(c.Request.Context(), c.GetString("user_uuid"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := db.revokeAllSessions(c, user); err != nil {
+		log.Println("Error revoking sessions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	db.clearTokenCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// revokeAllSessions invalidates every access token issued to user so far,
+// all of their refresh tokens and their server-side sessions.
+func (db *DBController) revokeAllSessions(c *gin.Context, user *model.User) error {
+	ctx := c.Request.Context()
+	if err := db.Revoker.RevokeUser(ctx, user.UUID); err != nil {
+		return err
+	}
+	if err := db.RefreshTokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	return db.Sessions.DeleteAllForUser(ctx, user.ID)
+}
+
+func (db *DBController) clearTokenCookies(c *gin.Context) {
+	// Set by older versions, which stored the access token in a cookie
+	c.SetCookie("token", "", -1, "/", "localhost", false, false)
+	http.SetCookie(c.Writer, db.Cookies.Cookie("refresh_token", "", refreshCookiePath, -1, true))
+	if db.SessionManager.Cookie {
+		http.SetCookie(c.Writer, db.Cookies.Cookie(auth.SessionCookie, "", "/", -1, true))
+		http.SetCookie(c.Writer, db.Cookies.Cookie(auth.CSRFCookie, "", "/", -1, false))
+	}
+}
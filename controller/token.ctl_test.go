@@ -0,0 +1,84 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginPair logs in and returns the access and refresh token.
+func (s *testServer) loginPair(email string) (access string, refresh string) {
+	s.t.Helper()
+	body := expect(s.t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": email, "password": testPassword}), http.StatusOK)
+	return body["token"].(string), body["refresh_token"].(string)
+}
+
+// refresh exchanges a refresh token.
+func (s *testServer) refresh(token string) *httptest.ResponseRecorder {
+	return s.call(http.MethodPost, "/api/v1/token/refresh", "", gin.H{"refresh_token": token})
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+	_, first := s.loginPair("ada@example.com")
+	_, other := s.loginPair("ada@example.com")
+
+	body := expect(t, s.refresh(first), http.StatusOK)
+	access, rotated := body["token"].(string), body["refresh_token"].(string)
+	if rotated == first {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	expect(t, s.call(http.MethodGet, "/api/v1/user", access, nil), http.StatusOK)
+
+	// The rotated token coming back means it leaked, so the whole login
+	// ends, including the tokens the thief may have refreshed
+	body = expect(t, s.refresh(first), http.StatusUnauthorized)
+	if body["error"] != "Refresh token reuse detected" {
+		t.Errorf("reuse answered %v", body)
+	}
+	expect(t, s.refresh(rotated), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", access, nil), http.StatusUnauthorized)
+
+	// Other logins of the account go on
+	expect(t, s.refresh(other), http.StatusOK)
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+	access, refresh := s.loginPair("ada@example.com")
+	otherAccess, otherRefresh := s.loginPair("ada@example.com")
+
+	expect(t, s.call(http.MethodPost, "/api/v1/logout", access, gin.H{"refresh_token": refresh}), http.StatusOK)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", access, nil), http.StatusUnauthorized)
+	expect(t, s.refresh(refresh), http.StatusUnauthorized)
+
+	// Only that login ended
+	expect(t, s.call(http.MethodGet, "/api/v1/user", otherAccess, nil), http.StatusOK)
+	expect(t, s.refresh(otherRefresh), http.StatusOK)
+}
+
+func TestLogoutAll(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+	s.signup("bob@example.com")
+	access, refresh := s.loginPair("ada@example.com")
+	otherAccess, otherRefresh := s.loginPair("ada@example.com")
+	bob := s.login("bob@example.com")
+
+	expect(t, s.call(http.MethodPost, "/api/v1/logout-all", access, nil), http.StatusOK)
+	for _, token := range []string{access, otherAccess} {
+		expect(t, s.call(http.MethodGet, "/api/v1/user", token, nil), http.StatusUnauthorized)
+	}
+	for _, token := range []string{refresh, otherRefresh} {
+		expect(t, s.refresh(token), http.StatusUnauthorized)
+	}
+	expect(t, s.call(http.MethodGet, "/api/v1/user", bob, nil), http.StatusOK)
+
+	// The cutoff only covers tokens issued before it, even within the same
+	// millisecond, so logging in again works straight away
+	expect(t, s.call(http.MethodGet, "/api/v1/user", s.login("ada@example.com"), nil), http.StatusOK)
+}
@@ -1,17 +1,19 @@
 package controller
 
 import (
-	"database/sql"
-	"fiet/auth"
+	"errors"
 	"fiet/model"
+	"fiet/password"
+	"fiet/repository"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // @Summary      Create User
@@ -44,24 +46,17 @@ func (db *DBController) CreateUser(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	query := "SELECT id FROM users WHERE email = :email"
-
-	stmt, err := db.Database.PrepareNamed(query)
-	if err != nil {
-		c.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Query preparation failed"})
+	if err := db.PasswordPolicy.Validate(req.Password, req.Email); err != nil {
+		policyError(c, err)
 		return
 	}
-	defer stmt.Close()
-
-	var existingID int
-	err = stmt.Get(&existingID, gin.H{"email": req.Email})
 
+	// Check if user already exists
+	_, err := db.Users.GetByEmail(c.Request.Context(), req.Email)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
-	} else if err != sql.ErrNoRows {
+	} else if !errors.Is(err, repository.ErrNotFound) {
 		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -70,43 +65,39 @@ func (db *DBController) CreateUser(c *gin.Context) {
 	// Generate UUID
 	newUUID := uuid.New().String()
 
-	// Hash password securely (bcrypt)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	// Hash password securely
+	hashedPassword, err := db.PasswordHasher.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password hashing failed"})
 		return
 	}
 
-	// Insert query with named params
-	query = `
-		INSERT INTO users (uuid, email, password_hash)
-		OUTPUT INSERTED.id
-		VALUES (:uuid, :email, :password_hash)
-	`
+	user := model.User{
+		UUID:     newUUID,
+		Email:    req.Email,
+		Password: hashedPassword,
+	}
 
-	// Prepare statement
-	stmt, err = db.Database.PrepareNamed(query)
-	if err != nil {
+	if err := db.Users.Create(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User creation failed"})
 		c.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Query preparation failed"})
 		return
 	}
-	defer stmt.Close()
 
-	// Params to bind
-	params := map[string]interface{}{
-		"uuid":          newUUID,
-		"email":         req.Email,
-		"password_hash": string(hashedPassword),
+	// Every new account starts as a student
+	if err := db.Roles.AssignRole(c.Request.Context(), user.ID, model.RoleStudent); err != nil {
+		c.Error(err)
+		log.Println("Error assigning default role:", err)
 	}
 
-	// Execute and fetch new ID
-	var id int
-	if err := stmt.Get(&id, params); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User creation failed"})
-		c.Error(err)
-		// log.Println("DB Error:", err)
-		return
+	// The account works without it unless RequireVerifiedEmail is set, and
+	// the link can be requested again
+	if err := db.sendEmailVerification(c.Request.Context(), &user); err != nil {
+		log.Println("Error creating verification token:", err)
 	}
 
 	// Success response
@@ -117,14 +108,19 @@ func (db *DBController) CreateUser(c *gin.Context) {
 
 // Login user
 // @Summary      Login User
-// @Description  Authenticate user and return JWT token
+// @Description  Authenticate user and return a JWT access token and a refresh token.
+// @Description  Accounts with a second factor get an MFA token instead, to be completed at /login/mfa.
+// @Description  With AUTH_MODE=session, every login sets the session cookie and returns a model.SessionResponse instead of tokens.
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Param        credentials  body     model.Credential  true  "User Credentials"
 // @Success	  	 200  {object}	model.TokenResponse "Successful login"
+// @Success      202  {object}  model.MFAChallengeResponse "Second factor required"
 // @Failure      400  {string}  "Invalid input"
 // @Failure      401  {string}  "Invalid email or password"
+// @Failure      403  {string}  "Account is disabled, requires a password reset or has an unverified email"
+// @Failure      429  {string}  "Too many failed login attempts; see Retry-After"
 // @Failure      500  {string}  "Internal server error"
 // @Router       /login [post]
 func (db *DBController) Login(c *gin.Context) {
@@ -139,77 +135,163 @@ func (db *DBController) Login(c *gin.Context) {
 		return
 	}
 
+	if db.loginThrottled(c, req.Email) {
+		return
+	}
+
 	// Fetch user by email
-	var user model.User
-	query := "SELECT uuid, password_hash FROM users WHERE email = :email"
-	stmt, err := db.Database.PrepareNamed(query)
+	user, err := db.Users.GetByEmail(c.Request.Context(), req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Query preparation failed"})
+		// An outage is nobody's failed attempt, so it does not count
+		// towards a lockout
+		if !errors.Is(err, repository.ErrNotFound) {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		db.loginFailed(c, req.Email)
 		return
 	}
-	defer stmt.Close()
 
-	if err := stmt.Get(&user, map[string]interface{}{"email": req.Email}); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password."})
-		c.Error(err)
+	// Compare hashed password
+	if !db.PasswordHasher.Verify(req.Password, user.Password) {
+		db.loginFailed(c, req.Email)
 		return
 	}
+	db.loginSucceeded(c, req.Email)
+	db.rehashPassword(c, user, req.Password)
 
-	// Compare hashed password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-		c.Error(err)
+	// Only reveal the account state to someone who knows the password
+	if db.accountBlocked(c, user) {
 		return
 	}
 
-	// uuid := FixUUIDFromSQLServer(user.UUID)
-	uuid := user.UUID
+	// Success response (excluding password), unless a second factor is due
+	db.completeLogin(c, user)
+}
 
-	fmt.Printf("Extracted UUID from JWT: %v (%T)\n", uuid, uuid)
-	// Success response (excluding password)
-	token, err := auth.GenerateToken(uuid)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
-		return
-	}
-	fmt.Println("Generated JWT token:", token)
-	c.SetCookie(
-		"token",     // name
-		token,       // value
-		3600,        // maxAge in seconds (e.g., 1 hour)
-		"/",         // path
-		"localhost", // domain — use frontend domain
-		false,       // secure (true = HTTPS only)
-		false,       // httpOnly (JS can't access it)
-	)
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
-	// c.JSON(http.StatusOK, gin.H{"token": token})
+// accountBlocked writes a 403 response and returns true when user may not
+// log in.
+func (db *DBController) accountBlocked(c *gin.Context, user *model.User) bool {
+	switch {
+	case user.DisabledAt != nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+	case user.PasswordResetRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required"})
+	case db.RequireVerifiedEmail && user.EmailVerifiedAt == nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+	default:
+		return false
+	}
+	return true
 }
 
 // Get all users
-// TODO: Implement pagination and filtering
 // @Summary      Get Users
-// @Description  Retrieve all users
+// @Description  Retrieve a page of users. Requires the users:list permission.
+// @Description  Pages are addressed either by `page` (offset pagination) or by the `cursor`
+// @Description  from the `next` Link of the previous page (keyset pagination).
 // @Tags         user
 // @Accept       json
 // @Produce      json
+// @Param        limit           query  int     false  "Page size (1-100)"  default(20)
+// @Param        page            query  int     false  "Page number, starting at 1; cannot be combined with cursor"
+// @Param        cursor          query  string  false  "Opaque cursor from the next Link of the previous page"
+// @Param        sort            query  string  false  "Sort field, prefixed with - for descending"  Enums(name, -name, email, -email, age, -age, created_at, -created_at, updated_at, -updated_at)  default(created_at)
+// @Param        name            query  string  false  "Case-insensitive substring of the name"
+// @Param        email           query  string  false  "Case-insensitive substring of the email"
+// @Param        min_age         query  int     false  "Minimum age (inclusive)"
+// @Param        max_age         query  int     false  "Maximum age (inclusive)"
+// @Param        created_after   query  string  false  "Created at or after (RFC 3339)"
+// @Param        created_before  query  string  false  "Created before (RFC 3339)"
 // @Success      200  {array}   model.PublicUser
+// @Header       200  {integer} X-Total-Count  "Number of users matching the filters"
+// @Header       200  {string}  Link           "first, prev, next and last page links (RFC 8288)"
+// @Failure      400  {string}  "Invalid query parameters"
+// @Failure      403  {string}  "Missing permission"
 // @Failure      500  {string}  "Internal server error"
 // @Router       /users [get]
 // @Security 	 BearerAuth
 func (db *DBController) GetUsers(c *gin.Context) {
-	var users []model.PublicUser
-	query := `
-	SELECT uuid, name, email, age, created_at, updated_at
-	FROM users
-	`
+	var query model.UserListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page > 0 && query.Cursor != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either page or cursor, not both"})
+		return
+	}
+
+	field := strings.TrimPrefix(query.Sort, "-")
+	if !repository.UserSortFields[field] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot sort by '%s'", field)})
+		return
+	}
 
-	err := db.Database.SelectContext(c.Request.Context(), &users, query)
+	opts := repository.UserListOptions{
+		Filter: repository.UserFilter{
+			Name:          query.Name,
+			Email:         query.Email,
+			MinAge:        query.MinAge,
+			MaxAge:        query.MaxAge,
+			CreatedAfter:  query.CreatedAfter,
+			CreatedBefore: query.CreatedBefore,
+		},
+		Sort: field,
+		Desc: strings.HasPrefix(query.Sort, "-"),
+		// One extra row tells whether there is a next page
+		Limit: query.Limit + 1,
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		opts.After = cursor.After
+	} else if query.Page > 0 {
+		opts.Offset = (query.Page - 1) * query.Limit
+	}
+
+	total, err := db.Users.Count(c.Request.Context(), opts.Filter)
+	if err != nil {
+		log.Println("Error counting users:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	users, err := db.Users.List(c.Request.Context(), opts)
 	if err != nil {
 		log.Println("Error fetching users:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
+	hasNext := len(users) > query.Limit
+	if hasNext {
+		users = users[:query.Limit]
+	}
+
+	links := []pageLink{}
+	if query.Page > 0 {
+		last := max((total+query.Limit-1)/query.Limit, 1)
+		links = append(links, pageLink{Rel: "first", Query: map[string]string{"page": "1"}})
+		if query.Page > 1 {
+			prev := min(query.Page-1, last)
+			links = append(links, pageLink{Rel: "prev", Query: map[string]string{"page": strconv.Itoa(prev)}})
+		}
+		if hasNext {
+			links = append(links, pageLink{Rel: "next", Query: map[string]string{"page": strconv.Itoa(query.Page + 1)}})
+		}
+		links = append(links, pageLink{Rel: "last", Query: map[string]string{"page": strconv.Itoa(last)}})
+	} else {
+		links = append(links, pageLink{Rel: "first", Query: map[string]string{"cursor": ""}})
+		if hasNext {
+			cursor := encodeCursor(pageCursor{Sort: query.Sort, After: users[len(users)-1].UUID})
+			links = append(links, pageLink{Rel: "next", Query: map[string]string{"cursor": cursor}})
+		}
+	}
+	setLinkHeader(c, links)
+	c.Header("X-Total-Count", strconv.Itoa(total))
 
 	// Send the user list as a response
 	c.JSON(http.StatusOK, users)
@@ -241,35 +323,24 @@ func (db *DBController) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	query := `
-	SELECT uuid, name, email, age, created_at, updated_at
-	FROM users
-	WHERE uuid = :uuid
-	`
-
-	var user model.PublicUser
-	stmt, err := db.Database.PrepareNamed(query)
-	if err != nil {
-		log.Println("Error preparing query:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare query"})
-		return
-	}
-	defer stmt.Close()
-
-	// Execute query using UUID
-	err = stmt.Get(&user, map[string]interface{}{"uuid": userUUID})
+	// Fetch user by UUID
+	user, err := db.Users.GetByUUID(c.Request.Context(), userUUID)
 	if err != nil {
 		log.Println("Error fetching user by UUID:", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, user.Public())
 }
 
 // Update user by UUID from JWT
 // @Summary      Update User
-// @Description  Update user details by UUID from JWT
+// @Description  Update user details by UUID from JWT. A new email address has to be verified again.
 // @Tags         user
 // @Accept       json
 // @Produce      json
@@ -290,6 +361,11 @@ func (db *DBController) UpdateUser(c *gin.Context) {
 	}
 	userUUID := userUUIDVal.(string)
 
+	db.updateUser(c, userUUID)
+}
+
+// updateUser applies the whitelisted fields of the JSON body to the user.
+func (db *DBController) updateUser(c *gin.Context, userUUID string) {
 	// Parse incoming JSON into a map
 	var req map[string]interface{}
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -297,86 +373,197 @@ func (db *DBController) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// Whitelist allowed fields
-	allowedFields := map[string]bool{
-		"name":  true,
-		"age":   true,
-		"email": true,
-		// Add more if needed
-	}
-
-	// Build SQL SET clause
-	setClauses := []string{}
-	params := map[string]interface{}{
-		"uuid": userUUID,
-	}
-
+	// Keep only whitelisted fields
+	fields := map[string]interface{}{}
 	for key, value := range req {
-		if allowedFields[key] {
-			setClauses = append(setClauses, fmt.Sprintf("%s = :%s", key, key))
-			params[key] = value
+		if repository.UpdatableUserFields[key] {
+			fields[key] = value
 		}
 	}
 
-	if len(setClauses) == 0 {
+	if len(fields) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid fields to update"})
 		return
 	}
 
-	// Always update updated_at
-	setClauses = append(setClauses, "updated_at = SYSDATETIME()")
-
-	query := fmt.Sprintf("UPDATE users SET %s WHERE uuid = :uuid", strings.Join(setClauses, ", "))
-	result, err := db.Database.NamedExec(query, params)
+	// A changed email has to be verified again, so compare with the current one
+	var email string
+	if value, ok := fields["email"]; ok {
+		var valid bool
+		if email, valid = value.(string); !valid || !validEmail(email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": "email must be a valid email address"})
+			return
+		}
+	}
+	before, err := db.Users.GetByUUID(c.Request.Context(), userUUID)
+	if err == nil {
+		err = db.Users.Update(c.Request.Context(), userUUID, fields)
+	}
 	if err != nil {
 		log.Println("Update error:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, repository.ErrConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		case errors.Is(err, repository.ErrInvalidField):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		}
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
+	if email != "" && email != before.Email {
+		// Links mailed to the old address must not verify the new one
+		for _, purpose := range []string{model.TokenPurposePasswordReset, model.TokenPurposeMagicLink} {
+			if err := db.UserTokens.RevokeAll(c.Request.Context(), before.ID, purpose); err != nil {
+				log.Println("Error revoking", purpose, "tokens:", err)
+			}
+		}
+		before.Email = email
+		if err := db.sendEmailVerification(c.Request.Context(), before); err != nil {
+			log.Println("Error creating verification token:", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
-// Delete user by ID
+// Delete user by UUID from JWT
+// @Summary      Delete User
+// @Description  Delete the account of the caller and revoke all of its sessions. The account can be restored with POST /user/restore until the grace period has passed; after that its personal data is wiped.
+// @Tags         user
+// @Produce      json
+// @Success      200  {string}  "User deleted successfully"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Failed to delete user"
+// @Router       /user [delete]
+// @Security 	 BearerAuth
 func (db *DBController) DeleteUserByID(c *gin.Context) {
-	// Get user ID from URL parameter
-	id := c.Param("id")
+	// Get user UUID from JWT
+	userUUIDVal, exists := c.Get("user_uuid")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User UUID not found"})
+		return
+	}
+	userUUID := userUUIDVal.(string)
+
+	user, err := db.Users.GetByUUID(c.Request.Context(), userUUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Println("Error fetching user by UUID:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
 
-	query := "DELETE FROM dbo.users WHERE id = :id"
+	db.deleteUser(c, user)
+}
 
-	// Execute delete query
-	result, err := db.Database.NamedExec(query, map[string]interface{}{"id": id})
+// deleteUser revokes every session of user and soft-deletes the account.
+func (db *DBController) deleteUser(c *gin.Context, user *model.User) {
+	// Tokens must not outlive the account
+	err := db.revokeAllSessions(c, user)
+	if err == nil {
+		err = db.Users.Delete(c.Request.Context(), user.UUID)
+	}
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
 		log.Println("Delete error:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	// Check if any rows were actually deleted
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	// Send success response
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "User deleted successfully",
+		"restore_until": time.Now().UTC().Add(db.DeletionGracePeriod),
+	})
+}
+
+// Restore a deleted account
+// @Summary      Restore User
+// @Description  Undo the deletion of an account within the grace period and log in
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        credentials  body     model.Credential  true  "User Credentials"
+// @Success      200  {object}  model.TokenResponse
+// @Success      202  {object}  model.MFAChallengeResponse "Second factor required"
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid email or password"
+// @Failure      403  {string}  "Account is disabled or requires a password reset"
+// @Failure      410  {string}  "Restore period has expired"
+// @Failure      429  {string}  "Too many failed login attempts; see Retry-After"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/restore [post]
+func (db *DBController) RestoreUser(c *gin.Context) {
+	var req model.Credential
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
 		return
 	}
 
-	// Send success response
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	if db.loginThrottled(c, req.Email) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := db.Users.GetDeletedByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		db.loginFailed(c, req.Email)
+		return
+	}
+	if !db.PasswordHasher.Verify(req.Password, user.Password) {
+		db.loginFailed(c, req.Email)
+		return
+	}
+	db.loginSucceeded(c, req.Email)
+	db.rehashPassword(c, user, req.Password)
+
+	if err := db.Users.Restore(ctx, user.UUID, time.Now().UTC().Add(-db.DeletionGracePeriod)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusGone, gin.H{"error": "Restore period has expired"})
+			return
+		}
+		log.Println("Restore error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	if db.accountBlocked(c, user) {
+		return
+	}
+	db.completeLogin(c, user)
 }
 
 // Change user password
+// @Summary      Change Password
+// @Description  Change the password of the caller. The new password must satisfy the password policy and differ from the recent passwords. Every session is signed out and a new token pair is returned for this one.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.ChangePasswordRequest  true  "Current and new password"
+// @Success      200  {object}  model.TokenResponse
+// @Failure      400  {string}  "Invalid input or the new password breaks the policy"
+// @Failure      401  {string}  "Incorrect current password"
+// @Failure      404  {string}  "User not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/password [post]
+// @Security 	 BearerAuth
 func (db *DBController) ChangePassword(c *gin.Context) {
-	// Change user password
-	type ChangePasswordRequest struct {
-		CurrentPassword string `json:"current_password"`
-		NewPassword     string `json:"new_password"`
-	}
-
 	// Get user UUID from JWT
 	userUUIDVal, exists := c.Get("user_uuid")
 	if !exists {
@@ -386,39 +573,126 @@ func (db *DBController) ChangePassword(c *gin.Context) {
 	userUUID := userUUIDVal.(string)
 
 	// Parse JSON input
-	var req ChangePasswordRequest
+	var req model.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
 		return
 	}
 
 	// Fetch existing password hash
-	var storedHash string
-	err := db.Database.Get(&storedHash, "SELECT password_hash FROM users WHERE uuid = ?", userUUID)
+	user, err := db.Users.GetByUUID(c.Request.Context(), userUUID)
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
 		return
 	}
 
 	// Compare current password with stored hash
-	if err := bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(req.CurrentPassword)); err != nil {
+	if !db.PasswordHasher.Verify(req.CurrentPassword, user.Password) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect current password"})
 		return
 	}
 
+	if !db.checkNewPassword(c, user, req.NewPassword) || !db.storePassword(c, user, req.NewPassword) {
+		return
+	}
+
+	// Tokens issued with the old password are no longer valid; this
+	// session continues with a fresh pair.
+	if err := db.revokeAllSessions(c, user); err != nil {
+		log.Println("Error revoking sessions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	db.issueTokens(c, user)
+}
+
+// checkNewPassword checks newPassword against the policy and the password
+// history of user. It writes the error response and returns false if the
+// password is rejected.
+func (db *DBController) checkNewPassword(c *gin.Context, user *model.User, newPassword string) bool {
+	if err := db.PasswordPolicy.Validate(newPassword, user.Email); err != nil {
+		policyError(c, err)
+		return false
+	}
+
+	// The current password counts towards the history
+	previous, err := db.Passwords.Recent(c.Request.Context(), user.ID, db.PasswordPolicy.History-1)
+	if err != nil {
+		log.Println("Error fetching password history:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return false
+	}
+	for _, hash := range append([]string{user.Password}, previous...) {
+		if db.PasswordHasher.Verify(newPassword, hash) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password was used recently"})
+			return false
+		}
+	}
+	return true
+}
+
+// storePassword hashes and saves newPassword, which must have passed
+// checkNewPassword, and moves the old hash into the history. It writes the
+// error response and returns false if that fails.
+func (db *DBController) storePassword(c *gin.Context, user *model.User, newPassword string) bool {
+	ctx := c.Request.Context()
+
 	// Hash new password
-	newHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	newHash, err := db.PasswordHasher.Hash(newPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash new password"})
-		return
+		return false
 	}
 
 	// Update password in DB
-	_, err = db.Database.Exec("UPDATE users SET password_hash = ?, updated_at = SYSDATETIME() WHERE uuid = ?", newHash, userUUID)
-	if err != nil {
+	if err := db.Users.UpdatePassword(ctx, user.UUID, newHash); err != nil {
+		log.Println("Error updating password:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return false
+	}
+
+	// Remember the old hash; a failure here only weakens the history
+	if err := db.Passwords.Add(ctx, user.ID, user.Password); err != nil {
+		log.Println("Error saving password history:", err)
+	} else if err := db.Passwords.Trim(ctx, user.ID, max(db.PasswordPolicy.History-1, 0)); err != nil {
+		log.Println("Error trimming password history:", err)
+	}
+
+	user.Password = newHash
+	user.PasswordResetRequired = false
+	return true
+}
+
+// rehashPassword upgrades the hash of user, whose password was just
+// verified, when it uses an outdated algorithm or cost. The login goes on
+// with the old hash if that fails.
+func (db *DBController) rehashPassword(c *gin.Context, user *model.User, password string) {
+	if !db.PasswordHasher.NeedsRehash(user.Password) {
+		return
+	}
+	newHash, err := db.PasswordHasher.Hash(password)
+	if err != nil {
+		log.Println("Error rehashing password:", err)
 		return
 	}
+	// Not UpdatePassword: that would lift a required password reset
+	if err := db.Users.RehashPassword(c.Request.Context(), user.UUID, user.Password, newHash); err != nil {
+		log.Println("Error saving rehashed password:", err)
+		return
+	}
+	user.Password = newHash
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+// policyError writes the rules a rejected password breaks.
+func policyError(c *gin.Context, err error) {
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the policy", "details": policyErr.Violations})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid password"})
 }
@@ -0,0 +1,192 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t)
+
+	s.signup("ada@example.com")
+	s.waitMail("ada@example.com", "Verify your email")
+
+	tests := []struct {
+		name string
+		body any
+		code int
+	}{
+		{"duplicate", gin.H{"email": "ada@example.com", "password": testPassword}, http.StatusConflict},
+		{"invalid email", gin.H{"email": "ada", "password": testPassword}, http.StatusBadRequest},
+		{"missing password", gin.H{"email": "bob@example.com"}, http.StatusBadRequest},
+		{"weak password", gin.H{"email": "bob@example.com", "password": "short"}, http.StatusBadRequest},
+		{"not json", "email=bob", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, s.call(http.MethodPost, "/api/v1/signup", "", tt.body), tt.code)
+		})
+	}
+
+	user, err := s.store.Users.GetByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == testPassword || !s.ctls.PasswordHasher.Verify(testPassword, user.Password) {
+		t.Error("password is not stored as a hash of the password")
+	}
+	roles, err := s.store.Roles.GetUserRoles(context.Background(), user.ID)
+	if err != nil || len(roles) != 1 || roles[0] != model.RoleStudent {
+		t.Errorf("roles = %v, %v; want [student]", roles, err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+
+	body := expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword}), http.StatusOK)
+	if body["token"] == "" || body["refresh_token"] == "" {
+		t.Errorf("login response without tokens: %v", body)
+	}
+
+	tests := []struct {
+		name string
+		body any
+		code int
+	}{
+		{"wrong password", gin.H{"email": "ada@example.com", "password": "horse-battery-wrong"}, http.StatusUnauthorized},
+		{"unknown email", gin.H{"email": "bob@example.com", "password": testPassword}, http.StatusUnauthorized},
+		{"invalid input", gin.H{"email": "ada@example.com"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := expect(t, s.call(http.MethodPost, "/api/v1/login", "", tt.body), tt.code)
+			if body["token"] != nil {
+				t.Errorf("failed login returned a token: %v", body)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s.signup("eve@example.com")
+		user, _ := s.store.Users.GetByEmail(context.Background(), "eve@example.com")
+		if err := s.store.Users.SetDisabled(context.Background(), user.UUID, true); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "eve@example.com", "password": testPassword}), http.StatusForbidden)
+	})
+}
+
+// downUsers fails lookups by email like a database that is down.
+type downUsers struct{ repository.UserRepository }
+
+func (downUsers) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestLoginDuringOutage(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+
+	users := s.store.Users
+	s.store.Users = downUsers{users}
+	for range 2 * s.ctls.Throttle.Account.MaxFailures {
+		expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword}), http.StatusInternalServerError)
+	}
+	s.store.Users = users
+
+	// None of it counted as a failed login
+	if _, err := s.store.LoginAttempts.Get(context.Background(), "account:ada@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("failures recorded during the outage: %v", err)
+	}
+	s.login("ada@example.com")
+}
+
+func TestGetUser(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+
+	body := expect(t, s.call(http.MethodGet, "/api/v1/user", token, nil), http.StatusOK)
+	if body["uuid"] != user.UUID || body["email"] != "ada@example.com" {
+		t.Errorf("GET /user = %v", body)
+	}
+	if body["password"] != nil {
+		t.Error("GET /user exposes the password hash")
+	}
+
+	expect(t, s.call(http.MethodGet, "/api/v1/user", "", nil), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", "not-a-jwt", nil), http.StatusUnauthorized)
+}
+
+func TestUpdateUser(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+	s.signup("bob@example.com")
+	token := s.login("ada@example.com")
+	ctx := context.Background()
+
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", token, gin.H{"name": "Ada", "age": 36, "password_hash": "x"}), http.StatusOK)
+	updated, _ := s.store.Users.GetByUUID(ctx, user.UUID)
+	if updated.Name == nil || *updated.Name != "Ada" || updated.Age == nil || *updated.Age != 36 {
+		t.Errorf("name and age not updated: %+v", updated)
+	}
+	if updated.Password != user.Password {
+		t.Error("PATCH /user changed a field that is not whitelisted")
+	}
+
+	tests := []struct {
+		name string
+		body any
+		code int
+	}{
+		{"no updatable fields", gin.H{"uuid": "x"}, http.StatusBadRequest},
+		{"invalid email", gin.H{"email": "Ada <ada@example.com>"}, http.StatusBadRequest},
+		{"email taken", gin.H{"email": "bob@example.com"}, http.StatusConflict},
+		{"not json", "name=Ada", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, s.call(http.MethodPatch, "/api/v1/user", token, tt.body), tt.code)
+		})
+	}
+	expect(t, s.call(http.MethodPatch, "/api/v1/user", "", gin.H{"name": "Eve"}), http.StatusUnauthorized)
+
+	t.Run("email change", func(t *testing.T) {
+		if err := s.store.Users.MarkEmailVerified(ctx, user.UUID); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, s.call(http.MethodPatch, "/api/v1/user", token, gin.H{"email": "ada@example.org"}), http.StatusOK)
+		updated, _ := s.store.Users.GetByUUID(ctx, user.UUID)
+		if updated.Email != "ada@example.org" || updated.EmailVerifiedAt != nil {
+			t.Errorf("email = %s, verified at %v; want the new email unverified", updated.Email, updated.EmailVerifiedAt)
+		}
+		s.waitMail("ada@example.org", "Verify your email")
+	})
+}
+
+func TestPurgeForgetsLoginAttempts(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("Ada@example.com")
+	token := s.login("Ada@example.com")
+	ctx := context.Background()
+
+	expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": "horse-battery-wrong"}), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodDelete, "/api/v1/user", token, nil), http.StatusOK)
+	if purged, err := s.store.Users.Purge(ctx, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("Purge = %d, %v; want 1 user", purged, err)
+	}
+
+	if _, err := s.store.LoginAttempts.Get(ctx, "account:ada@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("failed logins of a purged account kept: %v", err)
+	}
+}
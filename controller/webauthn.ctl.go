@@ -0,0 +1,486 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fiet/auth"
+	"fiet/model"
+	"fiet/repository"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// webAuthnCeremonyTTL is how long the browser has between the begin and
+// finish requests of a ceremony.
+const webAuthnCeremonyTTL = 5 * time.Minute
+
+// webAuthnCeremony is what is kept in WebAuthnSession.Data.
+type webAuthnCeremony struct {
+	Session webauthn.SessionData `json:"session"`
+	// Name of the credential being registered
+	Name string `json:"name,omitempty"`
+}
+
+// Start registering a passkey
+// @Summary      Begin Passkey Registration
+// @Description  Return the options for navigator.credentials.create(). Pass the session token and the created credential to the finish endpoint.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.WebAuthnRegisterRequest  true  "Current password and a name for the passkey"
+// @Success      200  {object}  model.WebAuthnBeginResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Incorrect password"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/webauthn/register/begin [post]
+// @Security 	 BearerAuth
+func (db *DBController) BeginWebAuthnRegistration(c *gin.Context) {
+	var req model.WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok || !db.confirmPassword(c, user, req.Password) {
+		return
+	}
+	webAuthnUser, ok := db.webAuthnUser(c, user)
+	if !ok {
+		return
+	}
+
+	options, session, err := db.RelyingParty.BeginRegistration(webAuthnUser,
+		webauthn.WithExclusions(webAuthnUser.CredentialExclusions()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		log.Println("Error starting WebAuthn registration:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start registration"})
+		return
+	}
+	db.startCeremony(c, model.WebAuthnRegistration, &user.ID, webAuthnCeremony{Session: *session, Name: req.Name}, options)
+}
+
+// Finish registering a passkey
+// @Summary      Finish Passkey Registration
+// @Description  Verify the credential from navigator.credentials.create() and store it. The passkey can then be used to log in and as a second factor.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.WebAuthnFinishRequest  true  "Session token and the created credential"
+// @Success      201  {object}  model.WebAuthnCredential
+// @Failure      400  {string}  "Invalid input or credential"
+// @Failure      401  {string}  "Invalid or expired WebAuthn session"
+// @Failure      409  {string}  "Passkey already registered"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/webauthn/register/finish [post]
+// @Security 	 BearerAuth
+func (db *DBController) FinishWebAuthnRegistration(c *gin.Context) {
+	var req model.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	ceremony, ok := db.takeCeremony(c, model.WebAuthnRegistration, req.SessionToken, user)
+	if !ok {
+		return
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential", "details": protocolDetails(err)})
+		return
+	}
+	webAuthnUser, ok := db.webAuthnUser(c, user)
+	if !ok {
+		return
+	}
+
+	credential, err := db.RelyingParty.CreateCredential(webAuthnUser, ceremony.Session, parsed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential", "details": protocolDetails(err)})
+		return
+	}
+	stored := auth.NewWebAuthnCredential(user.ID, ceremony.Name, credential)
+	if err := db.WebAuthn.CreateCredential(c.Request.Context(), stored); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Passkey already registered"})
+			return
+		}
+		log.Println("Error storing WebAuthn credential:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register passkey"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, stored)
+}
+
+// List passkeys
+// @Summary      List Passkeys
+// @Description  The passkeys and security keys registered by the caller.
+// @Tags         webauthn
+// @Produce      json
+// @Success      200  {array}   model.WebAuthnCredential
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/webauthn/credentials [get]
+// @Security 	 BearerAuth
+func (db *DBController) ListWebAuthnCredentials(c *gin.Context) {
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	credentials, err := db.WebAuthn.ListCredentials(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error listing WebAuthn credentials:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return
+	}
+	c.JSON(http.StatusOK, credentials)
+}
+
+// Remove a passkey
+// @Summary      Delete Passkey
+// @Description  Remove one of the caller's passkeys.
+// @Tags         webauthn
+// @Produce      json
+// @Param        id   path      int  true  "Passkey ID"
+// @Success      200  {string}  "Passkey deleted"
+// @Failure      401  {string}  "Unauthorized"
+// @Failure      404  {string}  "Passkey not found"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /user/webauthn/credentials/{id} [delete]
+// @Security 	 BearerAuth
+func (db *DBController) DeleteWebAuthnCredential(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+		return
+	}
+	user, ok := db.sessionUser(c)
+	if !ok {
+		return
+	}
+	if err := db.WebAuthn.DeleteCredential(c.Request.Context(), user.ID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+			return
+		}
+		log.Println("Error deleting WebAuthn credential:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted"})
+}
+
+// Start a passkey login
+// @Summary      Begin Passkey Login
+// @Description  Return the options for navigator.credentials.get(). The browser offers the passkeys it holds for this site, so no email is needed.
+// @Tags         webauthn
+// @Produce      json
+// @Success      200  {object}  model.WebAuthnBeginResponse
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/webauthn/begin [post]
+func (db *DBController) BeginWebAuthnLogin(c *gin.Context) {
+	// User verification makes the passkey count as both factors
+	options, session, err := db.RelyingParty.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		log.Println("Error starting WebAuthn login:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	db.startCeremony(c, model.WebAuthnLogin, nil, webAuthnCeremony{Session: *session}, options)
+}
+
+// Finish a passkey login
+// @Summary      Finish Passkey Login
+// @Description  Verify the assertion from navigator.credentials.get() and return a token pair. No password or further MFA is needed.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.WebAuthnFinishRequest  true  "Session token and the assertion"
+// @Success      200  {object}  model.TokenResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired WebAuthn session, or the passkey was not accepted"
+// @Failure      403  {string}  "Account is disabled, requires a password reset or has an unverified email"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/webauthn/finish [post]
+func (db *DBController) FinishWebAuthnLogin(c *gin.Context) {
+	var req model.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	ceremony, ok := db.takeCeremony(c, model.WebAuthnLogin, req.SessionToken, nil)
+	if !ok {
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(req.Credential))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey not accepted", "details": protocolDetails(err)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var webAuthnUser *auth.WebAuthnUser
+	credential, err := db.RelyingParty.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		user, err := db.Users.GetByUUID(ctx, string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		credentials, err := db.WebAuthn.ListCredentials(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		webAuthnUser = &auth.WebAuthnUser{User: user, Credentials: credentials}
+		return webAuthnUser, nil
+	}, ceremony.Session, parsed)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey not accepted", "details": protocolDetails(err)})
+		return
+	}
+	if !db.recordWebAuthnUse(c, webAuthnUser, credential) || db.accountBlocked(c, webAuthnUser.User) {
+		return
+	}
+
+	db.issueTokens(c, webAuthnUser.User)
+}
+
+// Start a passkey MFA challenge
+// @Summary      Begin Passkey MFA
+// @Description  Return the options for navigator.credentials.get() to answer the MFA challenge from login with one of the user's passkeys.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.WebAuthnMFABeginRequest  true  "MFA token from login"
+// @Success      200  {object}  model.WebAuthnBeginResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired MFA token"
+// @Failure      409  {string}  "No passkeys registered"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/mfa/webauthn/begin [post]
+func (db *DBController) BeginWebAuthnMFA(c *gin.Context) {
+	var req model.WebAuthnMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	_, user, ok := db.mfaChallenge(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	webAuthnUser, ok := db.webAuthnUser(c, user)
+	if !ok {
+		return
+	}
+	if len(webAuthnUser.Credentials) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No passkeys registered"})
+		return
+	}
+
+	options, session, err := db.RelyingParty.BeginLogin(webAuthnUser)
+	if err != nil {
+		log.Println("Error starting WebAuthn MFA:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	db.startCeremony(c, model.WebAuthnMFA, &user.ID, webAuthnCeremony{Session: *session}, options)
+}
+
+// Finish a passkey MFA challenge
+// @Summary      Finish Passkey MFA
+// @Description  Verify the assertion from navigator.credentials.get() and exchange the MFA token for a token pair. The MFA token is discarded after too many failures.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        body  body     model.WebAuthnFinishRequest  true  "Session token, MFA token and the assertion"
+// @Success      200  {object}  model.TokenResponse
+// @Failure      400  {string}  "Invalid input"
+// @Failure      401  {string}  "Invalid or expired MFA token or WebAuthn session, or the passkey was not accepted"
+// @Failure      403  {string}  "Account is disabled, requires a password reset or has an unverified email"
+// @Failure      500  {string}  "Internal server error"
+// @Router       /login/mfa/webauthn/finish [post]
+func (db *DBController) FinishWebAuthnMFA(c *gin.Context) {
+	var req model.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if req.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": "mfa_token is required"})
+		return
+	}
+	token, user, ok := db.mfaChallenge(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	ceremony, ok := db.takeCeremony(c, model.WebAuthnMFA, req.SessionToken, user)
+	if !ok {
+		return
+	}
+	webAuthnUser, ok := db.webAuthnUser(c, user)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(req.Credential))
+	var credential *webauthn.Credential
+	if err == nil {
+		credential, err = db.RelyingParty.ValidateLogin(webAuthnUser, ceremony.Session, parsed)
+	}
+	if err != nil {
+		if err := db.UserTokens.Fail(ctx, token.ID, maxMFAAttempts); err != nil {
+			log.Println("Error counting MFA attempt:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey not accepted", "details": protocolDetails(err)})
+		return
+	}
+	if !db.recordWebAuthnUse(c, webAuthnUser, credential) {
+		return
+	}
+	if err := db.UserTokens.Use(ctx, token.ID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	db.issueTokens(c, user)
+}
+
+// mfaChallenge looks up the MFA token from login and its user. It writes
+// the error response and returns false if that fails.
+func (db *DBController) mfaChallenge(c *gin.Context, mfaToken string) (*model.UserToken, *model.User, bool) {
+	ctx := c.Request.Context()
+	token, err := db.UserTokens.GetValid(ctx, model.TokenPurposeMFAChallenge, auth.HashToken(mfaToken))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error fetching MFA challenge:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return nil, nil, false
+	}
+	user, err := db.Users.GetByUUID(ctx, token.UserUUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return nil, nil, false
+	}
+	// The account may have changed since the password was checked
+	if db.accountBlocked(c, user) {
+		return nil, nil, false
+	}
+	return token, user, true
+}
+
+// webAuthnUser loads the registered credentials of user. It writes the
+// error response and returns false if that fails.
+func (db *DBController) webAuthnUser(c *gin.Context, user *model.User) (*auth.WebAuthnUser, bool) {
+	credentials, err := db.WebAuthn.ListCredentials(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Println("Error listing WebAuthn credentials:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return nil, false
+	}
+	return &auth.WebAuthnUser{User: user, Credentials: credentials}, true
+}
+
+// startCeremony stores the state of a ceremony and responds with the
+// options for the browser and the token to finish it with.
+func (db *DBController) startCeremony(c *gin.Context, purpose string, userID *int, ceremony webAuthnCeremony, options interface{}) {
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		log.Println("Error encoding WebAuthn session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start WebAuthn ceremony"})
+		return
+	}
+	token, hash, err := auth.NewOpaqueToken()
+	if err == nil {
+		err = db.WebAuthn.CreateSession(c.Request.Context(), &model.WebAuthnSession{
+			TokenHash: hash,
+			Purpose:   purpose,
+			UserID:    userID,
+			Data:      string(data),
+			ExpiresAt: time.Now().UTC().Add(webAuthnCeremonyTTL),
+		})
+	}
+	if err != nil {
+		log.Println("Error storing WebAuthn session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start WebAuthn ceremony"})
+		return
+	}
+	c.JSON(http.StatusOK, model.WebAuthnBeginResponse{SessionToken: token, Options: options})
+}
+
+// takeCeremony consumes the ceremony started for purpose, which must
+// belong to user unless user is nil. It writes the error response and
+// returns false if that fails.
+func (db *DBController) takeCeremony(c *gin.Context, purpose string, token string, user *model.User) (*webAuthnCeremony, bool) {
+	session, err := db.WebAuthn.TakeSession(c.Request.Context(), purpose, auth.HashToken(token))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Error fetching WebAuthn session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch WebAuthn session"})
+		return nil, false
+	}
+	if err != nil || (user != nil && (session.UserID == nil || *session.UserID != user.ID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired WebAuthn session"})
+		return nil, false
+	}
+
+	var ceremony webAuthnCeremony
+	if err := json.Unmarshal([]byte(session.Data), &ceremony); err != nil {
+		log.Println("Error decoding WebAuthn session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch WebAuthn session"})
+		return nil, false
+	}
+	return &ceremony, true
+}
+
+// recordWebAuthnUse stores the new signature counter of the credential
+// a user logged in with. A counter that went backwards means the
+// authenticator was probably cloned, so the login is refused. It writes
+// the error response and returns false if the login cannot go on.
+func (db *DBController) recordWebAuthnUse(c *gin.Context, user *auth.WebAuthnUser, credential *webauthn.Credential) bool {
+	if credential.Authenticator.CloneWarning {
+		log.Printf("WebAuthn signature counter went backwards for user %s", user.User.UUID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey not accepted"})
+		return false
+	}
+
+	id := auth.WebAuthnCredentialID(credential.ID)
+	for _, stored := range user.Credentials {
+		if stored.CredentialID != id {
+			continue
+		}
+		err := db.WebAuthn.UpdateCredentialUse(c.Request.Context(), stored.ID,
+			int64(credential.Authenticator.SignCount), credential.Flags.BackupState)
+		if err != nil {
+			log.Println("Error updating WebAuthn credential:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+			return false
+		}
+		return true
+	}
+	// The library only accepts credentials of the user
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey not accepted"})
+	return false
+}
+
+// protocolDetails is the most specific description of a WebAuthn error.
+func protocolDetails(err error) string {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		return protocolErr.Details + ": " + protocolErr.DevInfo
+	}
+	return err.Error()
+}
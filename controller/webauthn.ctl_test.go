@@ -0,0 +1,332 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"fiet/model"
+	"fiet/repository"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// softAuthenticator is a passkey in software: a P-256 key that answers
+// the options of a ceremony the way a browser and authenticator would.
+type softAuthenticator struct {
+	t          *testing.T
+	id         []byte
+	key        *ecdsa.PrivateKey
+	counter    uint32
+	userHandle []byte
+	// Origin is reported in the client data
+	Origin string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, id: id, key: key, Origin: testAppURL}
+}
+
+// create answers the options of a registration ceremony with a new
+// credential, attested with the "none" format.
+func (a *softAuthenticator) create(options map[string]any) json.RawMessage {
+	publicKey := options["publicKey"].(map[string]any)
+	handle, err := base64.RawURLEncoding.DecodeString(publicKey["user"].(map[string]any)["id"].(string))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	a.userHandle = handle
+
+	// COSE_Key of an EC2 P-256 key for ES256
+	coseKey, err := cbor.Marshal(map[int]any{
+		1: 2, 3: -7, -1: 1,
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttested)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, coseKey...)
+	attestation, err := cbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": authData})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    a.encode(a.clientData("webauthn.create", publicKey["challenge"].(string))),
+		"attestationObject": a.encode(attestation),
+	})
+}
+
+// get answers the options of a login ceremony with an assertion.
+func (a *softAuthenticator) get(options map[string]any, flags byte) json.RawMessage {
+	a.counter++
+	authData := a.authData(flags)
+	clientData := a.clientData("webauthn.get", options["publicKey"].(map[string]any)["challenge"].(string))
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    a.encode(clientData),
+		"authenticatorData": a.encode(authData),
+		"signature":         a.encode(signature),
+		"userHandle":        a.encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte("app.test"))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": a.Origin})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) credential(response map[string]string) json.RawMessage {
+	data, err := json.Marshal(map[string]any{
+		"id":       a.encode(a.id),
+		"rawId":    a.encode(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// expiredCeremonies stores WebAuthn ceremonies that have already expired.
+type expiredCeremonies struct {
+	repository.WebAuthnRepository
+}
+
+func (r expiredCeremonies) CreateSession(ctx context.Context, session *model.WebAuthnSession) error {
+	session.ExpiresAt = time.Now().Add(-time.Second)
+	return r.WebAuthnRepository.CreateSession(ctx, session)
+}
+
+// begin starts a ceremony and returns its session token and options.
+func (s *testServer) begin(path, token string, body any) (string, map[string]any) {
+	s.t.Helper()
+	response := expect(s.t, s.call(http.MethodPost, path, token, body), http.StatusOK)
+	return response["session_token"].(string), response["options"].(map[string]any)
+}
+
+// registerPasskey registers a new software passkey for the caller.
+func (s *testServer) registerPasskey(token string) *softAuthenticator {
+	s.t.Helper()
+	passkey := newSoftAuthenticator(s.t)
+	session, options := s.begin("/api/v1/user/webauthn/register/begin", token, gin.H{"password": testPassword, "name": "Laptop"})
+	expect(s.t, s.call(http.MethodPost, "/api/v1/user/webauthn/register/finish", token,
+		gin.H{"session_token": session, "credential": passkey.create(options)}), http.StatusCreated)
+	return passkey
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+	token := s.login("ada@example.com")
+	const begin, finish = "/api/v1/user/webauthn/register/begin", "/api/v1/user/webauthn/register/finish"
+
+	expect(t, s.call(http.MethodPost, begin, token, gin.H{"password": "wrong-password", "name": "Laptop"}), http.StatusUnauthorized)
+
+	passkey := newSoftAuthenticator(t)
+	session, options := s.begin(begin, token, gin.H{"password": testPassword, "name": "Laptop"})
+	credential := passkey.create(options)
+	body := expect(t, s.call(http.MethodPost, finish, token, gin.H{"session_token": session, "credential": credential}), http.StatusCreated)
+	if body["name"] != "Laptop" {
+		t.Errorf("registered credential = %v", body)
+	}
+
+	t.Run("replayed ceremony", func(t *testing.T) {
+		expect(t, s.call(http.MethodPost, finish, token, gin.H{"session_token": session, "credential": credential}), http.StatusUnauthorized)
+	})
+
+	t.Run("expired ceremony", func(t *testing.T) {
+		store := s.store.WebAuthn
+		s.store.WebAuthn = expiredCeremonies{store}
+		defer func() { s.store.WebAuthn = store }()
+
+		session, options := s.begin(begin, token, gin.H{"password": testPassword, "name": "Phone"})
+		credential := newSoftAuthenticator(t).create(options)
+		expect(t, s.call(http.MethodPost, finish, token, gin.H{"session_token": session, "credential": credential}), http.StatusUnauthorized)
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		phishing := newSoftAuthenticator(t)
+		phishing.Origin = "https://app.test.example.com"
+		session, options := s.begin(begin, token, gin.H{"password": testPassword, "name": "Phone"})
+		expect(t, s.call(http.MethodPost, finish, token, gin.H{"session_token": session, "credential": phishing.create(options)}), http.StatusBadRequest)
+	})
+
+	t.Run("already registered", func(t *testing.T) {
+		session, options := s.begin(begin, token, gin.H{"password": testPassword, "name": "Laptop"})
+		expect(t, s.call(http.MethodPost, finish, token, gin.H{"session_token": session, "credential": passkey.create(options)}), http.StatusConflict)
+	})
+
+	t.Run("ceremony of another user", func(t *testing.T) {
+		s.signup("eve@example.com")
+		other := s.login("eve@example.com")
+		session, options := s.begin(begin, token, gin.H{"password": testPassword, "name": "Phone"})
+		credential := newSoftAuthenticator(t).create(options)
+		expect(t, s.call(http.MethodPost, finish, other, gin.H{"session_token": session, "credential": credential}), http.StatusUnauthorized)
+	})
+
+	var credentials []map[string]any
+	if err := json.Unmarshal(s.call(http.MethodGet, "/api/v1/user/webauthn/credentials", token, nil).Body.Bytes(), &credentials); err != nil {
+		t.Fatal(err)
+	}
+	if len(credentials) != 1 {
+		t.Errorf("got %d credentials, want 1: %v", len(credentials), credentials)
+	}
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup("ada@example.com")
+	passkey := s.registerPasskey(s.login("ada@example.com"))
+	const begin, finish = "/api/v1/login/webauthn/begin", "/api/v1/login/webauthn/finish"
+
+	session, options := s.begin(begin, "", nil)
+	assertion := passkey.get(options, flagUserPresent|flagUserVerified)
+	body := expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": assertion}), http.StatusOK)
+	me := expect(t, s.call(http.MethodGet, "/api/v1/user", body["token"].(string), nil), http.StatusOK)
+	if me["uuid"] != user.UUID {
+		t.Errorf("logged in as %v, want %s", me["uuid"], user.UUID)
+	}
+
+	t.Run("replayed ceremony", func(t *testing.T) {
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": assertion}), http.StatusUnauthorized)
+	})
+
+	t.Run("expired ceremony", func(t *testing.T) {
+		store := s.store.WebAuthn
+		s.store.WebAuthn = expiredCeremonies{store}
+		defer func() { s.store.WebAuthn = store }()
+
+		session, options := s.begin(begin, "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": passkey.get(options, flagUserPresent|flagUserVerified)}), http.StatusUnauthorized)
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		passkey.Origin = "https://app.test.example.com"
+		defer func() { passkey.Origin = testAppURL }()
+
+		session, options := s.begin(begin, "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": passkey.get(options, flagUserPresent|flagUserVerified)}), http.StatusUnauthorized)
+	})
+
+	t.Run("without user verification", func(t *testing.T) {
+		session, options := s.begin(begin, "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": passkey.get(options, flagUserPresent)}), http.StatusUnauthorized)
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		// The copy falls behind once the original signs, so its
+		// signature counter does not move forward
+		clone := *passkey
+		session, options := s.begin(begin, "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": passkey.get(options, flagUserPresent|flagUserVerified)}), http.StatusOK)
+
+		session, options = s.begin(begin, "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "credential": clone.get(options, flagUserPresent|flagUserVerified)}), http.StatusUnauthorized)
+	})
+}
+
+func TestWebAuthnMFA(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("ada@example.com")
+	passkey := s.registerPasskey(s.login("ada@example.com"))
+	const begin, finish = "/api/v1/login/mfa/webauthn/begin", "/api/v1/login/mfa/webauthn/finish"
+
+	// A registered passkey turns the password into the first of two factors
+	body := expect(t, s.call(http.MethodPost, "/api/v1/login", "", gin.H{"email": "ada@example.com", "password": testPassword}), http.StatusAccepted)
+	if body["token"] != nil {
+		t.Fatalf("password login with a passkey returned tokens: %v", body)
+	}
+	mfaToken := body["mfa_token"].(string)
+
+	t.Run("wrong origin", func(t *testing.T) {
+		passkey.Origin = "https://app.test.example.com"
+		defer func() { passkey.Origin = testAppURL }()
+
+		session, options := s.begin(begin, "", gin.H{"mfa_token": mfaToken})
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "mfa_token": mfaToken, "credential": passkey.get(options, flagUserPresent)}), http.StatusUnauthorized)
+	})
+
+	t.Run("expired ceremony", func(t *testing.T) {
+		store := s.store.WebAuthn
+		s.store.WebAuthn = expiredCeremonies{store}
+		defer func() { s.store.WebAuthn = store }()
+
+		session, options := s.begin(begin, "", gin.H{"mfa_token": mfaToken})
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "mfa_token": mfaToken, "credential": passkey.get(options, flagUserPresent)}), http.StatusUnauthorized)
+	})
+
+	t.Run("ceremony of another login", func(t *testing.T) {
+		session, options := s.begin("/api/v1/login/webauthn/begin", "", nil)
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "mfa_token": mfaToken, "credential": passkey.get(options, flagUserPresent|flagUserVerified)}), http.StatusUnauthorized)
+	})
+
+	session, options := s.begin(begin, "", gin.H{"mfa_token": mfaToken})
+	assertion := passkey.get(options, flagUserPresent)
+	body = expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "mfa_token": mfaToken, "credential": assertion}), http.StatusOK)
+	token := body["token"].(string)
+	expect(t, s.call(http.MethodGet, "/api/v1/user", token, nil), http.StatusOK)
+
+	t.Run("replayed", func(t *testing.T) {
+		expect(t, s.call(http.MethodPost, finish, "", gin.H{"session_token": session, "mfa_token": mfaToken, "credential": assertion}), http.StatusUnauthorized)
+		expect(t, s.call(http.MethodPost, begin, "", gin.H{"mfa_token": mfaToken}), http.StatusUnauthorized)
+	})
+
+	t.Run("passkey removed", func(t *testing.T) {
+		var credentials []map[string]any
+		if err := json.Unmarshal(s.call(http.MethodGet, "/api/v1/user/webauthn/credentials", token, nil).Body.Bytes(), &credentials); err != nil || len(credentials) != 1 {
+			t.Fatalf("credentials = %v, %v", credentials, err)
+		}
+		path := "/api/v1/user/webauthn/credentials/" + strconv.Itoa(int(credentials[0]["id"].(float64)))
+		expect(t, s.call(http.MethodDelete, path, token, nil), http.StatusOK)
+		s.login("ada@example.com")
+	})
+}
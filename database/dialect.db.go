@@ -0,0 +1,131 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Supported values of DB_DRIVER.
+const (
+	DriverSQLServer = "sqlserver"
+	DriverPostgres  = "postgres"
+	DriverSQLite    = "sqlite"
+)
+
+func init() {
+	// sqlx only knows "sqlite3" (mattn); modernc registers itself as "sqlite".
+	sqlx.BindDriver(DriverSQLite, sqlx.QUESTION)
+}
+
+// Dialect hides the SQL differences between the supported databases.
+// Queries are written with sqlx named parameters (:name), which sqlx
+// rebinds to the placeholder style of each driver.
+type Dialect interface {
+	// Name is the DB_DRIVER value, which is also the database/sql driver name.
+	Name() string
+	// Now is the SQL expression for the current timestamp.
+	Now() string
+	// InsertReturning builds an INSERT of the named parameters :column into
+	// table that returns the given columns of the inserted row.
+	InsertReturning(table string, columns []string, returning ...string) string
+	// IsUniqueViolation reports whether err is a unique constraint violation.
+	IsUniqueViolation(err error) bool
+	// Paginate is the clause that follows ORDER BY to skip :offset rows and
+	// return at most :limit rows.
+	Paginate() string
+}
+
+// DialectFor returns the dialect of a database/sql driver name, as
+// reported by sqlx.DB.DriverName.
+func DialectFor(driverName string) Dialect {
+	switch driverName {
+	case DriverPostgres:
+		return postgresDialect{}
+	case DriverSQLite:
+		return sqliteDialect{}
+	default:
+		return sqlServerDialect{}
+	}
+}
+
+func namedValues(columns []string) string {
+	params := make([]string, len(columns))
+	for i, column := range columns {
+		params[i] = ":" + column
+	}
+	return strings.Join(params, ", ")
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string { return DriverSQLServer }
+
+func (sqlServerDialect) Now() string { return "SYSDATETIME()" }
+
+func (sqlServerDialect) InsertReturning(table string, columns []string, returning ...string) string {
+	output := make([]string, len(returning))
+	for i, column := range returning {
+		output[i] = "INSERTED." + column
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) OUTPUT %s VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(output, ", "), namedValues(columns))
+}
+
+func (sqlServerDialect) IsUniqueViolation(err error) bool {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		// 2601: duplicate key in unique index, 2627: unique constraint violation
+		return sqlErr.Number == 2601 || sqlErr.Number == 2627
+	}
+	return false
+}
+
+func (sqlServerDialect) Paginate() string {
+	return "OFFSET :offset ROWS FETCH NEXT :limit ROWS ONLY"
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return DriverPostgres }
+
+func (postgresDialect) Now() string { return "CURRENT_TIMESTAMP" }
+
+func (postgresDialect) InsertReturning(table string, columns []string, returning ...string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		table, strings.Join(columns, ", "), namedValues(columns), strings.Join(returning, ", "))
+}
+
+func (postgresDialect) IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (postgresDialect) Paginate() string { return "LIMIT :limit OFFSET :offset" }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return DriverSQLite }
+
+// Now keeps millisecond precision, which CURRENT_TIMESTAMP drops. It must
+// not contain ':' since queries go through sqlx named parameter parsing.
+func (sqliteDialect) Now() string { return "datetime('now', 'subsec')" }
+
+func (sqliteDialect) InsertReturning(table string, columns []string, returning ...string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		table, strings.Join(columns, ", "), namedValues(columns), strings.Join(returning, ", "))
+}
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+func (sqliteDialect) Paginate() string { return "LIMIT :limit OFFSET :offset" }
@@ -3,41 +3,97 @@ package db
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
 	_ "github.com/microsoft/go-mssqldb"
+	_ "modernc.org/sqlite"
 )
 
 func DatabaseInit() *sqlx.DB {
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	server := os.Getenv("DB_SERVER")
-	port := os.Getenv("DB_PORT")
-	database := os.Getenv("DB_DATABASE")
-
-	if user == "" || password == "" || server == "" || port == "" || database == "" {
-		log.Fatal("Database environment variables are not fully set")
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = DriverSQLServer
 	}
 
-	dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s&encrypt=disable",
-		user, password, server, port, database)
+	dsn, err := buildDSN(driver)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	db, err := sqlx.Connect("sqlserver", dsn)
+	db, err := sqlx.Connect(driver, dsn)
 	for i := 0; i < 10; i++ {
 		if err == nil {
 			break
 		}
 		log.Printf("Retries conneting database... (%d/10)", i+1)
 		time.Sleep(10 * time.Second)
-		db, err = sqlx.Connect("sqlserver", dsn)
+		db, err = sqlx.Connect(driver, dsn)
 	}
 
 	if err != nil {
 		log.Fatalf("Failed to connect to DB: %v", err)
 	}
 
-	log.Println("Connected to SQL Server")
+	if driver == DriverSQLite {
+		// SQLite allows a single writer; serialize access instead of
+		// failing with SQLITE_BUSY under concurrent requests.
+		db.SetMaxOpenConns(1)
+	}
+
+	log.Printf("Connected to %s", driver)
 	return db
 }
+
+// buildDSN returns DB_DSN when set, otherwise assembles the connection
+// string for driver from the DB_* variables.
+func buildDSN(driver string) (string, error) {
+	if dsn := os.Getenv("DB_DSN"); dsn != "" {
+		return dsn, nil
+	}
+
+	user := os.Getenv("DB_USER")
+	password := os.Getenv("DB_PASSWORD")
+	server := os.Getenv("DB_SERVER")
+	port := os.Getenv("DB_PORT")
+	database := os.Getenv("DB_DATABASE")
+
+	switch driver {
+	case DriverSQLServer:
+		if user == "" || password == "" || server == "" || port == "" || database == "" {
+			return "", fmt.Errorf("database environment variables are not fully set")
+		}
+		return fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s&encrypt=disable",
+			user, password, server, port, database), nil
+
+	case DriverPostgres:
+		if user == "" || server == "" || port == "" || database == "" {
+			return "", fmt.Errorf("database environment variables are not fully set")
+		}
+		sslMode := os.Getenv("DB_SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(user, password),
+			Host:     server + ":" + port,
+			Path:     "/" + database,
+			RawQuery: "sslmode=" + url.QueryEscape(sslMode),
+		}
+		return u.String(), nil
+
+	case DriverSQLite:
+		// DB_DATABASE is the path of the database file
+		if database == "" {
+			return "", fmt.Errorf("DB_DATABASE must be set to the SQLite file path")
+		}
+		return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite", database), nil
+	}
+
+	return "", fmt.Errorf("unsupported DB_DRIVER %q (want %s, %s or %s)",
+		driver, DriverSQLServer, DriverPostgres, DriverSQLite)
+}
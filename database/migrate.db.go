@@ -0,0 +1,296 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration files live in migrations/<dialect>/ and are named
+// <version>_<name>.up.sql / <version>_<name>.down.sql.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var schemaMigrationsDDL = map[string]string{
+	DriverSQLServer: `
+	IF OBJECT_ID('schema_migrations', 'U') IS NULL
+	CREATE TABLE schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name NVARCHAR(255) NOT NULL,
+		applied_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+	)`,
+	DriverPostgres: `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	DriverSQLite: `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+	)`,
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a known migration and when it was applied, if ever.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations of the database's dialect and
+// records them in the schema_migrations table.
+type Migrator struct {
+	DB         *sqlx.DB
+	Dialect    Dialect
+	Migrations []Migration // sorted by version
+}
+
+func NewMigrator(database *sqlx.DB) (*Migrator, error) {
+	dialect := DialectFor(database.DriverName())
+	migrations, err := loadMigrations(dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: database, Dialect: dialect, Migrations: migrations}, nil
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, schemaMigrationsDDL[m.Dialect.Name()])
+	return err
+}
+
+// applied returns the applied_at time of every applied version.
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.DB.SelectContext(ctx, &rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// Status lists every known migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		statuses[i].Migration = migration
+		if at, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.run(ctx, migration.Up, func(tx *sqlx.Tx) error {
+			_, err := tx.NamedExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name) VALUES (:version, :name)",
+				map[string]interface{}{"version": migration.Version, "name": migration.Name})
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the n most recently applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < n; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+		err := m.run(ctx, migration.Down, func(tx *sqlx.Tx) error {
+			_, err := tx.NamedExecContext(ctx,
+				"DELETE FROM schema_migrations WHERE version = :version",
+				map[string]interface{}{"version": migration.Version})
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// run executes a migration script and its bookkeeping in one transaction.
+func (m *Migrator) run(ctx context.Context, script string, record func(tx *sqlx.Tx) error) error {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateCommand runs the "migrate" CLI subcommand:
+//
+//	migrate up        apply all pending migrations
+//	migrate down [N]  roll back the last N migrations (default 1)
+//	migrate status    list migrations and whether they are applied
+func MigrateCommand(ctx context.Context, database *sqlx.DB, args []string, out io.Writer) error {
+	migrator, err := NewMigrator(database)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [N] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		for _, migration := range done {
+			fmt.Fprintf(out, "applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("migrate down: N must be a positive number, got %q", args[1])
+			}
+		}
+		done, err := migrator.Down(ctx, n)
+		for _, migration := range done {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q (want up, down or status)", args[0])
+}
@@ -0,0 +1,14 @@
+-- Access tokens revoked before their exp, by jti
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(36) NOT NULL PRIMARY KEY,
+    user_uuid VARCHAR(36) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL, -- row can be dropped after this
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every token of the user issued before not_before is revoked
+-- (logout everywhere, password change, account deletion)
+CREATE TABLE token_cutoffs (
+    user_uuid VARCHAR(36) NOT NULL PRIMARY KEY,
+    not_before TIMESTAMPTZ NOT NULL
+);
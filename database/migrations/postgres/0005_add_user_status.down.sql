@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN disabled_at,
+    DROP COLUMN password_reset_required;
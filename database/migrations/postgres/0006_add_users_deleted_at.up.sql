@@ -0,0 +1,5 @@
+-- Soft delete: deleted_at starts the restore grace period, purged_at marks
+-- rows whose personal data has been wiped once it expired
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMPTZ NULL,
+    ADD COLUMN purged_at TIMESTAMPTZ NULL;
@@ -0,0 +1,9 @@
+-- Previous password hashes, so users cannot cycle back to an old password
+CREATE TABLE password_history (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ix_password_history_user_id ON password_history (user_id);
@@ -0,0 +1,13 @@
+-- Single-use tokens sent to users by email (password reset, ...)
+CREATE TABLE user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    email VARCHAR(100) NOT NULL, -- the address the token was mailed to
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- sha256 of the token, never the token itself
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX ix_user_tokens_user_id ON user_tokens (user_id, purpose);
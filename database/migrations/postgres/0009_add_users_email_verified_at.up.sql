@@ -0,0 +1,3 @@
+-- Accounts created before verification existed are trusted as they are
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ NULL;
+UPDATE users SET email_verified_at = created_at;
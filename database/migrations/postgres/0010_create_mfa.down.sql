@@ -0,0 +1,3 @@
+ALTER TABLE user_tokens DROP COLUMN attempts;
+DROP TABLE mfa_recovery_codes;
+DROP TABLE user_totp;
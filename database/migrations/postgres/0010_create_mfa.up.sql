@@ -0,0 +1,21 @@
+-- TOTP second factor; confirmed_at stays NULL until the first valid code,
+-- last_step is the last time step accepted so a code works only once
+CREATE TABLE user_totp (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL, -- base32
+    confirmed_at TIMESTAMPTZ NULL,
+    last_step BIGINT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL, -- sha256 of the normalized code
+    used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX ix_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+-- Wrong codes entered against an MFA challenge
+ALTER TABLE user_tokens ADD COLUMN attempts INT NOT NULL DEFAULT 0;
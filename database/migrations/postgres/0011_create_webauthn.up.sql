@@ -0,0 +1,29 @@
+-- Passkeys and security keys registered with WebAuthn
+CREATE TABLE webauthn_credentials (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id VARCHAR(700) NOT NULL UNIQUE, -- base64url
+    public_key BYTEA NOT NULL, -- COSE encoded
+    attestation_type VARCHAR(32) NOT NULL,
+    aaguid BYTEA NULL,
+    transports VARCHAR(255) NOT NULL DEFAULT '', -- comma separated
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX ix_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- Challenges between the begin and finish steps of a ceremony. user_id is
+-- NULL for passkey logins, where the user is only known at the end.
+CREATE TABLE webauthn_sessions (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    purpose VARCHAR(32) NOT NULL,
+    user_id INT NULL REFERENCES users(id) ON DELETE CASCADE,
+    data TEXT NOT NULL, -- JSON session data of the ceremony
+    expires_at TIMESTAMPTZ NOT NULL
+);
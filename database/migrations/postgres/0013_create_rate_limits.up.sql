@@ -0,0 +1,8 @@
+-- Token buckets of the rate limiter when RATE_LIMIT_STORE=database. tat is
+-- the time the bucket is full again, in Unix nanoseconds.
+CREATE TABLE rate_limits (
+    bucket VARCHAR(300) PRIMARY KEY,
+    tat BIGINT NOT NULL
+);
+
+CREATE INDEX ix_rate_limits_tat ON rate_limits (tat);
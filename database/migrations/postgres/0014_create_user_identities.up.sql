@@ -0,0 +1,26 @@
+-- Accounts at external OpenID Connect providers that can log in as a user
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- sub claim, unique per provider
+    email VARCHAR(255) NOT NULL DEFAULT '', -- as reported at the last login
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMPTZ NULL,
+    CONSTRAINT uq_user_identities_provider_subject UNIQUE (provider, subject)
+);
+
+CREATE INDEX ix_user_identities_user_id ON user_identities (user_id);
+
+-- Authorization requests sent to a provider and not answered yet. user_id
+-- is set when a logged in user links a provider.
+CREATE TABLE oidc_states (
+    id SERIAL PRIMARY KEY,
+    state_hash VARCHAR(64) NOT NULL UNIQUE,
+    provider VARCHAR(50) NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    user_id INT NULL REFERENCES users(id) ON DELETE CASCADE,
+    nonce VARCHAR(100) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL, -- PKCE
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,8 @@
+DELETE FROM role_permissions
+WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'oauth_clients:manage');
+DELETE FROM permissions WHERE name = 'oauth_clients:manage';
+
+DROP TABLE oauth_consents;
+DROP TABLE oauth_codes;
+DROP TABLE oauth_requests;
+DROP TABLE oauth_clients;
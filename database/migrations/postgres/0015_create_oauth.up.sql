@@ -0,0 +1,52 @@
+-- Apps that let users sign in with their account (OAuth2/OpenID Connect).
+-- Public clients, e.g. single page apps, have no secret.
+CREATE TABLE oauth_clients (
+    id SERIAL PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    client_secret_hash VARCHAR(64) NULL,
+    name VARCHAR(100) NOT NULL,
+    redirect_uris TEXT NOT NULL, -- space separated
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Authorization requests waiting for the user to consent
+CREATE TABLE oauth_requests (
+    id SERIAL PRIMARY KEY,
+    request_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    redirect_uri VARCHAR(2000) NOT NULL,
+    scope VARCHAR(255) NOT NULL,
+    state VARCHAR(500) NOT NULL DEFAULT '',
+    nonce VARCHAR(500) NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL, -- PKCE, S256
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Authorization codes handed to clients, redeemed once at the token endpoint
+CREATE TABLE oauth_codes (
+    id SERIAL PRIMARY KEY,
+    code_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri VARCHAR(2000) NOT NULL,
+    scope VARCHAR(255) NOT NULL,
+    nonce VARCHAR(500) NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Scopes a user agreed to share with a client, so consent is asked once
+CREATE TABLE oauth_consents (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scope VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id)
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('oauth_clients:manage', 'Register and remove OAuth clients');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'oauth_clients:manage';
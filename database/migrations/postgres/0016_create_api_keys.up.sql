@@ -0,0 +1,15 @@
+-- Personal API keys for scripts. Only a hash of the key is stored; prefix
+-- is the start of the key, shown so its owner can tell keys apart.
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(1000) NOT NULL DEFAULT '', -- space separated permissions
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ix_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,5 @@
+DELETE FROM role_permissions
+WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'service_accounts:manage');
+DELETE FROM permissions WHERE name = 'service_accounts:manage';
+
+DROP TABLE service_accounts;
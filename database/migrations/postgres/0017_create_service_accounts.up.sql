@@ -0,0 +1,20 @@
+-- Non-human principals, e.g. batch jobs, that get access tokens with the
+-- OAuth2 client_credentials grant
+CREATE TABLE service_accounts (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(500) NOT NULL DEFAULT '',
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    client_secret_hash VARCHAR(64) NOT NULL,
+    permissions VARCHAR(1000) NOT NULL DEFAULT '', -- space separated
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_token_at TIMESTAMPTZ NULL
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('service_accounts:manage', 'Create and remove service accounts');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'service_accounts:manage';
@@ -0,0 +1,13 @@
+-- Server-side sessions for cookie authentication (AUTH_MODE=session). The
+-- cookie holds the session token; only hashes are stored.
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    session_hash VARCHAR(64) NOT NULL UNIQUE,
+    csrf_hash VARCHAR(64) NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX ix_sessions_user_id ON sessions (user_id);
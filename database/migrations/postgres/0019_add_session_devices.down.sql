@@ -0,0 +1,5 @@
+DROP INDEX ix_sessions_family_id;
+ALTER TABLE sessions
+    DROP COLUMN device_name,
+    DROP COLUMN ip_address,
+    DROP COLUMN family_id;
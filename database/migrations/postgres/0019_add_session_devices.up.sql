@@ -0,0 +1,8 @@
+-- Every login now gets a session, also in token mode, where family_id
+-- links it to its refresh tokens.
+ALTER TABLE sessions
+    ADD COLUMN device_name VARCHAR(200) NOT NULL DEFAULT '',
+    ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    ADD COLUMN family_id VARCHAR(36) NULL;
+
+CREATE INDEX ix_sessions_family_id ON sessions (family_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT NOT NULL UNIQUE, -- public identifier
+    name TEXT,
+    email TEXT NOT NULL UNIQUE,
+    age INTEGER CHECK (age >= 0 AND age <= 150),
+    password_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    updated_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
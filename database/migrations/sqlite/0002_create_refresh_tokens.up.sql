@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE, -- sha256 of the token, never the token itself
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    revoked_at DATETIME NULL,
+    replaced_by INTEGER NULL
+);
+
+CREATE INDEX ix_refresh_tokens_family_id ON refresh_tokens (family_id);
@@ -0,0 +1,14 @@
+-- Access tokens revoked before their exp, by jti
+CREATE TABLE revoked_tokens (
+    jti TEXT NOT NULL PRIMARY KEY,
+    user_uuid TEXT NOT NULL,
+    expires_at DATETIME NOT NULL, -- row can be dropped after this
+    revoked_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
+
+-- Every token of the user issued before not_before is revoked
+-- (logout everywhere, password change, account deletion)
+CREATE TABLE token_cutoffs (
+    user_uuid TEXT NOT NULL PRIMARY KEY,
+    not_before DATETIME NOT NULL
+);
@@ -0,0 +1,47 @@
+CREATE TABLE roles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT
+);
+
+CREATE TABLE permissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE, -- <resource>:<action>
+    description TEXT
+);
+
+CREATE TABLE role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('student', 'Default role of every account'),
+    ('lecturer', 'Teaching staff'),
+    ('staff', 'Faculty staff'),
+    ('admin', 'Full access');
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:list', 'List all users'),
+    ('users:read', 'Read any user'),
+    ('users:update', 'Update any user'),
+    ('users:delete', 'Delete any user'),
+    ('roles:list', 'List roles and role assignments'),
+    ('roles:assign', 'Assign and remove roles');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE (r.name = 'lecturer' AND p.name IN ('users:list'))
+   OR (r.name = 'staff' AND p.name IN ('users:list', 'users:read', 'roles:list'))
+   OR r.name = 'admin';
+
+-- Existing accounts become students
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u, roles r WHERE r.name = 'student';
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN disabled_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Soft delete: deleted_at starts the restore grace period, purged_at marks
+-- rows whose personal data has been wiped once it expired
+ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN purged_at DATETIME NULL;
@@ -0,0 +1,9 @@
+-- Previous password hashes, so users cannot cycle back to an old password
+CREATE TABLE password_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
+
+CREATE INDEX ix_password_history_user_id ON password_history (user_id);
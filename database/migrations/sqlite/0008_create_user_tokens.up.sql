@@ -0,0 +1,13 @@
+-- Single-use tokens sent to users by email (password reset, ...)
+CREATE TABLE user_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    email TEXT NOT NULL, -- the address the token was mailed to
+    token_hash TEXT NOT NULL UNIQUE, -- sha256 of the token, never the token itself
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    used_at DATETIME NULL
+);
+
+CREATE INDEX ix_user_tokens_user_id ON user_tokens (user_id, purpose);
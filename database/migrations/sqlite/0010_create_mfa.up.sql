@@ -0,0 +1,21 @@
+-- TOTP second factor; confirmed_at stays NULL until the first valid code,
+-- last_step is the last time step accepted so a code works only once
+CREATE TABLE user_totp (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL, -- base32
+    confirmed_at DATETIME NULL,
+    last_step INTEGER NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL, -- sha256 of the normalized code
+    used_at DATETIME NULL
+);
+
+CREATE INDEX ix_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+-- Wrong codes entered against an MFA challenge
+ALTER TABLE user_tokens ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
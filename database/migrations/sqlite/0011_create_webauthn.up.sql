@@ -0,0 +1,29 @@
+-- Passkeys and security keys registered with WebAuthn
+CREATE TABLE webauthn_credentials (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id TEXT NOT NULL UNIQUE, -- base64url
+    public_key BLOB NOT NULL, -- COSE encoded
+    attestation_type TEXT NOT NULL,
+    aaguid BLOB NULL,
+    transports TEXT NOT NULL DEFAULT '', -- comma separated
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT 0,
+    backup_state BOOLEAN NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    last_used_at DATETIME NULL
+);
+
+CREATE INDEX ix_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- Challenges between the begin and finish steps of a ceremony. user_id is
+-- NULL for passkey logins, where the user is only known at the end.
+CREATE TABLE webauthn_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,
+    purpose TEXT NOT NULL,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+    data TEXT NOT NULL, -- JSON session data of the ceremony
+    expires_at DATETIME NOT NULL
+);
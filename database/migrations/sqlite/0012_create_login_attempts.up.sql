@@ -0,0 +1,10 @@
+-- Failed logins per account ("account:<email>") and per client IP
+-- ("ip:<address>"), shared by every replica
+CREATE TABLE login_attempts (
+    subject TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL
+);
+
+CREATE INDEX ix_login_attempts_last_failure_at ON login_attempts (last_failure_at);
@@ -0,0 +1,26 @@
+-- Accounts at external OpenID Connect providers that can log in as a user
+CREATE TABLE user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL, -- sub claim, unique per provider
+    email TEXT NOT NULL DEFAULT '', -- as reported at the last login
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    last_login_at DATETIME NULL,
+    CONSTRAINT uq_user_identities_provider_subject UNIQUE (provider, subject)
+);
+
+CREATE INDEX ix_user_identities_user_id ON user_identities (user_id);
+
+-- Authorization requests sent to a provider and not answered yet. user_id
+-- is set when a logged in user links a provider.
+CREATE TABLE oidc_states (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    state_hash TEXT NOT NULL UNIQUE,
+    provider TEXT NOT NULL,
+    purpose TEXT NOT NULL,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL, -- PKCE
+    expires_at DATETIME NOT NULL
+);
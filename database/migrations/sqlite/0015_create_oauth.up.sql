@@ -0,0 +1,52 @@
+-- Apps that let users sign in with their account (OAuth2/OpenID Connect).
+-- Public clients, e.g. single page apps, have no secret.
+CREATE TABLE oauth_clients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    client_id TEXT NOT NULL UNIQUE,
+    client_secret_hash TEXT NULL,
+    name TEXT NOT NULL,
+    redirect_uris TEXT NOT NULL, -- space separated
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
+
+-- Authorization requests waiting for the user to consent
+CREATE TABLE oauth_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_hash TEXT NOT NULL UNIQUE,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL, -- PKCE, S256
+    expires_at DATETIME NOT NULL
+);
+
+-- Authorization codes handed to clients, redeemed once at the token endpoint
+CREATE TABLE oauth_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code_hash TEXT NOT NULL UNIQUE,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+-- Scopes a user agreed to share with a client, so consent is asked once
+CREATE TABLE oauth_consents (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    PRIMARY KEY (user_id, client_id)
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('oauth_clients:manage', 'Register and remove OAuth clients');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'oauth_clients:manage';
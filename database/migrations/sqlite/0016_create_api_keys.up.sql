@@ -0,0 +1,15 @@
+-- Personal API keys for scripts. Only a hash of the key is stored; prefix
+-- is the start of the key, shown so its owner can tell keys apart.
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '', -- space separated permissions
+    expires_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec'))
+);
+
+CREATE INDEX ix_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,20 @@
+-- Non-human principals, e.g. batch jobs, that get access tokens with the
+-- OAuth2 client_credentials grant
+CREATE TABLE service_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uuid TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    client_id TEXT NOT NULL UNIQUE,
+    client_secret_hash TEXT NOT NULL,
+    permissions TEXT NOT NULL DEFAULT '', -- space separated
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    last_token_at DATETIME NULL
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('service_accounts:manage', 'Create and remove service accounts');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'service_accounts:manage';
@@ -0,0 +1,13 @@
+-- Server-side sessions for cookie authentication (AUTH_MODE=session). The
+-- cookie holds the session token; only hashes are stored.
+CREATE TABLE sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_hash TEXT NOT NULL UNIQUE,
+    csrf_hash TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    last_seen_at DATETIME NOT NULL DEFAULT (datetime('now', 'subsec')),
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX ix_sessions_user_id ON sessions (user_id);
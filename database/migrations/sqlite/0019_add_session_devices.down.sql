@@ -0,0 +1,4 @@
+DROP INDEX ix_sessions_family_id;
+ALTER TABLE sessions DROP COLUMN family_id;
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN device_name;
@@ -0,0 +1,7 @@
+-- Every login now gets a session, also in token mode, where family_id
+-- links it to its refresh tokens.
+ALTER TABLE sessions ADD COLUMN device_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN family_id TEXT NULL;
+
+CREATE INDEX ix_sessions_family_id ON sessions (family_id);
@@ -0,0 +1,12 @@
+-- Databases set up by hand from the old README already have this table
+IF OBJECT_ID('users', 'U') IS NULL
+CREATE TABLE users (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    uuid NVARCHAR(36) NOT NULL DEFAULT CONVERT(NVARCHAR(36), NEWID()) UNIQUE, -- public identifier
+    name NVARCHAR(100),
+    email NVARCHAR(100) NOT NULL UNIQUE,
+    age INT CHECK (age >= 0 AND age <= 150),
+    password_hash NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    updated_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
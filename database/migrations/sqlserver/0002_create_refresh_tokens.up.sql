@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id NVARCHAR(36) NOT NULL,
+    token_hash NVARCHAR(64) NOT NULL UNIQUE, -- sha256 of the token, never the token itself
+    expires_at DATETIME2 NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    revoked_at DATETIME2 NULL,
+    replaced_by INT NULL
+);
+
+CREATE INDEX ix_refresh_tokens_family_id ON refresh_tokens (family_id);
@@ -0,0 +1,14 @@
+-- Access tokens revoked before their exp, by jti
+CREATE TABLE revoked_tokens (
+    jti NVARCHAR(36) NOT NULL PRIMARY KEY,
+    user_uuid NVARCHAR(36) NOT NULL,
+    expires_at DATETIME2 NOT NULL, -- row can be dropped after this
+    revoked_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
+
+-- Every token of the user issued before not_before is revoked
+-- (logout everywhere, password change, account deletion)
+CREATE TABLE token_cutoffs (
+    user_uuid NVARCHAR(36) NOT NULL PRIMARY KEY,
+    not_before DATETIME2 NOT NULL
+);
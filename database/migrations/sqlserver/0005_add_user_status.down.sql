@@ -0,0 +1,2 @@
+ALTER TABLE users DROP CONSTRAINT DF_users_password_reset_required;
+ALTER TABLE users DROP COLUMN disabled_at, password_reset_required;
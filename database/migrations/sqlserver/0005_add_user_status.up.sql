@@ -0,0 +1,3 @@
+ALTER TABLE users ADD
+    disabled_at DATETIME2 NULL,
+    password_reset_required BIT NOT NULL CONSTRAINT DF_users_password_reset_required DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN deleted_at, purged_at;
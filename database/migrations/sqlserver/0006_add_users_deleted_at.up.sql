@@ -0,0 +1,5 @@
+-- Soft delete: deleted_at starts the restore grace period, purged_at marks
+-- rows whose personal data has been wiped once it expired
+ALTER TABLE users ADD
+    deleted_at DATETIME2 NULL,
+    purged_at DATETIME2 NULL;
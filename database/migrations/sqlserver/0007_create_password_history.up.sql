@@ -0,0 +1,9 @@
+-- Previous password hashes, so users cannot cycle back to an old password
+CREATE TABLE password_history (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
+
+CREATE INDEX ix_password_history_user_id ON password_history (user_id);
@@ -0,0 +1,13 @@
+-- Single-use tokens sent to users by email (password reset, ...)
+CREATE TABLE user_tokens (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose NVARCHAR(32) NOT NULL,
+    email NVARCHAR(100) NOT NULL, -- the address the token was mailed to
+    token_hash NVARCHAR(64) NOT NULL UNIQUE, -- sha256 of the token, never the token itself
+    expires_at DATETIME2 NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    used_at DATETIME2 NULL
+);
+
+CREATE INDEX ix_user_tokens_user_id ON user_tokens (user_id, purpose);
@@ -0,0 +1,5 @@
+-- Accounts created before verification existed are trusted as they are.
+-- The new column is only visible to statements compiled after the ALTER,
+-- hence EXEC.
+ALTER TABLE users ADD email_verified_at DATETIME2 NULL;
+EXEC('UPDATE users SET email_verified_at = created_at');
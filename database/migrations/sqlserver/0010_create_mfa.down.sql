@@ -0,0 +1,4 @@
+ALTER TABLE user_tokens DROP CONSTRAINT DF_user_tokens_attempts;
+ALTER TABLE user_tokens DROP COLUMN attempts;
+DROP TABLE mfa_recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,21 @@
+-- TOTP second factor; confirmed_at stays NULL until the first valid code,
+-- last_step is the last time step accepted so a code works only once
+CREATE TABLE user_totp (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret NVARCHAR(64) NOT NULL, -- base32
+    confirmed_at DATETIME2 NULL,
+    last_step BIGINT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash NVARCHAR(64) NOT NULL, -- sha256 of the normalized code
+    used_at DATETIME2 NULL
+);
+
+CREATE INDEX ix_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+-- Wrong codes entered against an MFA challenge
+ALTER TABLE user_tokens ADD attempts INT NOT NULL CONSTRAINT DF_user_tokens_attempts DEFAULT 0;
@@ -0,0 +1,29 @@
+-- Passkeys and security keys registered with WebAuthn
+CREATE TABLE webauthn_credentials (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id NVARCHAR(700) NOT NULL UNIQUE, -- base64url
+    public_key VARBINARY(MAX) NOT NULL, -- COSE encoded
+    attestation_type NVARCHAR(32) NOT NULL,
+    aaguid VARBINARY(16) NULL,
+    transports NVARCHAR(255) NOT NULL CONSTRAINT DF_webauthn_credentials_transports DEFAULT '', -- comma separated
+    sign_count BIGINT NOT NULL CONSTRAINT DF_webauthn_credentials_sign_count DEFAULT 0,
+    backup_eligible BIT NOT NULL CONSTRAINT DF_webauthn_credentials_backup_eligible DEFAULT 0,
+    backup_state BIT NOT NULL CONSTRAINT DF_webauthn_credentials_backup_state DEFAULT 0,
+    name NVARCHAR(100) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    last_used_at DATETIME2 NULL
+);
+
+CREATE INDEX ix_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- Challenges between the begin and finish steps of a ceremony. user_id is
+-- NULL for passkey logins, where the user is only known at the end.
+CREATE TABLE webauthn_sessions (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    token_hash NVARCHAR(64) NOT NULL UNIQUE,
+    purpose NVARCHAR(32) NOT NULL,
+    user_id INT NULL REFERENCES users(id) ON DELETE CASCADE,
+    data NVARCHAR(MAX) NOT NULL, -- JSON session data of the ceremony
+    expires_at DATETIME2 NOT NULL
+);
@@ -0,0 +1,26 @@
+-- Accounts at external OpenID Connect providers that can log in as a user
+CREATE TABLE user_identities (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider NVARCHAR(50) NOT NULL,
+    subject NVARCHAR(255) NOT NULL, -- sub claim, unique per provider
+    email NVARCHAR(255) NOT NULL CONSTRAINT DF_user_identities_email DEFAULT '', -- as reported at the last login
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    last_login_at DATETIME2 NULL,
+    CONSTRAINT uq_user_identities_provider_subject UNIQUE (provider, subject)
+);
+
+CREATE INDEX ix_user_identities_user_id ON user_identities (user_id);
+
+-- Authorization requests sent to a provider and not answered yet. user_id
+-- is set when a logged in user links a provider.
+CREATE TABLE oidc_states (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    state_hash NVARCHAR(64) NOT NULL UNIQUE,
+    provider NVARCHAR(50) NOT NULL,
+    purpose NVARCHAR(32) NOT NULL,
+    user_id INT NULL REFERENCES users(id) ON DELETE CASCADE,
+    nonce NVARCHAR(100) NOT NULL,
+    code_verifier NVARCHAR(128) NOT NULL, -- PKCE
+    expires_at DATETIME2 NOT NULL
+);
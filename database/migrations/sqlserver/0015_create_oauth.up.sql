@@ -0,0 +1,52 @@
+-- Apps that let users sign in with their account (OAuth2/OpenID Connect).
+-- Public clients, e.g. single page apps, have no secret.
+CREATE TABLE oauth_clients (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    client_id NVARCHAR(64) NOT NULL UNIQUE,
+    client_secret_hash NVARCHAR(64) NULL,
+    name NVARCHAR(100) NOT NULL,
+    redirect_uris NVARCHAR(MAX) NOT NULL, -- space separated
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
+
+-- Authorization requests waiting for the user to consent
+CREATE TABLE oauth_requests (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    request_hash NVARCHAR(64) NOT NULL UNIQUE,
+    client_id NVARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    redirect_uri NVARCHAR(2000) NOT NULL,
+    scope NVARCHAR(255) NOT NULL,
+    state NVARCHAR(500) NOT NULL CONSTRAINT DF_oauth_requests_state DEFAULT '',
+    nonce NVARCHAR(500) NOT NULL CONSTRAINT DF_oauth_requests_nonce DEFAULT '',
+    code_challenge NVARCHAR(128) NOT NULL, -- PKCE, S256
+    expires_at DATETIME2 NOT NULL
+);
+
+-- Authorization codes handed to clients, redeemed once at the token endpoint
+CREATE TABLE oauth_codes (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    code_hash NVARCHAR(64) NOT NULL UNIQUE,
+    client_id NVARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri NVARCHAR(2000) NOT NULL,
+    scope NVARCHAR(255) NOT NULL,
+    nonce NVARCHAR(500) NOT NULL CONSTRAINT DF_oauth_codes_nonce DEFAULT '',
+    code_challenge NVARCHAR(128) NOT NULL,
+    expires_at DATETIME2 NOT NULL
+);
+
+-- Scopes a user agreed to share with a client, so consent is asked once
+CREATE TABLE oauth_consents (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id NVARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scope NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    PRIMARY KEY (user_id, client_id)
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('oauth_clients:manage', 'Register and remove OAuth clients');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'oauth_clients:manage';
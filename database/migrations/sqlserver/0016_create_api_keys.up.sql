@@ -0,0 +1,15 @@
+-- Personal API keys for scripts. Only a hash of the key is stored; prefix
+-- is the start of the key, shown so its owner can tell keys apart.
+CREATE TABLE api_keys (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name NVARCHAR(100) NOT NULL,
+    prefix NVARCHAR(16) NOT NULL,
+    key_hash NVARCHAR(64) NOT NULL UNIQUE,
+    scopes NVARCHAR(1000) NOT NULL CONSTRAINT DF_api_keys_scopes DEFAULT '', -- space separated permissions
+    expires_at DATETIME2 NOT NULL,
+    last_used_at DATETIME2 NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME()
+);
+
+CREATE INDEX ix_api_keys_user_id ON api_keys (user_id);
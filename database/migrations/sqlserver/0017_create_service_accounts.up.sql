@@ -0,0 +1,20 @@
+-- Non-human principals, e.g. batch jobs, that get access tokens with the
+-- OAuth2 client_credentials grant
+CREATE TABLE service_accounts (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    uuid NVARCHAR(36) NOT NULL UNIQUE,
+    name NVARCHAR(100) NOT NULL,
+    description NVARCHAR(500) NOT NULL CONSTRAINT DF_service_accounts_description DEFAULT '',
+    client_id NVARCHAR(64) NOT NULL UNIQUE,
+    client_secret_hash NVARCHAR(64) NOT NULL,
+    permissions NVARCHAR(1000) NOT NULL CONSTRAINT DF_service_accounts_permissions DEFAULT '', -- space separated
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    last_token_at DATETIME2 NULL
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('service_accounts:manage', 'Create and remove service accounts');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'service_accounts:manage';
@@ -0,0 +1,13 @@
+-- Server-side sessions for cookie authentication (AUTH_MODE=session). The
+-- cookie holds the session token; only hashes are stored.
+CREATE TABLE sessions (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    session_hash NVARCHAR(64) NOT NULL UNIQUE,
+    csrf_hash NVARCHAR(64) NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    last_seen_at DATETIME2 NOT NULL DEFAULT SYSDATETIME(),
+    expires_at DATETIME2 NOT NULL
+);
+
+CREATE INDEX ix_sessions_user_id ON sessions (user_id);
@@ -0,0 +1,4 @@
+DROP INDEX ix_sessions_family_id ON sessions;
+ALTER TABLE sessions DROP CONSTRAINT DF_sessions_device_name;
+ALTER TABLE sessions DROP CONSTRAINT DF_sessions_ip_address;
+ALTER TABLE sessions DROP COLUMN device_name, ip_address, family_id;
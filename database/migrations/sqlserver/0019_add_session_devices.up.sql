@@ -0,0 +1,8 @@
+-- Every login now gets a session, also in token mode, where family_id
+-- links it to its refresh tokens.
+ALTER TABLE sessions ADD
+    device_name NVARCHAR(200) NOT NULL CONSTRAINT DF_sessions_device_name DEFAULT '',
+    ip_address NVARCHAR(45) NOT NULL CONSTRAINT DF_sessions_ip_address DEFAULT '',
+    family_id NVARCHAR(36) NULL;
+
+CREATE INDEX ix_sessions_family_id ON sessions (family_id);
@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogSender writes email to the log instead of delivering it. Links in
+// the body are usable as is, so it is enough to try the flows locally.
+type LogSender struct {
+	From string
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes each email to an .eml file in Dir, which most mail
+// clients can open.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), fileSafe(msg.To))
+	if filepath.Base(name) != name {
+		return fmt.Errorf("invalid mail file name %q", name)
+	}
+	return os.WriteFile(filepath.Join(s.Dir, name), compose(s.From, msg), 0o600)
+}
+
+// fileSafe replaces the characters of an address that could leave Dir or
+// are not allowed in file names, such as the / in "a/b@example.com".
+func fileSafe(address string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("@.+-_", r):
+			return r
+		}
+		return '_'
+	}, address)
+}
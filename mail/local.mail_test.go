@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSenderStaysInDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender := &FileSender{Dir: dir, From: "Fiet <no-reply@localhost>"}
+
+	for _, to := range []string{"ada@example.com", "a/b@example.com", `..\\..@example.com`, "../../etc/passwd@example.com"} {
+		if err := sender.Send(context.Background(), Message{To: to, Subject: "Hi", Body: "Hello"}); err != nil {
+			t.Errorf("Send to %q: %v", to, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d files in %s, want 4", len(entries), dir)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".eml") {
+			t.Errorf("unexpected entry %q", entry.Name())
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*-ada@example.com.eml")); len(matches) != 1 {
+		t.Errorf("plain address not kept in the file name: %v", entries)
+	}
+}
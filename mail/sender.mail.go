@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"fiet/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewSender builds the sender selected by MAIL_DRIVER: "smtp", "file"
+// (one .eml file per message in MAIL_DIR) or "log" (the default, for
+// local development).
+func NewSender() (Sender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Fiet <no-reply@localhost>"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+		return &SMTPSender{
+			Host:     host,
+			Port:     config.Int("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail-out"
+		}
+		return &FileSender{Dir: dir, From: from}, nil
+	case "", "log":
+		return &LogSender{From: from}, nil
+	default:
+		return nil, fmt.Errorf("unsupported MAIL_DRIVER %q", driver)
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"fiet/auth"
 	"fiet/config"
+	"fiet/controller"
 	db "fiet/database"
 	docs "fiet/docs"
+	"fiet/mail"
+	"fiet/password"
+	"fiet/repository"
 	"fiet/router"
+	"flag"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	swaggerfiles "github.com/swaggo/files"
@@ -25,20 +37,68 @@ import (
 // @name Authorization
 // @description JWT Authorization header using the Bearer scheme. Example: "Authorization: Bearer {token}"
 func main() {
+	migrate := flag.Bool("migrate", false, "apply pending database migrations before starting the server")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	config.LoadConfig()
 
 	// Initialize the database connection
-	db := db.DatabaseInit()
+	database := db.DatabaseInit()
+
+	store := repository.NewSQLStore(database)
+	// Rate limit buckets stay in memory unless replicas have to share them
+	if os.Getenv("RATE_LIMIT_STORE") != "database" {
+		store.RateLimits = repository.NewMemoryRateLimitRepository()
+	}
+	// Login sessions are shared through the database unless a single
+	// instance keeps them in memory
+	if os.Getenv("SESSION_STORE") == "memory" {
+		store.Sessions = repository.NewMemorySessionRepository()
+	}
+
+	// `fiet migrate up|down N|status` manages the schema and exits
+	// `fiet role grant|revoke <email> <role>` manages roles and exits
+	if args := flag.Args(); len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "migrate":
+			err = db.MigrateCommand(context.Background(), database, args[1:], os.Stdout)
+		case "role":
+			err = roleCommand(context.Background(), store, args[1:], os.Stdout)
+		default:
+			err = fmt.Errorf("unknown command %q", args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *migrate {
+		migrator, err := db.NewMigrator(database)
+		if err != nil {
+			log.Fatal(err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", len(applied))
+	}
 
 	r := gin.Default()
-	// Trust a specific proxy (e.g., NGINX running on 10.0.0.1)
-	r.SetTrustedProxies([]string{"10.0.0.1", "192.168.1.0/24", "localhost"})
+	// Only these proxies may set X-Forwarded-For, which decides the client
+	// IP that failed logins are counted against
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	// r.Use(cors.Default())
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowOrigins:     []string{appURL()},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", auth.CSRFHeader},
+		ExposeHeaders:    []string{"Link", "X-Total-Count", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: true, // 🔥 this is REQUIRED for cookies to be set
 	}))
 	// config := cors.DefaultConfig()
@@ -51,12 +111,97 @@ func main() {
 
 	api.GET("/ping", PingHandler)
 
-	router.SetUserRoutes(api, db)
+	revoker := auth.NewRevoker(store.Revocations)
+	if err := revoker.Start(context.Background(), config.Duration("REVOCATION_SYNC_INTERVAL", 30*time.Second)); err != nil {
+		log.Fatalf("Failed to load revoked tokens: %v", err)
+	}
+	gracePeriod := config.Duration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+	purger := &repository.UserPurger{Users: store.Users, Grace: gracePeriod}
+	purger.Start(context.Background(), config.Duration("ACCOUNT_PURGE_INTERVAL", time.Hour))
+
+	mailer, err := mail.NewSender()
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+
+	relyingParty, err := auth.NewWebAuthn(appURL())
+	if err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
+
+	oidcProviders, err := auth.LoadOIDCProviders(appURL())
+	if err != nil {
+		log.Fatalf("Invalid OIDC configuration: %v", err)
+	}
+
+	oauthServer, err := auth.NewOAuthServer(appURL())
+	if err != nil {
+		log.Fatalf("Invalid OAuth configuration: %v", err)
+	}
+
+	cookies, err := auth.LoadCookieConfig()
+	if err != nil {
+		log.Fatalf("Invalid cookie configuration: %v", err)
+	}
+
+	sessions := auth.NewSessionManager(store.Sessions, store.Users, store.Roles)
+	switch mode := os.Getenv("AUTH_MODE"); mode {
+	case "", "token":
+	case "session":
+		sessions.Cookie = true
+	default:
+		log.Fatalf("Unknown AUTH_MODE %q", mode)
+	}
+
+	ctls := &controller.DBController{
+		Store:                store,
+		Revoker:              revoker,
+		Throttle:             auth.NewLoginThrottle(store.LoginAttempts),
+		DeletionGracePeriod:  gracePeriod,
+		PasswordPolicy:       password.LoadPolicy(),
+		PasswordHasher:       password.LoadHasher(),
+		Mailer:               mailer,
+		AppURL:               appURL(),
+		RequireVerifiedEmail: config.Bool("REQUIRE_EMAIL_VERIFICATION", false),
+		RelyingParty:         relyingParty,
+		OIDCProviders:        oidcProviders,
+		OAuthServer:          oauthServer,
+		SessionManager:       sessions,
+		Cookies:              cookies,
+	}
+
+	router.SetUserRoutes(api, ctls)
+	router.SetWellKnownRoutes(r, ctls)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	r.Run(":8080") // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
 }
 
+// appURL is the web frontend that links in emails point to.
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// trustedProxies lists the addresses and CIDRs of the reverse proxies in
+// front of the server, from the comma separated TRUSTED_PROXIES.
+func trustedProxies() []string {
+	list := os.Getenv("TRUSTED_PROXIES")
+	if list == "" {
+		// e.g. NGINX running on 10.0.0.1
+		return []string{"10.0.0.1", "192.168.1.0/24", "127.0.0.1", "::1"}
+	}
+	var proxies []string
+	for _, proxy := range strings.Split(list, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 // Ping godoc
 // @Summary      ping
 // @Description  do ping
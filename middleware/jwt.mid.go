@@ -1,18 +1,38 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"fiet/auth"
+	"fiet/model"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+// JWTAuthMiddleware authenticates the caller with an access token in the
+// Authorization header, with an API key in "Authorization: ApiKey ..." or
+// X-API-Key, or, in session mode, with the session cookie, and sets the
+// model.Principal on the context. Activity of login sessions is recorded
+// through sessions.
+func JWTAuthMiddleware(revoker *auth.Revoker, apiKeys *auth.APIKeyVerifier, sessions *auth.SessionManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := apiKeyFromRequest(c); key != "" {
+			authenticateAPIKey(c, apiKeys, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && sessions != nil && sessions.Cookie {
+			if cookie, err := c.Cookie(auth.SessionCookie); err == nil && cookie != "" {
+				authenticateSession(c, sessions, cookie)
+				return
+			}
+		}
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
 			return
@@ -31,14 +51,177 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Safely extract user_uuid
-		userUUID, ok := claims["user_uuid"].(string)
-		if !ok {
+		// Tokens issued to OAuth clients are meant for their audience only
+		if _, ok := claims["aud"]; ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+
+		// User tokens carry user_uuid, service account tokens sub and sub_type
+		principal := &model.Principal{Type: model.PrincipalUser}
+		if claims["sub_type"] == model.PrincipalService {
+			principal.Type = model.PrincipalService
+			principal.UUID, _ = claims["sub"].(string)
+		} else {
+			principal.UUID, _ = claims["user_uuid"].(string)
+		}
+		if principal.UUID == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing user_uuid in token"})
 			return
 		}
 
-		c.Set("user_uuid", userUUID)
+		jti, _ := claims["jti"].(string)
+		issuedAt, err := claims.GetIssuedAt()
+		if jti == "" || err != nil || issuedAt == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+		// iat is decoded from a float; round off the error so a token issued
+		// in the same millisecond as a cutoff is not treated as older.
+		if revoker.IsRevoked(jti, principal.UUID, issuedAt.Round(time.Millisecond)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+
+		// sid is decoded from a float too; tokens from before sessions have none
+		if sid, _ := claims["sid"].(float64); sid != 0 {
+			principal.SessionID = int(sid)
+			if revoker.IsSessionRevoked(principal.SessionID, issuedAt.Round(time.Millisecond)) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				return
+			}
+			if sessions != nil {
+				sessions.Seen(c.Request.Context(), principal.SessionID)
+			}
+		}
+
+		principal.TokenID = jti
+		principal.ExpiresAt = time.Now().Add(auth.AccessTokenTTL)
+		if expiresAt, _ := claims.GetExpirationTime(); expiresAt != nil {
+			principal.ExpiresAt = expiresAt.Time
+		}
+		principal.Roles = stringSliceClaim(claims, "roles")
+		principal.Permissions = stringSliceClaim(claims, "permissions")
+		setPrincipal(c, principal)
 		c.Next()
 	}
 }
+
+// authenticateAPIKey is JWTAuthMiddleware for requests with an API key.
+// Routes can tell them apart by the APIKeyID of the principal.
+func authenticateAPIKey(c *gin.Context, apiKeys *auth.APIKeyVerifier, raw string) {
+	key, roles, permissions, err := apiKeys.Verify(c.Request.Context(), raw)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			return
+		}
+		log.Println("Error verifying API key:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+		return
+	}
+
+	setPrincipal(c, &model.Principal{
+		Type:        model.PrincipalUser,
+		UUID:        key.UserUUID,
+		Roles:       roles,
+		Permissions: permissions,
+		APIKeyID:    key.ID,
+		ExpiresAt:   key.ExpiresAt,
+	})
+	c.Next()
+}
+
+// authenticateSession is JWTAuthMiddleware for requests with the session
+// cookie. Browsers send the cookie with cross-site requests too, so
+// requests that can change state must also carry the CSRF token of the
+// session in a header, which other sites cannot read or set.
+func authenticateSession(c *gin.Context, sessions *auth.SessionManager, token string) {
+	session, roles, permissions, err := sessions.Verify(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidSession) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			return
+		}
+		log.Println("Error verifying session:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !sessions.CheckCSRF(session, c.GetHeader(auth.CSRFHeader)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		}
+	}
+
+	setPrincipal(c, &model.Principal{
+		Type:        model.PrincipalUser,
+		UUID:        session.UserUUID,
+		Roles:       roles,
+		Permissions: permissions,
+		SessionID:   session.ID,
+		ExpiresAt:   session.ExpiresAt,
+	})
+	c.Next()
+}
+
+// setPrincipal stores the caller on the context. The user_uuid, jti,
+// token_exp, roles and permissions keys are kept for handlers that read
+// them directly; user_uuid is only set for users.
+func setPrincipal(c *gin.Context, principal *model.Principal) {
+	c.Set("principal", principal)
+	if principal.IsUser() {
+		c.Set("user_uuid", principal.UUID)
+	}
+	c.Set("jti", principal.TokenID)
+	c.Set("token_exp", principal.ExpiresAt)
+	c.Set("roles", principal.Roles)
+	c.Set("permissions", principal.Permissions)
+}
+
+// CurrentPrincipal returns the caller authenticated by JWTAuthMiddleware,
+// or nil on routes without it.
+func CurrentPrincipal(c *gin.Context) *model.Principal {
+	principal, _ := c.Get("principal")
+	p, _ := principal.(*model.Principal)
+	return p
+}
+
+// RejectAPIKeys keeps API keys away from routes that manage the account
+// itself, such as passwords, second factors and the keys themselves. It
+// must run after JWTAuthMiddleware.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p := CurrentPrincipal(c); p != nil && p.APIKeyID != 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used here, log in instead"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeyFromRequest returns the API key sent with the request, if any.
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "ApiKey "); ok {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// stringSliceClaim reads a JSON array claim, which decodes as []interface{}.
+func stringSliceClaim(claims jwt.MapClaims, key string) []string {
+	values, _ := claims[key].([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fiet/config"
+	"fiet/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc names the caller a request is counted against.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP counts requests per client IP, as resolved through the trusted
+// proxies.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser counts requests per authenticated user or service account, and
+// per IP before JWTAuthMiddleware has run or when it did not authenticate
+// anyone.
+func ByUser(c *gin.Context) string {
+	if principal := CurrentPrincipal(c); principal != nil {
+		return principal.Type + ":" + principal.UUID
+	}
+	return ByIP(c)
+}
+
+// ByAPIKey counts requests per API key, and falls back to ByUser without
+// one. Only a hash of the key ends up in the store.
+func ByAPIKey(c *gin.Context) string {
+	if key := apiKeyFromRequest(c); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+	return ByUser(c)
+}
+
+// RatePolicy is a token bucket that holds Limit requests and refills
+// completely over Period, one request every Period/Limit.
+type RatePolicy struct {
+	// Name keeps the buckets of different policies apart
+	Name   string
+	Limit  int
+	Period time.Duration
+	Key    KeyFunc
+}
+
+// rateResult is the state of a bucket after a request.
+type rateResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// take runs the generic cell rate algorithm, which behaves like a token
+// bucket but only needs the time the bucket is full again (tat).
+func (p RatePolicy) take(tat int64, now time.Time) (int64, rateResult) {
+	interval := p.Period / time.Duration(p.Limit)
+	start := time.Unix(0, max(tat, now.UnixNano()))
+	next := start.Add(interval)
+
+	if allowAt := next.Add(-p.Period); now.Before(allowAt) {
+		return tat, rateResult{reset: start.Sub(now), retryAfter: allowAt.Sub(now)}
+	}
+	return next.UnixNano(), rateResult{
+		allowed:   true,
+		remaining: int((p.Period - next.Sub(now)) / interval),
+		reset:     next.Sub(now),
+	}
+}
+
+// maxRateRetries bounds the compare-and-set loop against a shared store.
+const maxRateRetries = 5
+
+// RateLimit rejects requests beyond policy with 429 Too Many Requests and
+// reports the state of the bucket in RateLimit-* headers. Buckets live in
+// store, which is shared by replicas when it is the database. When several
+// policies apply to a route, the headers describe the one closest to its
+// limit. RATE_LIMIT_ENABLED=false turns every policy off.
+func RateLimit(store repository.RateLimitRepository, policy RatePolicy) gin.HandlerFunc {
+	if !config.Bool("RATE_LIMIT_ENABLED", true) {
+		return func(c *gin.Context) { c.Next() }
+	}
+	if policy.Key == nil {
+		policy.Key = ByIP
+	}
+	window := strconv.Itoa(int(policy.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		bucket := policy.Name + ":" + policy.Key(c)
+		result, err := takeRate(c, store, bucket, policy)
+		if err != nil {
+			// Rather serve the request than fail it for a limiter outage
+			log.Println("Error checking rate limit:", err)
+			c.Next()
+			return
+		}
+
+		if remaining, ok := c.Get("ratelimit_remaining"); !ok || result.remaining <= remaining.(int) {
+			c.Set("ratelimit_remaining", result.remaining)
+			c.Header("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(result.remaining))
+			c.Header("RateLimit-Reset", ceilSeconds(result.reset))
+			c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", policy.Limit, window))
+		}
+		if !result.allowed {
+			c.Header("Retry-After", ceilSeconds(result.retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded, try again later"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func takeRate(c *gin.Context, store repository.RateLimitRepository, bucket string, policy RatePolicy) (rateResult, error) {
+	ctx := c.Request.Context()
+	for retry := 0; retry < maxRateRetries; retry++ {
+		old, err := store.Get(ctx, bucket)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return rateResult{}, err
+		}
+		tat, result := policy.take(old, time.Now())
+		if !result.allowed {
+			return result, nil
+		}
+		err = store.CompareAndSet(ctx, bucket, old, tat)
+		if !errors.Is(err, repository.ErrConflict) {
+			return result, err
+		}
+	}
+	return rateResult{}, errors.New("too much contention on bucket " + bucket)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
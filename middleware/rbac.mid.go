@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission only lets the request through if the access token
+// grants the permission. It must run after JWTAuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the authenticated caller holds permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	principal := CurrentPrincipal(c)
+	return principal != nil && principal.HasPermission(permission)
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets scripts call the API as its owner, with at most the
+// permissions in Scopes. Only a hash of the key is stored.
+type APIKey struct {
+	ID       int    `db:"id"`
+	UserID   int    `db:"user_id"`
+	UserUUID string `db:"user_uuid"` // joined from users
+	Name     string `db:"name"`
+	// Prefix is the start of the key, shown to tell keys apart
+	Prefix  string `db:"prefix"`
+	KeyHash string `db:"key_hash"`
+	// Scopes is the space separated list of permissions the key may use
+	Scopes     string     `db:"scopes"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// Public returns the key as shown to its owner, without the key itself.
+func (k *APIKey) Public() APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     strings.Fields(k.Scopes),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"grade import"`
+	// Scopes are permissions of the caller the key may use; without any the
+	// key can only reach routes that need no permission
+	Scopes []string `json:"scopes" example:"users:list"`
+	// ExpiresInDays defaults to 90
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1,max=365" example:"90"`
+}
+
+type APIKeyResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name" example:"grade import"`
+	Prefix     string     `json:"prefix" example:"fiet_Xb3k9Q"`
+	Scopes     []string   `json:"scopes" example:"users:list"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Key is only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// LoginAttempt counts the recent failed logins of one subject, an account
+// or a client IP.
+type LoginAttempt struct {
+	Subject       string     `db:"subject"`
+	Failures      int        `db:"failures"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, which can then log in as the user.
+type UserIdentity struct {
+	ID       int    `db:"id" json:"id"`
+	UserID   int    `db:"user_id" json:"-"`
+	UserUUID string `db:"user_uuid" json:"-"` // joined from users
+	// Provider is the name the provider is configured under
+	Provider string `db:"provider" json:"provider" example:"google"`
+	// Subject is the sub claim, which identifies the account at the provider
+	Subject string `db:"subject" json:"-"`
+	// Email is the address the provider reported at the last login
+	Email       string     `db:"email" json:"email"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at" json:"last_login_at,omitempty"`
+}
+
+// Purposes of OIDCState.
+const (
+	OIDCLogin = "login"
+	OIDCLink  = "link"
+)
+
+// OIDCState holds an authorization request between sending the browser to
+// the provider and its return. UserID is only set when linking.
+type OIDCState struct {
+	ID           int       `db:"id"`
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Purpose      string    `db:"purpose"`
+	UserID       *int      `db:"user_id"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// OIDCProviderInfo describes a provider users can log in with.
+type OIDCProviderInfo struct {
+	Name        string `json:"name" example:"google"`
+	DisplayName string `json:"display_name" example:"Google"`
+}
+
+// OIDCBeginResponse carries the provider URL to send the browser to.
+type OIDCBeginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	ExpiresIn        int64  `json:"expires_in" example:"600"`
+}
+
+type OIDCLinkRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// OIDCFinishRequest carries the query parameters the provider redirected
+// the browser back with.
+type OIDCFinishRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
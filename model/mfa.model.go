@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// TOTPCredential is the TOTP secret of a user. It only counts as a second
+// factor once ConfirmedAt is set.
+type TOTPCredential struct {
+	UserID      int        `db:"user_id"`
+	Secret      string     `db:"secret"` // base32
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	LastStep    *int64     `db:"last_step"` // last accepted time step
+	CreatedAt   time.Time  `db:"created_at"`
+}
+
+// Second factors listed in MFAChallengeResponse.Methods.
+const (
+	MFAMethodTOTP     = "totp" // also accepts recovery codes
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// MFAChallengeResponse is returned by login instead of a token pair when
+// the account has a second factor.
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required" example:"true"`
+	MFAToken    string   `json:"mfa_token"`
+	ExpiresIn   int64    `json:"expires_in"` // challenge lifetime in seconds
+	Methods     []string `json:"methods" example:"totp,webauthn"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is a TOTP code or one of the recovery codes
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type PasswordConfirmation struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+// TOTPEnrollment is everything an authenticator app needs to add the
+// account, either typed in, from the URI or scanned from the QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"otpauth_uri" example:"otpauth://totp/Fiet:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Fiet"`
+	QRCode string `json:"qr_code" example:"data:image/png;base64,iVBORw0KGgo..."`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatus struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	WebAuthnCredentials    int  `json:"webauthn_credentials"`
+}
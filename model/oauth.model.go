@@ -0,0 +1,127 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// OAuth scopes a client can request. openid is required; the others
+// select the user claims in the ID token and at the userinfo endpoint.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthClient is an app that lets users sign in with their account.
+// Public clients, which cannot keep a secret, have no SecretHash.
+type OAuthClient struct {
+	ID         int     `db:"id"`
+	ClientID   string  `db:"client_id"`
+	SecretHash *string `db:"client_secret_hash"`
+	Name       string  `db:"name"`
+	// RedirectURIs is the space separated list of allowed redirect URIs
+	RedirectURIs string    `db:"redirect_uris"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// AllowsRedirect reports whether uri is registered, compared exactly.
+func (c *OAuthClient) AllowsRedirect(uri string) bool {
+	return slices.Contains(strings.Fields(c.RedirectURIs), uri)
+}
+
+// Public returns the client as shown to admins.
+func (c *OAuthClient) Public() OAuthClientResponse {
+	return OAuthClientResponse{
+		ClientID:     c.ClientID,
+		Name:         c.Name,
+		RedirectURIs: strings.Fields(c.RedirectURIs),
+		Confidential: c.SecretHash != nil,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// OAuthRequest is an authorization request waiting for the user to
+// consent on the frontend.
+type OAuthRequest struct {
+	ID            int       `db:"id"`
+	RequestHash   string    `db:"request_hash"`
+	ClientID      string    `db:"client_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"` // space separated
+	State         string    `db:"state"`
+	Nonce         string    `db:"nonce"`
+	CodeChallenge string    `db:"code_challenge"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+// OAuthCode is an authorization code the user consented to. Only its hash
+// is stored.
+type OAuthCode struct {
+	ID            int       `db:"id"`
+	CodeHash      string    `db:"code_hash"`
+	ClientID      string    `db:"client_id"`
+	UserID        int       `db:"user_id"`
+	UserUUID      string    `db:"user_uuid"` // joined from users
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"`
+	Nonce         string    `db:"nonce"`
+	CodeChallenge string    `db:"code_challenge"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+type OAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100" example:"Course Portal"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url" example:"https://portal.example.com/callback"`
+	// Public clients, such as single page apps, get no secret and must
+	// use PKCE, which every client does anyway
+	Public bool `json:"public"`
+}
+
+type OAuthClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+	// ClientSecret is only returned when the client is registered
+	ClientSecret string    `json:"client_secret,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OAuthRequestInfo is what the consent screen shows.
+type OAuthRequestInfo struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name" example:"Course Portal"`
+	Scopes     []string `json:"scopes" example:"openid,profile,email"`
+	// Consented is true when the user already agreed to these scopes, so
+	// the frontend may approve without asking again
+	Consented bool      `json:"consented"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type OAuthConsentRequest struct {
+	Approve bool `json:"approve"`
+}
+
+// OAuthConsentResponse tells the frontend where to send the browser: back
+// to the client with a code, or with an error if the user declined.
+type OAuthConsentResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuthTokenResponse is the token endpoint response (RFC 6749 section 5.1).
+// Service accounts get no ID token.
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	ExpiresIn   int64  `json:"expires_in" example:"900"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope" example:"openid profile email"`
+}
+
+// OAuthError is an OAuth2 error response (RFC 6749 section 5.2).
+type OAuthError struct {
+	Error       string `json:"error" example:"invalid_grant"`
+	Description string `json:"error_description,omitempty"`
+}
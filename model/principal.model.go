@@ -0,0 +1,41 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Kinds of Principal, also the sub_type claim of access tokens.
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
+// Principal is whoever made an authenticated request: a user, with an
+// access token, a session cookie or an API key, or a service account.
+type Principal struct {
+	Type string
+	// UUID is the user or service account UUID
+	UUID        string
+	Roles       []string
+	Permissions []string
+	// TokenID is the jti of the access token; empty for API keys
+	TokenID string
+	// APIKeyID is set when a user authenticated with an API key
+	APIKeyID int
+	// SessionID is the login session of the session cookie or access token
+	SessionID int
+	// ExpiresAt is when the credential stops working
+	ExpiresAt time.Time
+}
+
+// IsUser reports whether the principal is a user rather than a service
+// account.
+func (p *Principal) IsUser() bool {
+	return p.Type == PrincipalUser
+}
+
+// HasPermission reports whether the principal holds permission.
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
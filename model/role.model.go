@@ -0,0 +1,20 @@
+package model
+
+// Built-in roles, seeded by the 0004_create_roles migration.
+const (
+	RoleStudent  = "student"
+	RoleLecturer = "lecturer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+type Role struct {
+	ID          int      `db:"id" json:"-"`
+	Name        string   `db:"name" json:"name"`
+	Description *string  `db:"description" json:"description"`
+	Permissions []string `db:"-" json:"permissions"`
+}
+
+type RoleAssignment struct {
+	Role string `json:"role" binding:"required" example:"lecturer"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// ServiceAccount is a non-human principal, such as a batch job, that gets
+// access tokens with its client credentials instead of logging in.
+type ServiceAccount struct {
+	ID          int    `db:"id"`
+	UUID        string `db:"uuid"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	ClientID    string `db:"client_id"`
+	SecretHash  string `db:"client_secret_hash"`
+	// Permissions is the space separated list of permissions its tokens carry
+	Permissions string     `db:"permissions"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastTokenAt *time.Time `db:"last_token_at"`
+}
+
+// Public returns the account as shown to admins.
+func (s *ServiceAccount) Public() ServiceAccountResponse {
+	return ServiceAccountResponse{
+		UUID:        s.UUID,
+		Name:        s.Name,
+		Description: s.Description,
+		ClientID:    s.ClientID,
+		Permissions: strings.Fields(s.Permissions),
+		CreatedAt:   s.CreatedAt,
+		LastTokenAt: s.LastTokenAt,
+	}
+}
+
+type ServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required,max=100" example:"LMS sync"`
+	Description string `json:"description" binding:"max=500" example:"Nightly enrolment sync"`
+	// Permissions must be held by the admin creating the account
+	Permissions []string `json:"permissions" example:"users:list"`
+}
+
+type ServiceAccountResponse struct {
+	UUID        string   `json:"uuid"`
+	Name        string   `json:"name" example:"LMS sync"`
+	Description string   `json:"description,omitempty"`
+	ClientID    string   `json:"client_id" example:"svc_Xb3k9Q..."`
+	Permissions []string `json:"permissions" example:"users:list"`
+	// ClientSecret is only returned when the account is created or its
+	// secret is rotated
+	ClientSecret string     `json:"client_secret,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastTokenAt  *time.Time `json:"last_token_at,omitempty"`
+}
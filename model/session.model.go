@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// Session is one login of a user, listed as an active device. In session
+// mode it is referenced by the session cookie; in token mode FamilyID links
+// it to its refresh tokens and access tokens carry its ID as sid. Only
+// hashes of the session and CSRF tokens are stored.
+type Session struct {
+	ID          int    `db:"id"`
+	SessionHash string `db:"session_hash"`
+	CSRFHash    string `db:"csrf_hash"`
+	UserID      int    `db:"user_id"`
+	UserUUID    string `db:"user_uuid"` // joined from users
+	// DeviceName is derived from the User-Agent, e.g. "Firefox on Windows"
+	DeviceName string    `db:"device_name"`
+	IPAddress  string    `db:"ip_address"`
+	FamilyID   *string   `db:"family_id"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	// ExpiresAt moves forward with activity, up to the maximum session age,
+	// or with each refresh in token mode
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Public returns the session as listed to its user; current marks the
+// session of the request.
+func (s *Session) Public(current bool) SessionInfo {
+	return SessionInfo{
+		ID:         s.ID,
+		DeviceName: s.DeviceName,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    current,
+	}
+}
+
+type SessionInfo struct {
+	ID         int       `json:"id" example:"12"`
+	DeviceName string    `json:"device_name" example:"Firefox on Windows"`
+	IPAddress  string    `json:"ip_address" example:"161.246.4.10"`
+	CreatedAt  time.Time `json:"created_at"`
+	// LastSeenAt is updated at most once a minute
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionResponse is returned by the logins in session mode instead of a
+// token pair; the session itself is in an HttpOnly cookie.
+type SessionResponse struct {
+	// CSRFToken must be sent as X-CSRF-Token with every state-changing
+	// request; it is also set in the readable csrf_token cookie
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// RefreshToken is an opaque, rotating refresh token. Tokens issued from
+// one login share a FamilyID; each refresh replaces the presented token
+// with a new one in the same family.
+type RefreshToken struct {
+	ID         int        `db:"id"`
+	UserID     int        `db:"user_id"`
+	UserUUID   string     `db:"user_uuid"` // joined from users
+	FamilyID   string     `db:"family_id"`
+	TokenHash  string     `db:"token_hash"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	ReplacedBy *int       `db:"replaced_by"` // set when rotated
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"3q2-7wR4..."`
+}
+
+// RevokedToken is an access token revoked before it expired.
+type RevokedToken struct {
+	JTI       string    `db:"jti"`
+	UserUUID  string    `db:"user_uuid"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// TokenCutoff revokes every token of a user issued before NotBefore.
+type TokenCutoff struct {
+	UserUUID  string    `db:"user_uuid"`
+	NotBefore time.Time `db:"not_before"`
+}
+
+// Purposes of UserToken.
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeMFAChallenge      = "mfa_challenge"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// UserToken is a single-use token handed to a user, e.g. in a password
+// reset link or as an MFA challenge. Only its hash is stored.
+type UserToken struct {
+	ID        int        `db:"id"`
+	UserID    int        `db:"user_id"`
+	UserUUID  string     `db:"user_uuid"` // joined from users
+	Purpose   string     `db:"purpose"`
+	Email     string     `db:"email"` // address the token was mailed to
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	Attempts  int        `db:"attempts"` // failed attempts, see UserTokenRepository.Fail
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
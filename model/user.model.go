@@ -13,6 +13,33 @@ type User struct {
 	Password  string    `db:"password_hash" json:"password"` // Hashed password
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// EmailVerifiedAt is set once the owner of Email has confirmed it
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
+	// DisabledAt is set while an admin has disabled the account
+	DisabledAt *time.Time `db:"disabled_at" json:"disabled_at,omitempty"`
+	// PasswordResetRequired blocks login until the password is changed
+	PasswordResetRequired bool `db:"password_reset_required" json:"password_reset_required"`
+	// DeletedAt is set when the account is deleted; it can be restored
+	// until the grace period has passed
+	DeletedAt *time.Time `db:"deleted_at" json:"-"`
+	// PurgedAt is set once the personal data of a deleted account is wiped
+	PurgedAt *time.Time `db:"purged_at" json:"-"`
+}
+
+// Public returns the fields of the user that are safe to expose.
+func (u *User) Public() PublicUser {
+	return PublicUser{
+		UUID:      u.UUID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Age:       u.Age,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+
+		EmailVerifiedAt:       u.EmailVerifiedAt,
+		DisabledAt:            u.DisabledAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+	}
 }
 
 type PublicUser struct {
@@ -22,6 +49,24 @@ type PublicUser struct {
 	Age       *int64    `db:"age" json:"age"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	EmailVerifiedAt       *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
+	DisabledAt            *time.Time `db:"disabled_at" json:"disabled_at,omitempty"`
+	PasswordResetRequired bool       `db:"password_reset_required" json:"password_reset_required"`
+}
+
+// UserListQuery holds the query parameters of GET /users.
+type UserListQuery struct {
+	Limit         int        `form:"limit,default=20" binding:"min=1,max=100"`
+	Page          int        `form:"page" binding:"omitempty,min=1"`
+	Cursor        string     `form:"cursor"`
+	Sort          string     `form:"sort,default=created_at"` // field name, prefixed with '-' for descending
+	Name          string     `form:"name"`
+	Email         string     `form:"email"`
+	MinAge        *int64     `form:"min_age" binding:"omitempty,min=0"`
+	MaxAge        *int64     `form:"max_age" binding:"omitempty,min=0"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
 type Credential struct {
@@ -29,6 +74,13 @@ type Credential struct {
 	Password string `json:"password" binding:"required,min=8,max=64" example:"supersecure123"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
 }
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+type WebAuthnCredential struct {
+	ID              int        `db:"id" json:"id"`
+	UserID          int        `db:"user_id" json:"-"`
+	CredentialID    string     `db:"credential_id" json:"credential_id"` // base64url
+	PublicKey       []byte     `db:"public_key" json:"-"`
+	AttestationType string     `db:"attestation_type" json:"-"`
+	AAGUID          []byte     `db:"aaguid" json:"-"`
+	Transports      string     `db:"transports" json:"-"` // comma separated
+	SignCount       int64      `db:"sign_count" json:"-"`
+	BackupEligible  bool       `db:"backup_eligible" json:"-"`
+	BackupState     bool       `db:"backup_state" json:"synced"`
+	Name            string     `db:"name" json:"name"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt      *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+}
+
+// Purposes of WebAuthnSession.
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+	WebAuthnMFA          = "mfa"
+)
+
+// WebAuthnSession holds the challenge of a ceremony between its begin and
+// finish requests. UserID is nil for passkey logins.
+type WebAuthnSession struct {
+	ID        int       `db:"id"`
+	TokenHash string    `db:"token_hash"`
+	Purpose   string    `db:"purpose"`
+	UserID    *int      `db:"user_id"`
+	Data      string    `db:"data"` // JSON of webauthn.SessionData
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type WebAuthnRegisterRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Name tells the user's credentials apart, e.g. "Pixel 8"
+	Name string `json:"name" binding:"required,max=100" example:"Pixel 8"`
+}
+
+type WebAuthnMFABeginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// WebAuthnBeginResponse carries the options for navigator.credentials and
+// the token that ties the finish request to this ceremony.
+type WebAuthnBeginResponse struct {
+	SessionToken string      `json:"session_token"`
+	Options      interface{} `json:"options" swaggertype:"object"`
+}
+
+// WebAuthnFinishRequest carries the PublicKeyCredential returned by
+// navigator.credentials, serialized as JSON.
+type WebAuthnFinishRequest struct {
+	SessionToken string          `json:"session_token" binding:"required"`
+	MFAToken     string          `json:"mfa_token,omitempty"` // only when finishing an MFA challenge
+	Credential   json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
@@ -0,0 +1,138 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"fiet/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms a Hasher can create hashes with.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// Argon2Params are the cost parameters of argon2id.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes new passwords with Algorithm and verifies hashes of every
+// supported algorithm. Hashes are self-describing strings: the PHC format
+// for argon2id ($argon2id$v=19$m=...,t=...,p=...$salt$hash) and the
+// modular crypt format for bcrypt ($2a$10$...).
+type Hasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// LoadHasher reads the hashing parameters from PASSWORD_HASH_* environment
+// variables. The argon2id defaults follow the OWASP recommendation of
+// 19 MiB of memory and two iterations.
+func LoadHasher() Hasher {
+	h := Hasher{
+		Algorithm: strings.ToLower(os.Getenv("PASSWORD_HASH_ALGORITHM")),
+		Argon2: Argon2Params{
+			Memory:      uint32(config.Int("PASSWORD_HASH_ARGON2_MEMORY", 19*1024)),
+			Iterations:  uint32(config.Int("PASSWORD_HASH_ARGON2_ITERATIONS", 2)),
+			Parallelism: uint8(config.Int("PASSWORD_HASH_ARGON2_PARALLELISM", 1)),
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: config.Int("PASSWORD_HASH_BCRYPT_COST", bcrypt.DefaultCost),
+	}
+	if h.Algorithm == "" {
+		h.Algorithm = Argon2id
+	}
+	if h.Algorithm != Argon2id && h.Algorithm != Bcrypt {
+		log.Printf("Invalid PASSWORD_HASH_ALGORITHM %q, using %s", h.Algorithm, Argon2id)
+		h.Algorithm = Argon2id
+	}
+	return h
+}
+
+// Hash hashes password with the configured algorithm.
+func (h Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+
+	p := h.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches hash. Malformed hashes, such as
+// the empty hash of a purged account, match nothing.
+func (h Hasher) Verify(password string, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or
+// other costs than the ones configured now.
+func (h Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, _, _, err := decodeArgon2(hash)
+		return err != nil || h.Algorithm != Argon2id ||
+			p.Memory != h.Argon2.Memory || p.Iterations != h.Argon2.Iterations ||
+			p.Parallelism != h.Argon2.Parallelism || p.KeyLength != h.Argon2.KeyLength
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || h.Algorithm != Bcrypt || cost != h.BcryptCost
+}
+
+// decodeArgon2 parses a PHC argon2id hash.
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
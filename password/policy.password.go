@@ -0,0 +1,145 @@
+package password
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fiet/config"
+)
+
+// bcrypt ignores everything after the first 72 bytes.
+const maxBytes = 72
+
+//go:embed banned.txt
+var bannedList string
+
+var banned = parseBanned(bannedList)
+
+func parseBanned(list string) map[string]bool {
+	words := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words[line] = true
+		}
+	}
+	return words
+}
+
+// Policy is the set of rules a new password must satisfy.
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// History is how many of the latest passwords, including the current
+	// one, cannot be chosen again. 0 or 1 only rejects the current one.
+	History int
+}
+
+// LoadPolicy reads the policy from PASSWORD_* environment variables.
+func LoadPolicy() Policy {
+	return Policy{
+		MinLength:     config.Int("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:     config.Int("PASSWORD_MAX_LENGTH", 64),
+		RequireUpper:  config.Bool("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:  config.Bool("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:  config.Bool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol: config.Bool("PASSWORD_REQUIRE_SYMBOL", false),
+		History:       config.Int("PASSWORD_HISTORY", 5),
+	}
+}
+
+// PolicyError lists every rule a password breaks.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate checks password against the policy for the account with the
+// given email and returns a *PolicyError if it breaks any rule.
+func (p Policy) Validate(password string, email string) error {
+	violations := []string{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	} else if len(password) > maxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", maxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if isBanned(password) {
+		violations = append(violations, "is too common")
+	}
+	if email != "" && containsEmail(password, email) {
+		violations = append(violations, "must not contain the email address")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// isBanned reports whether password is a banned word, ignoring case and
+// the digits and symbols people tend to append ("Password123!").
+func isBanned(password string) bool {
+	lower := strings.ToLower(password)
+	if banned[lower] {
+		return true
+	}
+	stem := strings.TrimRightFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return stem != "" && banned[stem]
+}
+
+// containsEmail reports whether password is the email address or contains
+// its local part.
+func containsEmail(password string, email string) bool {
+	lower := strings.ToLower(password)
+	email = strings.ToLower(email)
+	if lower == email {
+		return true
+	}
+	local, _, _ := strings.Cut(email, "@")
+	// Very short local parts would reject too many passwords
+	return len(local) >= 3 && strings.Contains(lower, local)
+}
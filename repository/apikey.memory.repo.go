@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryAPIKeyRepository keeps API keys in a map. UserUUID is stored as
+// given to Create since there is no users table to join.
+type MemoryAPIKeyRepository struct {
+	mu     sync.Mutex
+	keys   map[int]*model.APIKey
+	nextID int
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: make(map[int]*model.APIKey)}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if k.KeyHash == key.KeyHash {
+			return ErrConflict
+		}
+	}
+	r.nextID++
+	key.ID = r.nextID
+	key.CreatedAt = time.Now()
+	stored := *key
+	r.keys[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range r.keys {
+		if k.KeyHash == keyHash && k.ExpiresAt.After(now) {
+			key := *k
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryAPIKeyRepository) List(ctx context.Context, userID int) ([]model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []model.APIKey{}
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			keys = append(keys, *k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Delete(ctx context.Context, userID int, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.keys[id]
+	if !ok || k.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) Touch(ctx context.Context, id int, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	k.LastUsedAt = &now
+	return nil
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fiet/model"
+)
+
+// APIKeyRepository stores the personal API keys of users.
+type APIKeyRepository interface {
+	// Create stores a key and fills in its ID and CreatedAt.
+	Create(ctx context.Context, key *model.APIKey) error
+	// GetByHash finds an unexpired key by the hash of the key.
+	GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	List(ctx context.Context, userID int) ([]model.APIKey, error)
+	// Delete revokes a key of the user.
+	Delete(ctx context.Context, userID int, id int) error
+	// Touch records that the key was used at now.
+	Touch(ctx context.Context, id int, now time.Time) error
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const apiKeyColumns = `k.id, k.user_id, u.uuid AS user_uuid, k.name, k.prefix, k.key_hash, k.scopes,
+	k.expires_at, k.last_used_at, k.created_at`
+
+type SQLAPIKeyRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLAPIKeyRepository(database *sqlx.DB) *SQLAPIKeyRepository {
+	return &SQLAPIKeyRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	query := r.Dialect.InsertReturning("api_keys",
+		[]string{"user_id", "name", "prefix", "key_hash", "scopes", "expires_at"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"user_id":    key.UserID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"key_hash":   key.KeyHash,
+		"scopes":     key.Scopes,
+		"expires_at": key.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&key.ID, &key.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + `
+	FROM api_keys k
+	JOIN users u ON u.id = k.user_id
+	WHERE k.key_hash = :key_hash AND k.expires_at > :now`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var key model.APIKey
+	err = stmt.GetContext(ctx, &key, map[string]interface{}{
+		"key_hash": keyHash,
+		"now":      time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &key, nil
+}
+
+func (r *SQLAPIKeyRepository) List(ctx context.Context, userID int) ([]model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + `
+	FROM api_keys k
+	JOIN users u ON u.id = k.user_id
+	WHERE k.user_id = :user_id
+	ORDER BY k.id`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	keys := []model.APIKey{}
+	if err := stmt.SelectContext(ctx, &keys, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *SQLAPIKeyRepository) Delete(ctx context.Context, userID int, id int) error {
+	query := "DELETE FROM api_keys WHERE id = :id AND user_id = :user_id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":      id,
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLAPIKeyRepository) Touch(ctx context.Context, id int, now time.Time) error {
+	query := "UPDATE api_keys SET last_used_at = :now WHERE id = :id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":  id,
+		"now": now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*model.LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: make(map[string]*model.LoginAttempt)}
+}
+
+func (r *MemoryLoginAttemptRepository) Get(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	attempt := *a
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, subject string, since time.Time) (*model.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for s, a := range r.attempts {
+		if a.LastFailureAt.Before(since) && (a.LockedUntil == nil || a.LockedUntil.Before(now)) {
+			delete(r.attempts, s)
+		}
+	}
+
+	a, ok := r.attempts[subject]
+	if !ok {
+		a = &model.LoginAttempt{Subject: subject}
+		r.attempts[subject] = a
+	}
+	if a.LastFailureAt.Before(since) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	attempt := *a
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, subject string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[subject]
+	if !ok {
+		return ErrNotFound
+	}
+	a.LockedUntil = &until
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, subject)
+	return nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fiet/model"
+)
+
+// LoginAttemptRepository stores failed login counters. The SQL
+// implementation is shared by every replica, the memory one is per
+// process.
+type LoginAttemptRepository interface {
+	// Get returns the counter of subject, or ErrNotFound if it has none.
+	Get(ctx context.Context, subject string) (*model.LoginAttempt, error)
+	// RecordFailure counts a failed login of subject and returns the new
+	// counter. Failures before since are forgotten.
+	RecordFailure(ctx context.Context, subject string, since time.Time) (*model.LoginAttempt, error)
+	// Lock refuses logins of subject until the given time.
+	Lock(ctx context.Context, subject string, until time.Time) error
+	// Reset forgets the failures of subject and lifts its lock.
+	Reset(ctx context.Context, subject string) error
+}
+
+// AccountAttemptSubject is the subject that counts the failed logins of the
+// account with email.
+func AccountAttemptSubject(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
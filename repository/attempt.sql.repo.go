@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLLoginAttemptRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLLoginAttemptRepository(database *sqlx.DB) *SQLLoginAttemptRepository {
+	return &SQLLoginAttemptRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLLoginAttemptRepository) Get(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	query := `
+	SELECT subject, failures, last_failure_at, locked_until
+	FROM login_attempts WHERE subject = :subject
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var attempt model.LoginAttempt
+	if err := stmt.GetContext(ctx, &attempt, map[string]interface{}{"subject": subject}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &attempt, nil
+}
+
+func (r *SQLLoginAttemptRepository) RecordFailure(ctx context.Context, subject string, since time.Time) (*model.LoginAttempt, error) {
+	now := time.Now().UTC()
+	params := map[string]interface{}{
+		"subject": subject,
+		"since":   since.UTC(),
+		"now":     now,
+	}
+
+	// Forget subjects that have been quiet for a while, so IPs do not pile up
+	_, err := r.DB.NamedExecContext(ctx, `
+	DELETE FROM login_attempts
+	WHERE last_failure_at < :since AND (locked_until IS NULL OR locked_until < :now)
+	`, params)
+	if err != nil {
+		return nil, err
+	}
+
+	update := `
+	UPDATE login_attempts
+	SET failures = CASE WHEN last_failure_at < :since THEN 1 ELSE failures + 1 END,
+		last_failure_at = :now
+	WHERE subject = :subject
+	`
+	insert := `
+	INSERT INTO login_attempts (subject, failures, last_failure_at)
+	VALUES (:subject, 1, :now)
+	`
+	// The increment is a single statement so that concurrent failures on
+	// several replicas are all counted. If the row does not exist yet,
+	// another replica may insert it first; the update then wins on retry.
+	for retry := 0; retry < 2; retry++ {
+		result, err := r.DB.NamedExecContext(ctx, update, params)
+		if err != nil {
+			return nil, err
+		}
+		if err := requireRows(result); !errors.Is(err, ErrNotFound) {
+			if err != nil {
+				return nil, err
+			}
+			return r.Get(ctx, subject)
+		}
+
+		_, err = r.DB.NamedExecContext(ctx, insert, params)
+		if err == nil {
+			return r.Get(ctx, subject)
+		}
+		if err = mapError(r.Dialect, err); !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+	}
+	return nil, ErrConflict
+}
+
+func (r *SQLLoginAttemptRepository) Lock(ctx context.Context, subject string, until time.Time) error {
+	query := "UPDATE login_attempts SET locked_until = :until WHERE subject = :subject"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"subject": subject,
+		"until":   until.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLLoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM login_attempts WHERE subject = :subject",
+		map[string]interface{}{"subject": subject})
+	return err
+}
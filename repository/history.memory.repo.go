@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+type MemoryPasswordHistoryRepository struct {
+	mu     sync.RWMutex
+	hashes map[int][]string // user ID -> hashes, oldest first
+}
+
+func NewMemoryPasswordHistoryRepository() *MemoryPasswordHistoryRepository {
+	return &MemoryPasswordHistoryRepository{
+		hashes: make(map[int][]string),
+	}
+}
+
+func (r *MemoryPasswordHistoryRepository) Add(ctx context.Context, userID int, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hashes[userID] = append(r.hashes[userID], passwordHash)
+	return nil
+}
+
+func (r *MemoryPasswordHistoryRepository) Recent(ctx context.Context, userID int, n int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.hashes[userID]
+	hashes := []string{}
+	for i := len(stored) - 1; i >= 0 && len(hashes) < n; i-- {
+		hashes = append(hashes, stored[i])
+	}
+	return hashes, nil
+}
+
+func (r *MemoryPasswordHistoryRepository) Trim(ctx context.Context, userID int, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.hashes[userID]
+	if len(stored) > keep {
+		r.hashes[userID] = append([]string(nil), stored[len(stored)-max(keep, 0):]...)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "context"
+
+// PasswordHistoryRepository remembers previous password hashes of users.
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, userID int, passwordHash string) error
+	// Recent returns up to n hashes of the user, newest first.
+	Recent(ctx context.Context, userID int, n int) ([]string, error)
+	// Trim drops all but the newest keep hashes of the user.
+	Trim(ctx context.Context, userID int, keep int) error
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	db "fiet/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLPasswordHistoryRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLPasswordHistoryRepository(database *sqlx.DB) *SQLPasswordHistoryRepository {
+	return &SQLPasswordHistoryRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLPasswordHistoryRepository) Add(ctx context.Context, userID int, passwordHash string) error {
+	query := "INSERT INTO password_history (user_id, password_hash) VALUES (:user_id, :password_hash)"
+
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id":       userID,
+		"password_hash": passwordHash,
+	})
+	return err
+}
+
+func (r *SQLPasswordHistoryRepository) Recent(ctx context.Context, userID int, n int) ([]string, error) {
+	hashes := []string{}
+	if n <= 0 {
+		return hashes, nil
+	}
+
+	query := `
+	SELECT password_hash FROM password_history
+	WHERE user_id = :user_id
+	ORDER BY id DESC
+	` + r.Dialect.Paginate()
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	params := map[string]interface{}{"user_id": userID, "limit": n, "offset": 0}
+	if err := stmt.SelectContext(ctx, &hashes, params); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (r *SQLPasswordHistoryRepository) Trim(ctx context.Context, userID int, keep int) error {
+	query := "DELETE FROM password_history WHERE user_id = :user_id"
+	if keep > 0 {
+		query += `
+		AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = :user_id
+			ORDER BY id DESC
+			` + r.Dialect.Paginate() + `
+		)`
+	}
+
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"limit":   keep,
+		"offset":  0,
+	})
+	return err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryIdentityRepository keeps identities in a map. UserUUID is stored
+// as given to Create since there is no users table to join.
+type MemoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[int]*model.UserIdentity
+	states     map[string]*model.OIDCState // keyed by state hash
+	nextID     int
+	nextState  int
+}
+
+func NewMemoryIdentityRepository() *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{
+		identities: make(map[int]*model.UserIdentity),
+		states:     make(map[string]*model.OIDCState),
+	}
+}
+
+func (r *MemoryIdentityRepository) Get(ctx context.Context, provider string, subject string) (*model.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			identity := *i
+			return &identity, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryIdentityRepository) List(ctx context.Context, userID int) ([]model.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identities := []model.UserIdentity{}
+	for _, i := range r.identities {
+		if i.UserID == userID {
+			identities = append(identities, *i)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities, nil
+}
+
+func (r *MemoryIdentityRepository) Create(ctx context.Context, identity *model.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return ErrConflict
+		}
+	}
+	r.nextID++
+	identity.ID = r.nextID
+	identity.CreatedAt = time.Now()
+	stored := *identity
+	r.identities[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemoryIdentityRepository) RecordLogin(ctx context.Context, id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.identities[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	i.Email = email
+	i.LastLoginAt = &now
+	return nil
+}
+
+func (r *MemoryIdentityRepository) Delete(ctx context.Context, userID int, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.identities[id]
+	if !ok || i.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.identities, id)
+	return nil
+}
+
+func (r *MemoryIdentityRepository) CreateState(ctx context.Context, state *model.OIDCState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, s := range r.states {
+		if !s.ExpiresAt.After(now) {
+			delete(r.states, hash)
+		}
+	}
+	if _, ok := r.states[state.StateHash]; ok {
+		return ErrConflict
+	}
+	r.nextState++
+	state.ID = r.nextState
+	stored := *state
+	r.states[stored.StateHash] = &stored
+	return nil
+}
+
+func (r *MemoryIdentityRepository) TakeState(ctx context.Context, purpose string, stateHash string) (*model.OIDCState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.states[stateHash]
+	if !ok || s.Purpose != purpose || !s.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	delete(r.states, stateHash)
+	state := *s
+	return &state, nil
+}
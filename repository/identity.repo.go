@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// IdentityRepository stores the external OpenID Connect accounts linked
+// to users and the authorization requests still waiting for the provider.
+type IdentityRepository interface {
+	// Get finds the identity of subject at provider.
+	Get(ctx context.Context, provider string, subject string) (*model.UserIdentity, error)
+	List(ctx context.Context, userID int) ([]model.UserIdentity, error)
+	// Create links an identity and fills in its ID and CreatedAt. It
+	// returns ErrConflict if the identity is already linked to a user.
+	Create(ctx context.Context, identity *model.UserIdentity) error
+	// RecordLogin stores the time of a login and the email reported with it.
+	RecordLogin(ctx context.Context, id int, email string) error
+	// Delete unlinks an identity of the user.
+	Delete(ctx context.Context, userID int, id int) error
+	// CreateState stores a new authorization request.
+	CreateState(ctx context.Context, state *model.OIDCState) error
+	// TakeState removes and returns an unexpired authorization request of
+	// purpose, so each state can be used only once.
+	TakeState(ctx context.Context, purpose string, stateHash string) (*model.OIDCState, error)
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const identityColumns = `i.id, i.user_id, u.uuid AS user_uuid, i.provider, i.subject, i.email,
+	i.created_at, i.last_login_at`
+
+type SQLIdentityRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLIdentityRepository(database *sqlx.DB) *SQLIdentityRepository {
+	return &SQLIdentityRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLIdentityRepository) Get(ctx context.Context, provider string, subject string) (*model.UserIdentity, error) {
+	query := "SELECT " + identityColumns + `
+	FROM user_identities i
+	JOIN users u ON u.id = i.user_id
+	WHERE i.provider = :provider AND i.subject = :subject`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var identity model.UserIdentity
+	err = stmt.GetContext(ctx, &identity, map[string]interface{}{
+		"provider": provider,
+		"subject":  subject,
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &identity, nil
+}
+
+func (r *SQLIdentityRepository) List(ctx context.Context, userID int) ([]model.UserIdentity, error) {
+	query := "SELECT " + identityColumns + `
+	FROM user_identities i
+	JOIN users u ON u.id = i.user_id
+	WHERE i.user_id = :user_id
+	ORDER BY i.id`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	identities := []model.UserIdentity{}
+	if err := stmt.SelectContext(ctx, &identities, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *SQLIdentityRepository) Create(ctx context.Context, identity *model.UserIdentity) error {
+	query := r.Dialect.InsertReturning("user_identities",
+		[]string{"user_id", "provider", "subject", "email"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"user_id":  identity.UserID,
+		"provider": identity.Provider,
+		"subject":  identity.Subject,
+		"email":    identity.Email,
+	})
+	if err := row.Scan(&identity.ID, &identity.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLIdentityRepository) RecordLogin(ctx context.Context, id int, email string) error {
+	query := "UPDATE user_identities SET email = :email, last_login_at = :now WHERE id = :id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":    id,
+		"email": email,
+		"now":   time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLIdentityRepository) Delete(ctx context.Context, userID int, id int) error {
+	query := "DELETE FROM user_identities WHERE id = :id AND user_id = :user_id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":      id,
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLIdentityRepository) CreateState(ctx context.Context, state *model.OIDCState) error {
+	// Abandoned requests are cleaned up whenever a new one starts
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM oidc_states WHERE expires_at <= :now",
+		map[string]interface{}{"now": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	query := r.Dialect.InsertReturning("oidc_states",
+		[]string{"state_hash", "provider", "purpose", "user_id", "nonce", "code_verifier", "expires_at"},
+		"id")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"state_hash":    state.StateHash,
+		"provider":      state.Provider,
+		"purpose":       state.Purpose,
+		"user_id":       state.UserID,
+		"nonce":         state.Nonce,
+		"code_verifier": state.CodeVerifier,
+		"expires_at":    state.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&state.ID); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLIdentityRepository) TakeState(ctx context.Context, purpose string, stateHash string) (*model.OIDCState, error) {
+	query := `
+	SELECT id, state_hash, provider, purpose, user_id, nonce, code_verifier, expires_at
+	FROM oidc_states
+	WHERE state_hash = :state_hash AND purpose = :purpose AND expires_at > :now
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var state model.OIDCState
+	err = stmt.GetContext(ctx, &state, map[string]interface{}{
+		"state_hash": stateHash,
+		"purpose":    purpose,
+		"now":        time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+
+	// Whoever deletes the row owns the request
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM oidc_states WHERE id = :id",
+		map[string]interface{}{"id": state.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRows(result); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
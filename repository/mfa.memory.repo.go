@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+type memoryRecoveryCode struct {
+	hash string
+	used bool
+}
+
+type MemoryMFARepository struct {
+	mu    sync.Mutex
+	totp  map[int]*model.TOTPCredential
+	codes map[int][]*memoryRecoveryCode
+}
+
+func NewMemoryMFARepository() *MemoryMFARepository {
+	return &MemoryMFARepository{
+		totp:  make(map[int]*model.TOTPCredential),
+		codes: make(map[int][]*memoryRecoveryCode),
+	}
+}
+
+func (r *MemoryMFARepository) GetTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	credential := *c
+	return &credential, nil
+}
+
+func (r *MemoryMFARepository) SetPendingTOTP(ctx context.Context, userID int, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.totp[userID]; ok && c.ConfirmedAt != nil {
+		return ErrConflict
+	}
+	r.totp[userID] = &model.TOTPCredential{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (r *MemoryMFARepository) ConfirmTOTP(ctx context.Context, userID int, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.totp[userID]
+	if !ok || c.ConfirmedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	c.ConfirmedAt = &now
+	c.LastStep = &step
+	return nil
+}
+
+func (r *MemoryMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.totp[userID]
+	if !ok || c.ConfirmedAt == nil || (c.LastStep != nil && *c.LastStep >= step) {
+		return ErrNotFound
+	}
+	c.LastStep = &step
+	return nil
+}
+
+func (r *MemoryMFARepository) DeleteTOTP(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.totp, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *MemoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := make([]*memoryRecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = &memoryRecoveryCode{hash: hash}
+	}
+	r.codes[userID] = codes
+	return nil
+}
+
+func (r *MemoryMFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, code := range r.codes[userID] {
+		if code.hash == codeHash && !code.used {
+			code.used = true
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryMFARepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, code := range r.codes[userID] {
+		if !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
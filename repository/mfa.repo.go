@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// MFARepository stores the second factors of users: a TOTP secret and a
+// set of hashed one-time recovery codes.
+type MFARepository interface {
+	// GetTOTP returns the TOTP credential of the user, confirmed or not.
+	GetTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error)
+	// SetPendingTOTP stores an unconfirmed secret, replacing an earlier
+	// unconfirmed one. It returns ErrConflict if TOTP is already confirmed.
+	SetPendingTOTP(ctx context.Context, userID int, secret string) error
+	// ConfirmTOTP activates the pending secret and records step as used.
+	ConfirmTOTP(ctx context.Context, userID int, step int64) error
+	// UseTOTPStep records step as used. It returns ErrNotFound if step is
+	// not newer than the last accepted one, so each code works once.
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	// DeleteTOTP removes the TOTP credential and the recovery codes.
+	DeleteTOTP(ctx context.Context, userID int) error
+	// ReplaceRecoveryCodes discards the codes of the user and stores the
+	// given hashes.
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	// UseRecoveryCode marks an unused code as used, or returns ErrNotFound.
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	// CountRecoveryCodes returns how many unused codes the user has left.
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLMFARepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLMFARepository(database *sqlx.DB) *SQLMFARepository {
+	return &SQLMFARepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLMFARepository) GetTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error) {
+	query := `
+	SELECT user_id, secret, confirmed_at, last_step, created_at
+	FROM user_totp WHERE user_id = :user_id
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var credential model.TOTPCredential
+	if err := stmt.GetContext(ctx, &credential, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &credential, nil
+}
+
+func (r *SQLMFARepository) SetPendingTOTP(ctx context.Context, userID int, secret string) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	params := map[string]interface{}{"user_id": userID, "secret": secret}
+	// A confirmed row survives and makes the insert a unique violation
+	_, err = tx.NamedExecContext(ctx, "DELETE FROM user_totp WHERE user_id = :user_id AND confirmed_at IS NULL", params)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NamedExecContext(ctx, "INSERT INTO user_totp (user_id, secret) VALUES (:user_id, :secret)", params)
+	if err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return tx.Commit()
+}
+
+func (r *SQLMFARepository) ConfirmTOTP(ctx context.Context, userID int, step int64) error {
+	query := `
+	UPDATE user_totp SET confirmed_at = :now, last_step = :step
+	WHERE user_id = :user_id AND confirmed_at IS NULL
+	`
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"step":    step,
+		"now":     time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	// A single statement, so two requests with the same code cannot both win
+	query := `
+	UPDATE user_totp SET last_step = :step
+	WHERE user_id = :user_id AND confirmed_at IS NOT NULL
+	  AND (last_step IS NULL OR last_step < :step)
+	`
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"step":    step,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLMFARepository) DeleteTOTP(ctx context.Context, userID int) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	params := map[string]interface{}{"user_id": userID}
+	for _, query := range []string{
+		"DELETE FROM mfa_recovery_codes WHERE user_id = :user_id",
+		"DELETE FROM user_totp WHERE user_id = :user_id",
+	} {
+		if _, err := tx.NamedExecContext(ctx, query, params); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	params := map[string]interface{}{"user_id": userID}
+	if _, err := tx.NamedExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = :user_id", params); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		params["code_hash"] = hash
+		query := "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (:user_id, :code_hash)"
+		if _, err := tx.NamedExecContext(ctx, query, params); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLMFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := `
+	UPDATE mfa_recovery_codes SET used_at = :now
+	WHERE user_id = :user_id AND code_hash = :code_hash AND used_at IS NULL
+	`
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id":   userID,
+		"code_hash": codeHash,
+		"now":       time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLMFARepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	query := "SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = :user_id AND used_at IS NULL"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var count int
+	if err := stmt.GetContext(ctx, &count, map[string]interface{}{"user_id": userID}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryOAuthRepository keeps clients and grants in maps. UserUUID of a
+// code is stored as given to CreateCode since there is no users table to
+// join.
+type MemoryOAuthRepository struct {
+	mu       sync.Mutex
+	clients  map[string]*model.OAuthClient  // keyed by client ID
+	requests map[string]*model.OAuthRequest // keyed by request hash
+	codes    map[string]*model.OAuthCode    // keyed by code hash
+	consents map[oauthConsentKey]string
+	nextID   int
+}
+
+type oauthConsentKey struct {
+	userID   int
+	clientID string
+}
+
+func NewMemoryOAuthRepository() *MemoryOAuthRepository {
+	return &MemoryOAuthRepository{
+		clients:  make(map[string]*model.OAuthClient),
+		requests: make(map[string]*model.OAuthRequest),
+		codes:    make(map[string]*model.OAuthCode),
+		consents: make(map[oauthConsentKey]string),
+	}
+}
+
+func (r *MemoryOAuthRepository) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[client.ClientID]; ok {
+		return ErrConflict
+	}
+	r.nextID++
+	client.ID = r.nextID
+	client.CreatedAt = time.Now()
+	stored := *client
+	r.clients[stored.ClientID] = &stored
+	return nil
+}
+
+func (r *MemoryOAuthRepository) GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.clients[clientID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	client := *c
+	return &client, nil
+}
+
+func (r *MemoryOAuthRepository) ListClients(ctx context.Context) ([]model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := []model.OAuthClient{}
+	for _, c := range r.clients {
+		clients = append(clients, *c)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+func (r *MemoryOAuthRepository) DeleteClient(ctx context.Context, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[clientID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.clients, clientID)
+	for hash, request := range r.requests {
+		if request.ClientID == clientID {
+			delete(r.requests, hash)
+		}
+	}
+	for hash, code := range r.codes {
+		if code.ClientID == clientID {
+			delete(r.codes, hash)
+		}
+	}
+	for key := range r.consents {
+		if key.clientID == clientID {
+			delete(r.consents, key)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryOAuthRepository) CreateRequest(ctx context.Context, request *model.OAuthRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, q := range r.requests {
+		if !q.ExpiresAt.After(now) {
+			delete(r.requests, hash)
+		}
+	}
+	if _, ok := r.requests[request.RequestHash]; ok {
+		return ErrConflict
+	}
+	r.nextID++
+	request.ID = r.nextID
+	stored := *request
+	r.requests[stored.RequestHash] = &stored
+	return nil
+}
+
+func (r *MemoryOAuthRepository) GetRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	q, ok := r.requests[requestHash]
+	if !ok || !q.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	request := *q
+	return &request, nil
+}
+
+func (r *MemoryOAuthRepository) TakeRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	q, ok := r.requests[requestHash]
+	if !ok || !q.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	delete(r.requests, requestHash)
+	request := *q
+	return &request, nil
+}
+
+func (r *MemoryOAuthRepository) CreateCode(ctx context.Context, code *model.OAuthCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, c := range r.codes {
+		if !c.ExpiresAt.After(now) {
+			delete(r.codes, hash)
+		}
+	}
+	if _, ok := r.codes[code.CodeHash]; ok {
+		return ErrConflict
+	}
+	r.nextID++
+	code.ID = r.nextID
+	stored := *code
+	r.codes[stored.CodeHash] = &stored
+	return nil
+}
+
+func (r *MemoryOAuthRepository) TakeCode(ctx context.Context, codeHash string) (*model.OAuthCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.codes[codeHash]
+	if !ok || !c.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	delete(r.codes, codeHash)
+	code := *c
+	return &code, nil
+}
+
+func (r *MemoryOAuthRepository) GetConsent(ctx context.Context, userID int, clientID string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scope, ok := r.consents[oauthConsentKey{userID, clientID}]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return scope, nil
+}
+
+func (r *MemoryOAuthRepository) SaveConsent(ctx context.Context, userID int, clientID string, scope string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[clientID]; !ok {
+		return ErrNotFound
+	}
+	r.consents[oauthConsentKey{userID, clientID}] = scope
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// OAuthRepository stores the apps that let users sign in with their
+// account, and the requests, codes and consents of their logins.
+type OAuthRepository interface {
+	// CreateClient registers a client and fills in its ID and CreatedAt.
+	// It returns ErrConflict if the client ID is taken.
+	CreateClient(ctx context.Context, client *model.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error)
+	ListClients(ctx context.Context) ([]model.OAuthClient, error)
+	// DeleteClient removes a client along with its requests, codes and
+	// consents.
+	DeleteClient(ctx context.Context, clientID string) error
+	// CreateRequest stores a new authorization request.
+	CreateRequest(ctx context.Context, request *model.OAuthRequest) error
+	// GetRequest returns an unexpired authorization request.
+	GetRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error)
+	// TakeRequest removes and returns an unexpired authorization request,
+	// so the user can answer it only once.
+	TakeRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error)
+	// CreateCode stores a new authorization code.
+	CreateCode(ctx context.Context, code *model.OAuthCode) error
+	// TakeCode removes and returns an unexpired authorization code, so it
+	// can be redeemed only once.
+	TakeCode(ctx context.Context, codeHash string) (*model.OAuthCode, error)
+	// GetConsent returns the space separated scopes the user agreed to
+	// share with the client.
+	GetConsent(ctx context.Context, userID int, clientID string) (string, error)
+	// SaveConsent replaces the scopes the user agreed to share.
+	SaveConsent(ctx context.Context, userID int, clientID string, scope string) error
+}
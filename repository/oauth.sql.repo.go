@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const oauthClientColumns = "id, client_id, client_secret_hash, name, redirect_uris, created_at"
+
+type SQLOAuthRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLOAuthRepository(database *sqlx.DB) *SQLOAuthRepository {
+	return &SQLOAuthRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLOAuthRepository) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	query := r.Dialect.InsertReturning("oauth_clients",
+		[]string{"client_id", "client_secret_hash", "name", "redirect_uris"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"client_id":          client.ClientID,
+		"client_secret_hash": client.SecretHash,
+		"name":               client.Name,
+		"redirect_uris":      client.RedirectURIs,
+	})
+	if err := row.Scan(&client.ID, &client.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLOAuthRepository) GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE client_id = :client_id"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var client model.OAuthClient
+	if err := stmt.GetContext(ctx, &client, map[string]interface{}{"client_id": clientID}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &client, nil
+}
+
+func (r *SQLOAuthRepository) ListClients(ctx context.Context) ([]model.OAuthClient, error) {
+	clients := []model.OAuthClient{}
+	err := r.DB.SelectContext(ctx, &clients, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (r *SQLOAuthRepository) DeleteClient(ctx context.Context, clientID string) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Not left to ON DELETE CASCADE, which SQLite only honors with
+	// foreign keys turned on
+	params := map[string]interface{}{"client_id": clientID}
+	for _, query := range []string{
+		"DELETE FROM oauth_requests WHERE client_id = :client_id",
+		"DELETE FROM oauth_codes WHERE client_id = :client_id",
+		"DELETE FROM oauth_consents WHERE client_id = :client_id",
+	} {
+		if _, err := tx.NamedExecContext(ctx, query, params); err != nil {
+			return err
+		}
+	}
+	result, err := tx.NamedExecContext(ctx, "DELETE FROM oauth_clients WHERE client_id = :client_id", params)
+	if err != nil {
+		return err
+	}
+	if err := requireRows(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLOAuthRepository) CreateRequest(ctx context.Context, request *model.OAuthRequest) error {
+	// Abandoned requests are cleaned up whenever a new one starts
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM oauth_requests WHERE expires_at <= :now",
+		map[string]interface{}{"now": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	query := r.Dialect.InsertReturning("oauth_requests",
+		[]string{"request_hash", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "expires_at"},
+		"id")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"request_hash":   request.RequestHash,
+		"client_id":      request.ClientID,
+		"redirect_uri":   request.RedirectURI,
+		"scope":          request.Scope,
+		"state":          request.State,
+		"nonce":          request.Nonce,
+		"code_challenge": request.CodeChallenge,
+		"expires_at":     request.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&request.ID); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLOAuthRepository) GetRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error) {
+	query := `
+	SELECT id, request_hash, client_id, redirect_uri, scope, state, nonce, code_challenge, expires_at
+	FROM oauth_requests
+	WHERE request_hash = :request_hash AND expires_at > :now
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var request model.OAuthRequest
+	err = stmt.GetContext(ctx, &request, map[string]interface{}{
+		"request_hash": requestHash,
+		"now":          time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &request, nil
+}
+
+func (r *SQLOAuthRepository) TakeRequest(ctx context.Context, requestHash string) (*model.OAuthRequest, error) {
+	request, err := r.GetRequest(ctx, requestHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Whoever deletes the row owns the request
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM oauth_requests WHERE id = :id",
+		map[string]interface{}{"id": request.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRows(result); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (r *SQLOAuthRepository) CreateCode(ctx context.Context, code *model.OAuthCode) error {
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM oauth_codes WHERE expires_at <= :now",
+		map[string]interface{}{"now": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	query := r.Dialect.InsertReturning("oauth_codes",
+		[]string{"code_hash", "client_id", "user_id", "redirect_uri", "scope", "nonce", "code_challenge", "expires_at"},
+		"id")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"code_hash":      code.CodeHash,
+		"client_id":      code.ClientID,
+		"user_id":        code.UserID,
+		"redirect_uri":   code.RedirectURI,
+		"scope":          code.Scope,
+		"nonce":          code.Nonce,
+		"code_challenge": code.CodeChallenge,
+		"expires_at":     code.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&code.ID); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLOAuthRepository) TakeCode(ctx context.Context, codeHash string) (*model.OAuthCode, error) {
+	query := `
+	SELECT c.id, c.code_hash, c.client_id, c.user_id, u.uuid AS user_uuid, c.redirect_uri,
+	       c.scope, c.nonce, c.code_challenge, c.expires_at
+	FROM oauth_codes c
+	JOIN users u ON u.id = c.user_id
+	WHERE c.code_hash = :code_hash AND c.expires_at > :now
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var code model.OAuthCode
+	err = stmt.GetContext(ctx, &code, map[string]interface{}{
+		"code_hash": codeHash,
+		"now":       time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+
+	// Whoever deletes the row redeems the code
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM oauth_codes WHERE id = :id",
+		map[string]interface{}{"id": code.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRows(result); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *SQLOAuthRepository) GetConsent(ctx context.Context, userID int, clientID string) (string, error) {
+	query := "SELECT scope FROM oauth_consents WHERE user_id = :user_id AND client_id = :client_id"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	var scope string
+	err = stmt.GetContext(ctx, &scope, map[string]interface{}{
+		"user_id":   userID,
+		"client_id": clientID,
+	})
+	if err != nil {
+		return "", mapError(r.Dialect, err)
+	}
+	return scope, nil
+}
+
+func (r *SQLOAuthRepository) SaveConsent(ctx context.Context, userID int, clientID string, scope string) error {
+	params := map[string]interface{}{
+		"user_id":   userID,
+		"client_id": clientID,
+		"scope":     scope,
+	}
+	update := "UPDATE oauth_consents SET scope = :scope WHERE user_id = :user_id AND client_id = :client_id"
+	insert := "INSERT INTO oauth_consents (user_id, client_id, scope) VALUES (:user_id, :client_id, :scope)"
+
+	result, err := r.DB.NamedExecContext(ctx, update, params)
+	if err != nil {
+		return err
+	}
+	if err := requireRows(result); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	_, err = r.DB.NamedExecContext(ctx, insert, params)
+	if err = mapError(r.Dialect, err); errors.Is(err, ErrConflict) {
+		// Saved concurrently; ours is as good
+		return nil
+	}
+	return err
+}
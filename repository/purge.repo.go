@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// UserPurger wipes deleted accounts once they can no longer be restored.
+type UserPurger struct {
+	Users UserRepository
+	// Grace is how long a deleted account can be restored
+	Grace time.Duration
+}
+
+// Purge anonymizes every user deleted more than Grace ago.
+func (p *UserPurger) Purge(ctx context.Context) (int, error) {
+	return p.Users.Purge(ctx, time.Now().UTC().Add(-p.Grace))
+}
+
+// Start purges now and then every interval until ctx is done.
+func (p *UserPurger) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purged, err := p.Purge(ctx)
+			if err != nil {
+				log.Println("Error purging deleted users:", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted user(s)", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryRateLimitRepository struct {
+	mu        sync.Mutex
+	buckets   map[string]int64
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitRepository() *MemoryRateLimitRepository {
+	return &MemoryRateLimitRepository{buckets: make(map[string]int64)}
+}
+
+func (r *MemoryRateLimitRepository) Get(ctx context.Context, bucket string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tat, ok := r.buckets[bucket]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return tat, nil
+}
+
+func (r *MemoryRateLimitRepository) CompareAndSet(ctx context.Context, bucket string, old int64, tat int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.buckets[bucket]; (ok && current != old) || (!ok && old != 0) {
+		return ErrConflict
+	}
+	r.buckets[bucket] = tat
+
+	// Full buckets are the same as missing ones; drop them once a minute
+	if now := time.Now(); now.Sub(r.lastSweep) > time.Minute {
+		for b, t := range r.buckets {
+			if t < now.UnixNano() {
+				delete(r.buckets, b)
+			}
+		}
+		r.lastSweep = now
+	}
+	return nil
+}
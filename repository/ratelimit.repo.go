@@ -0,0 +1,15 @@
+package repository
+
+import "context"
+
+// RateLimitRepository stores token buckets as the time each one is full
+// again (its theoretical arrival time, or TAT), in Unix nanoseconds. A
+// missing bucket is full.
+type RateLimitRepository interface {
+	// Get returns the TAT of bucket, or ErrNotFound.
+	Get(ctx context.Context, bucket string) (int64, error)
+	// CompareAndSet replaces the TAT of bucket with tat if it is still old,
+	// where an old of 0 means the bucket does not exist yet. It returns
+	// ErrConflict if another request changed the bucket first.
+	CompareAndSet(ctx context.Context, bucket string, old int64, tat int64) error
+}
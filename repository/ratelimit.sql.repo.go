@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "fiet/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLRateLimitRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLRateLimitRepository(database *sqlx.DB) *SQLRateLimitRepository {
+	return &SQLRateLimitRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLRateLimitRepository) Get(ctx context.Context, bucket string) (int64, error) {
+	stmt, err := r.DB.PrepareNamedContext(ctx, "SELECT tat FROM rate_limits WHERE bucket = :bucket")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var tat int64
+	if err := stmt.GetContext(ctx, &tat, map[string]interface{}{"bucket": bucket}); err != nil {
+		return 0, mapError(r.Dialect, err)
+	}
+	return tat, nil
+}
+
+func (r *SQLRateLimitRepository) CompareAndSet(ctx context.Context, bucket string, old int64, tat int64) error {
+	params := map[string]interface{}{
+		"bucket": bucket,
+		"old":    old,
+		"tat":    tat,
+		"now":    time.Now().UnixNano(),
+	}
+
+	if old != 0 {
+		result, err := r.DB.NamedExecContext(ctx, "UPDATE rate_limits SET tat = :tat WHERE bucket = :bucket AND tat = :old", params)
+		if err != nil {
+			return err
+		}
+		if errors.Is(requireRows(result), ErrNotFound) {
+			return ErrConflict
+		}
+		return nil
+	}
+
+	// Full buckets are the same as missing ones; drop them as new ones come in
+	if _, err := r.DB.NamedExecContext(ctx, "DELETE FROM rate_limits WHERE tat < :now", params); err != nil {
+		return err
+	}
+	_, err := r.DB.NamedExecContext(ctx, "INSERT INTO rate_limits (bucket, tat) VALUES (:bucket, :tat)", params)
+	return mapError(r.Dialect, err)
+}
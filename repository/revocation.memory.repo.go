@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+type MemoryRevocationRepository struct {
+	mu      sync.Mutex
+	tokens  map[string]model.RevokedToken // keyed by jti
+	cutoffs map[string]model.TokenCutoff  // keyed by user UUID
+}
+
+func NewMemoryRevocationRepository() *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{
+		tokens:  make(map[string]model.RevokedToken),
+		cutoffs: make(map[string]model.TokenCutoff),
+	}
+}
+
+func (r *MemoryRevocationRepository) RevokeToken(ctx context.Context, token model.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.JTI] = token
+	return nil
+}
+
+func (r *MemoryRevocationRepository) SetCutoff(ctx context.Context, cutoff model.TokenCutoff) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs[cutoff.UserUUID] = cutoff
+	return nil
+}
+
+func (r *MemoryRevocationRepository) ListRevokedTokens(ctx context.Context) ([]model.RevokedToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := make([]model.RevokedToken, 0, len(r.tokens))
+	for _, t := range r.tokens {
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func (r *MemoryRevocationRepository) ListCutoffs(ctx context.Context) ([]model.TokenCutoff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoffs := make([]model.TokenCutoff, 0, len(r.cutoffs))
+	for _, c := range r.cutoffs {
+		cutoffs = append(cutoffs, c)
+	}
+	return cutoffs, nil
+}
+
+func (r *MemoryRevocationRepository) Prune(ctx context.Context, now time.Time, cutoffBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for jti, t := range r.tokens {
+		if t.ExpiresAt.Before(now) {
+			delete(r.tokens, jti)
+		}
+	}
+	for uuid, c := range r.cutoffs {
+		if c.NotBefore.Before(cutoffBefore) {
+			delete(r.cutoffs, uuid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fiet/model"
+)
+
+// RevocationRepository persists revoked access tokens so revocations
+// survive restarts and are shared between replicas.
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, token model.RevokedToken) error
+	// SetCutoff revokes all tokens of the user issued before cutoff.NotBefore.
+	SetCutoff(ctx context.Context, cutoff model.TokenCutoff) error
+	ListRevokedTokens(ctx context.Context) ([]model.RevokedToken, error)
+	ListCutoffs(ctx context.Context) ([]model.TokenCutoff, error)
+	// Prune drops revoked tokens expired before now and cutoffs older than
+	// cutoffBefore, which can no longer match an unexpired token.
+	Prune(ctx context.Context, now time.Time, cutoffBefore time.Time) error
+}
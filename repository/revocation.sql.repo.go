@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLRevocationRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLRevocationRepository(database *sqlx.DB) *SQLRevocationRepository {
+	return &SQLRevocationRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLRevocationRepository) RevokeToken(ctx context.Context, token model.RevokedToken) error {
+	query := "INSERT INTO revoked_tokens (jti, user_uuid, expires_at) VALUES (:jti, :user_uuid, :expires_at)"
+
+	_, err := r.DB.NamedExecContext(ctx, query, token)
+	if err := mapError(r.Dialect, err); err != nil && !errors.Is(err, ErrConflict) {
+		return err
+	}
+	// Already revoked is fine
+	return nil
+}
+
+func (r *SQLRevocationRepository) SetCutoff(ctx context.Context, cutoff model.TokenCutoff) error {
+	update := "UPDATE token_cutoffs SET not_before = :not_before WHERE user_uuid = :user_uuid"
+	insert := "INSERT INTO token_cutoffs (user_uuid, not_before) VALUES (:user_uuid, :not_before)"
+
+	// UPDATE then INSERT works the same on every dialect; retry once if a
+	// concurrent request inserted the row in between.
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := r.DB.NamedExecContext(ctx, update, cutoff)
+		if err != nil {
+			return err
+		}
+		if err := requireRows(result); err == nil {
+			return nil
+		}
+		_, err = r.DB.NamedExecContext(ctx, insert, cutoff)
+		if err = mapError(r.Dialect, err); !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+func (r *SQLRevocationRepository) ListRevokedTokens(ctx context.Context) ([]model.RevokedToken, error) {
+	tokens := []model.RevokedToken{}
+	err := r.DB.SelectContext(ctx, &tokens, "SELECT jti, user_uuid, expires_at FROM revoked_tokens")
+	return tokens, err
+}
+
+func (r *SQLRevocationRepository) ListCutoffs(ctx context.Context) ([]model.TokenCutoff, error) {
+	cutoffs := []model.TokenCutoff{}
+	err := r.DB.SelectContext(ctx, &cutoffs, "SELECT user_uuid, not_before FROM token_cutoffs")
+	return cutoffs, err
+}
+
+func (r *SQLRevocationRepository) Prune(ctx context.Context, now time.Time, cutoffBefore time.Time) error {
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < :now",
+		map[string]interface{}{"now": now})
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.NamedExecContext(ctx, "DELETE FROM token_cutoffs WHERE not_before < :before",
+		map[string]interface{}{"before": cutoffBefore})
+	return err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"fiet/model"
+)
+
+// defaultRoles mirrors the seed data of the 0004_create_roles migration
+// and the permissions added by later migrations.
+var defaultRoles = []model.Role{
+	{ID: 1, Name: model.RoleStudent, Permissions: []string{}},
+	{ID: 2, Name: model.RoleLecturer, Permissions: []string{"users:list"}},
+	{ID: 3, Name: model.RoleStaff, Permissions: []string{"roles:list", "users:list", "users:read"}},
+	{ID: 4, Name: model.RoleAdmin, Permissions: []string{
+		"oauth_clients:manage", "roles:assign", "roles:list", "service_accounts:manage",
+		"users:delete", "users:list", "users:read", "users:update",
+	}},
+}
+
+type MemoryRoleRepository struct {
+	mu        sync.RWMutex
+	roles     []model.Role
+	userRoles map[int]map[string]bool // user ID -> role names
+}
+
+func NewMemoryRoleRepository() *MemoryRoleRepository {
+	return &MemoryRoleRepository{
+		roles:     defaultRoles,
+		userRoles: make(map[int]map[string]bool),
+	}
+}
+
+func (r *MemoryRoleRepository) ListRoles(ctx context.Context) ([]model.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	roles := make([]model.Role, len(r.roles))
+	for i, role := range r.roles {
+		roles[i] = role
+		roles[i].Permissions = append([]string{}, role.Permissions...)
+	}
+	return roles, nil
+}
+
+func (r *MemoryRoleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for name := range r.userRoles[userID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *MemoryRoleRepository) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := map[string]bool{}
+	for _, role := range r.roles {
+		if r.userRoles[userID][role.Name] {
+			for _, permission := range role.Permissions {
+				set[permission] = true
+			}
+		}
+	}
+
+	permissions := []string{}
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (r *MemoryRoleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.exists(role) {
+		return ErrNotFound
+	}
+	if r.userRoles[userID] == nil {
+		r.userRoles[userID] = map[string]bool{}
+	}
+	r.userRoles[userID][role] = true
+	return nil
+}
+
+func (r *MemoryRoleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.userRoles[userID][role] {
+		return ErrNotFound
+	}
+	delete(r.userRoles[userID], role)
+	return nil
+}
+
+func (r *MemoryRoleRepository) exists(role string) bool {
+	for _, known := range r.roles {
+		if known.Name == role {
+			return true
+		}
+	}
+	return false
+}
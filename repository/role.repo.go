@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// RoleRepository manages roles, their permissions and who holds them.
+type RoleRepository interface {
+	// ListRoles returns every role with its permissions.
+	ListRoles(ctx context.Context) ([]model.Role, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	// GetUserPermissions returns the union of the permissions of the user's roles.
+	GetUserPermissions(ctx context.Context, userID int) ([]string, error)
+	// AssignRole gives the user a role; it is a no-op if they already have
+	// it and returns ErrNotFound if the role does not exist.
+	AssignRole(ctx context.Context, userID int, role string) error
+	// RemoveRole takes a role away; ErrNotFound if the user did not have it.
+	RemoveRole(ctx context.Context, userID int, role string) error
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLRoleRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLRoleRepository(database *sqlx.DB) *SQLRoleRepository {
+	return &SQLRoleRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLRoleRepository) ListRoles(ctx context.Context) ([]model.Role, error) {
+	roles := []model.Role{}
+	if err := r.DB.SelectContext(ctx, &roles, "SELECT id, name, description FROM roles ORDER BY id"); err != nil {
+		return nil, err
+	}
+
+	var grants []struct {
+		RoleID     int    `db:"role_id"`
+		Permission string `db:"name"`
+	}
+	query := `
+	SELECT rp.role_id, p.name
+	FROM role_permissions rp
+	JOIN permissions p ON p.id = rp.permission_id
+	ORDER BY p.name
+	`
+	if err := r.DB.SelectContext(ctx, &grants, query); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		roles[i].Permissions = []string{}
+		for _, grant := range grants {
+			if grant.RoleID == roles[i].ID {
+				roles[i].Permissions = append(roles[i].Permissions, grant.Permission)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (r *SQLRoleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	query := `
+	SELECT r.name
+	FROM user_roles ur
+	JOIN roles r ON r.id = ur.role_id
+	WHERE ur.user_id = :user_id
+	ORDER BY r.name
+	`
+	return r.selectNames(ctx, query, userID)
+}
+
+func (r *SQLRoleRepository) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	query := `
+	SELECT DISTINCT p.name
+	FROM user_roles ur
+	JOIN role_permissions rp ON rp.role_id = ur.role_id
+	JOIN permissions p ON p.id = rp.permission_id
+	WHERE ur.user_id = :user_id
+	ORDER BY p.name
+	`
+	return r.selectNames(ctx, query, userID)
+}
+
+func (r *SQLRoleRepository) selectNames(ctx context.Context, query string, userID int) ([]string, error) {
+	query, args, err := sqlx.Named(query, map[string]interface{}{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	if err := r.DB.SelectContext(ctx, &names, r.DB.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (r *SQLRoleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	query := `
+	INSERT INTO user_roles (user_id, role_id)
+	SELECT CAST(:user_id AS INT), id FROM roles WHERE name = :role
+	`
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"role":    role,
+	})
+	if err := mapError(r.Dialect, err); errors.Is(err, ErrConflict) {
+		// Already assigned
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Nothing inserted means the role name is unknown
+	return requireRows(result)
+}
+
+func (r *SQLRoleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	query := `
+	DELETE FROM user_roles
+	WHERE user_id = :user_id AND role_id IN (SELECT id FROM roles WHERE name = :role)
+	`
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"role":    role,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryServiceAccountRepository keeps service accounts in a map by UUID.
+type MemoryServiceAccountRepository struct {
+	mu       sync.Mutex
+	accounts map[string]*model.ServiceAccount
+	nextID   int
+}
+
+func NewMemoryServiceAccountRepository() *MemoryServiceAccountRepository {
+	return &MemoryServiceAccountRepository{accounts: make(map[string]*model.ServiceAccount)}
+}
+
+func (r *MemoryServiceAccountRepository) Create(ctx context.Context, account *model.ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range r.accounts {
+		if a.UUID == account.UUID || a.ClientID == account.ClientID {
+			return ErrConflict
+		}
+	}
+	r.nextID++
+	account.ID = r.nextID
+	account.CreatedAt = time.Now()
+	stored := *account
+	r.accounts[stored.UUID] = &stored
+	return nil
+}
+
+func (r *MemoryServiceAccountRepository) GetByUUID(ctx context.Context, uuid string) (*model.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[uuid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	account := *a
+	return &account, nil
+}
+
+func (r *MemoryServiceAccountRepository) GetByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range r.accounts {
+		if a.ClientID == clientID {
+			account := *a
+			return &account, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryServiceAccountRepository) List(ctx context.Context) ([]model.ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	accounts := make([]model.ServiceAccount, 0, len(r.accounts))
+	for _, a := range r.accounts {
+		accounts = append(accounts, *a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+func (r *MemoryServiceAccountRepository) UpdateSecret(ctx context.Context, uuid string, secretHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[uuid]
+	if !ok {
+		return ErrNotFound
+	}
+	a.SecretHash = secretHash
+	return nil
+}
+
+func (r *MemoryServiceAccountRepository) RecordToken(ctx context.Context, id int, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range r.accounts {
+		if a.ID == id {
+			a.LastTokenAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryServiceAccountRepository) Delete(ctx context.Context, uuid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[uuid]; !ok {
+		return ErrNotFound
+	}
+	delete(r.accounts, uuid)
+	return nil
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fiet/model"
+)
+
+// ServiceAccountRepository stores service accounts and their credentials.
+type ServiceAccountRepository interface {
+	// Create stores an account and fills in its ID and CreatedAt. It
+	// returns ErrConflict if the UUID or client ID is taken.
+	Create(ctx context.Context, account *model.ServiceAccount) error
+	GetByUUID(ctx context.Context, uuid string) (*model.ServiceAccount, error)
+	GetByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error)
+	List(ctx context.Context) ([]model.ServiceAccount, error)
+	// UpdateSecret replaces the client secret.
+	UpdateSecret(ctx context.Context, uuid string, secretHash string) error
+	// RecordToken stores when the account last got an access token.
+	RecordToken(ctx context.Context, id int, now time.Time) error
+	Delete(ctx context.Context, uuid string) error
+}
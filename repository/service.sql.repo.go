@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const serviceAccountColumns = `id, uuid, name, description, client_id, client_secret_hash, permissions,
+	created_at, last_token_at`
+
+type SQLServiceAccountRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLServiceAccountRepository(database *sqlx.DB) *SQLServiceAccountRepository {
+	return &SQLServiceAccountRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLServiceAccountRepository) Create(ctx context.Context, account *model.ServiceAccount) error {
+	query := r.Dialect.InsertReturning("service_accounts",
+		[]string{"uuid", "name", "description", "client_id", "client_secret_hash", "permissions"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"uuid":               account.UUID,
+		"name":               account.Name,
+		"description":        account.Description,
+		"client_id":          account.ClientID,
+		"client_secret_hash": account.SecretHash,
+		"permissions":        account.Permissions,
+	})
+	if err := row.Scan(&account.ID, &account.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLServiceAccountRepository) GetByUUID(ctx context.Context, uuid string) (*model.ServiceAccount, error) {
+	return r.getBy(ctx, "uuid", uuid)
+}
+
+func (r *SQLServiceAccountRepository) GetByClientID(ctx context.Context, clientID string) (*model.ServiceAccount, error) {
+	return r.getBy(ctx, "client_id", clientID)
+}
+
+// getBy finds an account by a unique column; column is never user input.
+func (r *SQLServiceAccountRepository) getBy(ctx context.Context, column string, value string) (*model.ServiceAccount, error) {
+	query := "SELECT " + serviceAccountColumns + " FROM service_accounts WHERE " + column + " = :value"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var account model.ServiceAccount
+	if err := stmt.GetContext(ctx, &account, map[string]interface{}{"value": value}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &account, nil
+}
+
+func (r *SQLServiceAccountRepository) List(ctx context.Context) ([]model.ServiceAccount, error) {
+	query := "SELECT " + serviceAccountColumns + " FROM service_accounts ORDER BY id"
+
+	accounts := []model.ServiceAccount{}
+	if err := r.DB.SelectContext(ctx, &accounts, query); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (r *SQLServiceAccountRepository) UpdateSecret(ctx context.Context, uuid string, secretHash string) error {
+	query := "UPDATE service_accounts SET client_secret_hash = :hash WHERE uuid = :uuid"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid": uuid,
+		"hash": secretHash,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLServiceAccountRepository) RecordToken(ctx context.Context, id int, now time.Time) error {
+	query := "UPDATE service_accounts SET last_token_at = :now WHERE id = :id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":  id,
+		"now": now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLServiceAccountRepository) Delete(ctx context.Context, uuid string) error {
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM service_accounts WHERE uuid = :uuid",
+		map[string]interface{}{"uuid": uuid})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemorySessionRepository keeps sessions in a map, so they are lost on
+// restart and not shared between replicas. UserUUID is stored as given to
+// Create since there is no users table to join.
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[int]*model.Session
+	nextID   int
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{sessions: make(map[int]*model.Session)}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *model.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range r.sessions {
+		if !s.ExpiresAt.After(now) {
+			delete(r.sessions, id)
+		}
+	}
+	r.nextID++
+	session.ID = r.nextID
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	stored := *session
+	r.sessions[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemorySessionRepository) Get(ctx context.Context, userID int, id int) (*model.Session, error) {
+	return r.find(func(s *model.Session) bool { return s.ID == id && s.UserID == userID })
+}
+
+func (r *MemorySessionRepository) GetByHash(ctx context.Context, sessionHash string) (*model.Session, error) {
+	return r.find(func(s *model.Session) bool { return s.SessionHash == sessionHash })
+}
+
+func (r *MemorySessionRepository) GetByFamily(ctx context.Context, familyID string) (*model.Session, error) {
+	return r.find(func(s *model.Session) bool { return s.FamilyID != nil && *s.FamilyID == familyID })
+}
+
+// find returns a copy of the unexpired session matching match.
+func (r *MemorySessionRepository) find(match func(*model.Session) bool) (*model.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range r.sessions {
+		if match(s) && s.ExpiresAt.After(now) {
+			session := *s
+			return &session, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemorySessionRepository) List(ctx context.Context, userID int) ([]model.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	sessions := []model.Session{}
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.ExpiresAt.After(now) {
+			sessions = append(sessions, *s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, id int, now time.Time, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastSeenAt = now
+	s.ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *MemorySessionRepository) SetLastSeen(ctx context.Context, id int, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastSeenAt = now
+	return nil
+}
+
+func (r *MemorySessionRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.sessions, id)
+	return nil
+}
+
+func (r *MemorySessionRepository) DeleteAllForUser(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.sessions {
+		if s.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fiet/model"
+)
+
+// SessionRepository stores the login sessions of users.
+type SessionRepository interface {
+	// Create stores a session and fills in its ID, CreatedAt and LastSeenAt.
+	Create(ctx context.Context, session *model.Session) error
+	// Get finds an unexpired session of the user.
+	Get(ctx context.Context, userID int, id int) (*model.Session, error)
+	// GetByHash finds an unexpired session by the hash of its token.
+	GetByHash(ctx context.Context, sessionHash string) (*model.Session, error)
+	// GetByFamily finds the session of a refresh token family.
+	GetByFamily(ctx context.Context, familyID string) (*model.Session, error)
+	// List returns the unexpired sessions of the user, most recently used first.
+	List(ctx context.Context, userID int) ([]model.Session, error)
+	// Touch records activity and extends the session until expiresAt.
+	Touch(ctx context.Context, id int, now time.Time, expiresAt time.Time) error
+	// SetLastSeen records activity without extending the session.
+	SetLastSeen(ctx context.Context, id int, now time.Time) error
+	Delete(ctx context.Context, id int) error
+	// DeleteAllForUser ends every session of the user.
+	DeleteAllForUser(ctx context.Context, userID int) error
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const sessionColumns = `s.id, s.session_hash, s.csrf_hash, s.user_id, u.uuid AS user_uuid,
+	s.device_name, s.ip_address, s.family_id, s.created_at, s.last_seen_at, s.expires_at`
+
+type SQLSessionRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLSessionRepository(database *sqlx.DB) *SQLSessionRepository {
+	return &SQLSessionRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLSessionRepository) Create(ctx context.Context, session *model.Session) error {
+	// Expired sessions are cleaned up whenever a new one starts
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= :now",
+		map[string]interface{}{"now": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	query := r.Dialect.InsertReturning("sessions",
+		[]string{"session_hash", "csrf_hash", "user_id", "device_name", "ip_address", "family_id", "expires_at"},
+		"id", "created_at", "last_seen_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"session_hash": session.SessionHash,
+		"csrf_hash":    session.CSRFHash,
+		"user_id":      session.UserID,
+		"device_name":  session.DeviceName,
+		"ip_address":   session.IPAddress,
+		"family_id":    session.FamilyID,
+		"expires_at":   session.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLSessionRepository) Get(ctx context.Context, userID int, id int) (*model.Session, error) {
+	return r.get(ctx, "s.id = :id AND s.user_id = :user_id AND s.expires_at > :now",
+		map[string]interface{}{"id": id, "user_id": userID})
+}
+
+func (r *SQLSessionRepository) GetByHash(ctx context.Context, sessionHash string) (*model.Session, error) {
+	return r.get(ctx, "s.session_hash = :session_hash AND s.expires_at > :now",
+		map[string]interface{}{"session_hash": sessionHash})
+}
+
+func (r *SQLSessionRepository) GetByFamily(ctx context.Context, familyID string) (*model.Session, error) {
+	return r.get(ctx, "s.family_id = :family_id AND s.expires_at > :now",
+		map[string]interface{}{"family_id": familyID})
+}
+
+// get returns the one session matching where, which may use :now.
+func (r *SQLSessionRepository) get(ctx context.Context, where string, params map[string]interface{}) (*model.Session, error) {
+	query := "SELECT " + sessionColumns + `
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	WHERE ` + where
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	params["now"] = time.Now().UTC()
+	var session model.Session
+	if err := stmt.GetContext(ctx, &session, params); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &session, nil
+}
+
+func (r *SQLSessionRepository) List(ctx context.Context, userID int) ([]model.Session, error) {
+	query := "SELECT " + sessionColumns + `
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = :user_id AND s.expires_at > :now
+	ORDER BY s.last_seen_at DESC, s.id DESC`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	sessions := []model.Session{}
+	err = stmt.SelectContext(ctx, &sessions, map[string]interface{}{
+		"user_id": userID,
+		"now":     time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *SQLSessionRepository) Touch(ctx context.Context, id int, now time.Time, expiresAt time.Time) error {
+	query := "UPDATE sessions SET last_seen_at = :now, expires_at = :expires_at WHERE id = :id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":         id,
+		"now":        now.UTC(),
+		"expires_at": expiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLSessionRepository) SetLastSeen(ctx context.Context, id int, now time.Time) error {
+	result, err := r.DB.NamedExecContext(ctx, "UPDATE sessions SET last_seen_at = :now WHERE id = :id",
+		map[string]interface{}{"id": id, "now": now.UTC()})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLSessionRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM sessions WHERE id = :id",
+		map[string]interface{}{"id": id})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLSessionRepository) DeleteAllForUser(ctx context.Context, userID int) error {
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM sessions WHERE user_id = :user_id",
+		map[string]interface{}{"user_id": userID})
+	return err
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	db "fiet/database"
+)
+
+// mapError maps driver errors onto the repository errors.
+func mapError(dialect db.Dialect, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if dialect.IsUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// requireRows turns an UPDATE/DELETE that touched nothing into ErrNotFound.
+func requireRows(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
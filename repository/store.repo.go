@@ -0,0 +1,65 @@
+package repository
+
+import "github.com/jmoiron/sqlx"
+
+// Store groups the repositories the controllers depend on, so routes can
+// be wired against either the database or memory.
+type Store struct {
+	Users           UserRepository
+	RefreshTokens   RefreshTokenRepository
+	Revocations     RevocationRepository
+	Roles           RoleRepository
+	Passwords       PasswordHistoryRepository
+	UserTokens      UserTokenRepository
+	MFA             MFARepository
+	WebAuthn        WebAuthnRepository
+	LoginAttempts   LoginAttemptRepository
+	RateLimits      RateLimitRepository
+	Identities      IdentityRepository
+	OAuth           OAuthRepository
+	APIKeys         APIKeyRepository
+	ServiceAccounts ServiceAccountRepository
+	Sessions        SessionRepository
+}
+
+func NewSQLStore(database *sqlx.DB) *Store {
+	return &Store{
+		Users:           NewSQLUserRepository(database),
+		RefreshTokens:   NewSQLRefreshTokenRepository(database),
+		Revocations:     NewSQLRevocationRepository(database),
+		Roles:           NewSQLRoleRepository(database),
+		Passwords:       NewSQLPasswordHistoryRepository(database),
+		UserTokens:      NewSQLUserTokenRepository(database),
+		MFA:             NewSQLMFARepository(database),
+		WebAuthn:        NewSQLWebAuthnRepository(database),
+		LoginAttempts:   NewSQLLoginAttemptRepository(database),
+		RateLimits:      NewSQLRateLimitRepository(database),
+		Identities:      NewSQLIdentityRepository(database),
+		OAuth:           NewSQLOAuthRepository(database),
+		APIKeys:         NewSQLAPIKeyRepository(database),
+		ServiceAccounts: NewSQLServiceAccountRepository(database),
+		Sessions:        NewSQLSessionRepository(database),
+	}
+}
+
+func NewMemoryStore() *Store {
+	users := NewMemoryUserRepository()
+	users.Attempts = NewMemoryLoginAttemptRepository()
+	return &Store{
+		Users:           users,
+		RefreshTokens:   NewMemoryRefreshTokenRepository(),
+		Revocations:     NewMemoryRevocationRepository(),
+		Roles:           NewMemoryRoleRepository(),
+		Passwords:       NewMemoryPasswordHistoryRepository(),
+		UserTokens:      NewMemoryUserTokenRepository(),
+		MFA:             NewMemoryMFARepository(),
+		WebAuthn:        NewMemoryWebAuthnRepository(),
+		LoginAttempts:   users.Attempts,
+		RateLimits:      NewMemoryRateLimitRepository(),
+		Identities:      NewMemoryIdentityRepository(),
+		OAuth:           NewMemoryOAuthRepository(),
+		APIKeys:         NewMemoryAPIKeyRepository(),
+		ServiceAccounts: NewMemoryServiceAccountRepository(),
+		Sessions:        NewMemorySessionRepository(),
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+type MemoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[string]*model.RefreshToken // keyed by token hash
+}
+
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{
+		nextID: 1,
+		tokens: make(map[string]*model.RefreshToken),
+	}
+}
+
+func (r *MemoryRefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insert(token)
+}
+
+func (r *MemoryRefreshTokenRepository) insert(token *model.RefreshToken) error {
+	if _, ok := r.tokens[token.TokenHash]; ok {
+		return ErrConflict
+	}
+	token.ID = r.nextID
+	token.CreatedAt = time.Now()
+	r.nextID++
+
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := *t
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) Rotate(ctx context.Context, old *model.RefreshToken, next *model.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.tokens[old.TokenHash]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.RevokedAt != nil {
+		return ErrConflict
+	}
+	if err := r.insert(next); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	replacedBy := next.ID
+	current.RevokedAt = &now
+	current.ReplacedBy = &replacedBy
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range r.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// RefreshTokenRepository stores hashed refresh tokens.
+type RefreshTokenRepository interface {
+	// Create inserts a new token (the first of its family on login).
+	Create(ctx context.Context, token *model.RefreshToken) error
+	// GetByHash finds a token, revoked or not, with its user's UUID.
+	GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// Rotate inserts next and marks old as revoked and replaced by it. It
+	// returns ErrConflict, and stores nothing, if old was already revoked
+	// (e.g. a concurrent refresh won the race).
+	Rotate(ctx context.Context, old *model.RefreshToken, next *model.RefreshToken) error
+	// RevokeFamily revokes every live token descending from one login.
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeAllForUser revokes every live token of the user.
+	RevokeAllForUser(ctx context.Context, userID int) error
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLRefreshTokenRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLRefreshTokenRepository(database *sqlx.DB) *SQLRefreshTokenRepository {
+	return &SQLRefreshTokenRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLRefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	return r.insert(ctx, r.DB, token)
+}
+
+// insert runs on either the database or a transaction.
+func (r *SQLRefreshTokenRepository) insert(ctx context.Context, ext sqlx.ExtContext, token *model.RefreshToken) error {
+	query := r.Dialect.InsertReturning("refresh_tokens",
+		[]string{"user_id", "family_id", "token_hash", "expires_at"},
+		"id", "created_at")
+
+	query, args, err := sqlx.Named(query, map[string]interface{}{
+		"user_id":    token.UserID,
+		"family_id":  token.FamilyID,
+		"token_hash": token.TokenHash,
+		"expires_at": token.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	row := ext.QueryRowxContext(ctx, ext.Rebind(query), args...)
+	if err := row.Scan(&token.ID, &token.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	query := `
+	SELECT t.id, t.user_id, u.uuid AS user_uuid, t.family_id, t.token_hash,
+	       t.expires_at, t.created_at, t.revoked_at, t.replaced_by
+	FROM refresh_tokens t
+	JOIN users u ON u.id = t.user_id
+	WHERE t.token_hash = :token_hash
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var token model.RefreshToken
+	if err := stmt.GetContext(ctx, &token, map[string]interface{}{"token_hash": tokenHash}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &token, nil
+}
+
+func (r *SQLRefreshTokenRepository) Rotate(ctx context.Context, old *model.RefreshToken, next *model.RefreshToken) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.insert(ctx, tx, next); err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = :now, replaced_by = :replaced_by
+	WHERE id = :id AND revoked_at IS NULL
+	`
+	result, err := tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":          old.ID,
+		"replaced_by": next.ID,
+		"now":         time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := requireRows(result); err != nil {
+		// Someone rotated or revoked it first
+		return ErrConflict
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = :now
+	WHERE family_id = :family_id AND revoked_at IS NULL
+	`
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"family_id": familyID,
+		"now":       time.Now().UTC(),
+	})
+	return err
+}
+
+func (r *SQLRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = :now
+	WHERE user_id = :user_id AND revoked_at IS NULL
+	`
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"now":     time.Now().UTC(),
+	})
+	return err
+}
@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryUserRepository keeps users in a map. It is meant for tests and
+// local development; data is lost when the process exits.
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[string]*model.User // keyed by UUID
+	// Attempts, when set, loses the failed logins of purged users
+	Attempts *MemoryLoginAttemptRepository
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		nextID: 1,
+		users:  make(map[string]*model.User),
+	}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == user.Email || u.UUID == user.UUID {
+			return ErrConflict
+		}
+	}
+
+	now := time.Now()
+	user.ID = r.nextID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.nextID++
+
+	stored := *user
+	r.users[user.UUID] = &stored
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByUUID(ctx context.Context, uuid string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email == email && u.DeletedAt == nil {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, opts UserListOptions) ([]model.PublicUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// before reports whether a comes before b in the requested order
+	before := func(a, b *model.User) bool {
+		c := compareUsers(opts.Sort, a, b)
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if opts.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+
+	var after *model.User
+	if opts.After != "" {
+		var ok bool
+		if after, ok = r.users[opts.After]; !ok {
+			return []model.PublicUser{}, nil
+		}
+	}
+
+	matched := []*model.User{}
+	for _, u := range r.users {
+		if u.DeletedAt == nil && matchesUser(opts.Filter, u) && (after == nil || before(after, u)) {
+			matched = append(matched, u)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return before(matched[i], matched[j]) })
+
+	matched = matched[min(opts.Offset, len(matched)):]
+	if opts.Limit > 0 {
+		matched = matched[:min(opts.Limit, len(matched))]
+	}
+
+	users := make([]model.PublicUser, 0, len(matched))
+	for _, u := range matched {
+		users = append(users, u.Public())
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Count(ctx context.Context, filter UserFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, u := range r.users {
+		if u.DeletedAt == nil && matchesUser(filter, u) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// matchesUser mirrors the WHERE clause built by the SQL repository; like
+// SQL NULLs, users without an age never match an age range.
+func matchesUser(filter UserFilter, u *model.User) bool {
+	if filter.Name != "" && (u.Name == nil || !containsFold(*u.Name, filter.Name)) {
+		return false
+	}
+	if filter.Email != "" && !containsFold(u.Email, filter.Email) {
+		return false
+	}
+	if filter.MinAge != nil && (u.Age == nil || *u.Age < *filter.MinAge) {
+		return false
+	}
+	if filter.MaxAge != nil && (u.Age == nil || *u.Age > *filter.MaxAge) {
+		return false
+	}
+	if filter.CreatedAfter != nil && u.CreatedAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !u.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// compareUsers compares a and b by one of UserSortFields, treating a
+// missing name or age as "" and -1 like the SQL repository does.
+func compareUsers(field string, a, b *model.User) int {
+	switch field {
+	case "name":
+		return strings.Compare(derefOr(a.Name, ""), derefOr(b.Name, ""))
+	case "email":
+		return strings.Compare(a.Email, b.Email)
+	case "age":
+		return cmp.Compare(derefOr(a.Age, -1), derefOr(b.Age, -1))
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
+
+func derefOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, uuid string, fields map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+
+	updated := *u
+	for key, value := range fields {
+		if !UpdatableUserFields[key] {
+			return fmt.Errorf("%w: %s", ErrInvalidField, key)
+		}
+		switch key {
+		case "name":
+			name, err := nullableString(value)
+			if err != nil {
+				return err
+			}
+			updated.Name = name
+		case "age":
+			age, err := nullableInt(value)
+			if err != nil {
+				return err
+			}
+			updated.Age = age
+		case "email":
+			email, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: email must be a string", ErrInvalidField)
+			}
+			for _, other := range r.users {
+				if other.UUID != uuid && other.Email == email {
+					return ErrConflict
+				}
+			}
+			if email != u.Email {
+				updated.EmailVerifiedAt = nil
+			}
+			updated.Email = email
+		}
+	}
+	updated.UpdatedAt = time.Now()
+	r.users[uuid] = &updated
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, uuid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	u.DeletedAt = &now
+	return nil
+}
+
+func (r *MemoryUserRepository) GetDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email == email && u.DeletedAt != nil && u.PurgedAt == nil {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, uuid string, deletedAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[uuid]
+	if !ok || u.DeletedAt == nil || u.PurgedAt != nil || u.DeletedAt.Before(deletedAfter) {
+		return ErrNotFound
+	}
+	u.DeletedAt = nil
+	return nil
+}
+
+// Purge only anonymizes the users; tokens, roles and password history live
+// in other repositories of the memory store.
+func (r *MemoryUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	now := time.Now()
+	for _, u := range r.users {
+		if u.DeletedAt == nil || u.PurgedAt != nil || !u.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		if r.Attempts != nil {
+			if err := r.Attempts.Reset(ctx, AccountAttemptSubject(u.Email)); err != nil {
+				return purged, err
+			}
+		}
+		u.Email = "deleted-" + u.UUID + "@invalid"
+		u.Name = nil
+		u.Age = nil
+		u.Password = ""
+		u.EmailVerifiedAt = nil
+		u.PurgedAt = &now
+		purged++
+	}
+	return purged, nil
+}
+
+// active returns the stored user unless it is missing or deleted.
+func (r *MemoryUserRepository) active(uuid string) (*model.User, bool) {
+	u, ok := r.users[uuid]
+	if !ok || u.DeletedAt != nil {
+		return nil, false
+	}
+	return u, true
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, uuid string, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+	u.Password = passwordHash
+	u.PasswordResetRequired = false
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *MemoryUserRepository) RehashPassword(ctx context.Context, uuid string, oldHash string, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[uuid]
+	if !ok || u.Password != oldHash {
+		return ErrNotFound
+	}
+	u.Password = newHash
+	return nil
+}
+
+func (r *MemoryUserRepository) SetDisabled(ctx context.Context, uuid string, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+	switch {
+	case !disabled:
+		u.DisabledAt = nil
+	case u.DisabledAt == nil:
+		now := time.Now()
+		u.DisabledAt = &now
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) SetPasswordResetRequired(ctx context.Context, uuid string, required bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+	u.PasswordResetRequired = required
+	return nil
+}
+
+func (r *MemoryUserRepository) MarkEmailVerified(ctx context.Context, uuid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.active(uuid)
+	if !ok {
+		return ErrNotFound
+	}
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+func nullableString(value interface{}) (*string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &v, nil
+	}
+	return nil, fmt.Errorf("%w: expected string, got %T", ErrInvalidField, value)
+}
+
+// nullableInt accepts the float64 produced by decoding JSON numbers.
+func nullableInt(value interface{}) (*int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		i := int64(v)
+		return &i, nil
+	case int:
+		i := int64(v)
+		return &i, nil
+	case int64:
+		return &v, nil
+	}
+	return nil, fmt.Errorf("%w: expected number, got %T", ErrInvalidField, value)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fiet/model"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a unique value (e.g. email) is already taken.
+	ErrConflict = errors.New("record already exists")
+	// ErrInvalidField is returned when an update references a column that
+	// is not allowed to be changed.
+	ErrInvalidField = errors.New("invalid field")
+)
+
+// UserRepository abstracts the storage of users so handlers do not depend
+// on a particular database. Deleted users are invisible to every method
+// except GetDeletedByEmail, Restore and Purge.
+type UserRepository interface {
+	// Create inserts a new user and fills in its ID and timestamps.
+	Create(ctx context.Context, user *model.User) error
+	GetByUUID(ctx context.Context, uuid string) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	// List returns one page of users matching opts.
+	List(ctx context.Context, opts UserListOptions) ([]model.PublicUser, error)
+	// Count returns the number of users matching filter.
+	Count(ctx context.Context, filter UserFilter) (int, error)
+	// Update sets the given columns (see UpdatableUserFields) on the user.
+	// Changing the email clears EmailVerifiedAt.
+	Update(ctx context.Context, uuid string, fields map[string]interface{}) error
+	// Delete soft-deletes the user.
+	Delete(ctx context.Context, uuid string) error
+	// GetDeletedByEmail returns a deleted user that has not been purged yet.
+	GetDeletedByEmail(ctx context.Context, email string) (*model.User, error)
+	// Restore undeletes a user deleted at or after deletedAfter.
+	Restore(ctx context.Context, uuid string, deletedAfter time.Time) error
+	// Purge wipes the personal data of users deleted before deletedBefore,
+	// along with their tokens, roles, password history, second factors and
+	// linked identities, and returns how many were purged.
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+	// UpdatePassword sets a new password hash and clears PasswordResetRequired.
+	UpdatePassword(ctx context.Context, uuid string, passwordHash string) error
+	// RehashPassword replaces oldHash with newHash, a hash of the same
+	// password, and returns ErrNotFound when the hash changed meanwhile.
+	RehashPassword(ctx context.Context, uuid string, oldHash string, newHash string) error
+	// SetDisabled disables or re-enables the account.
+	SetDisabled(ctx context.Context, uuid string, disabled bool) error
+	SetPasswordResetRequired(ctx context.Context, uuid string, required bool) error
+	// MarkEmailVerified records that the current email has been confirmed.
+	MarkEmailVerified(ctx context.Context, uuid string) error
+}
+
+// UpdatableUserFields whitelists the columns Update may change.
+var UpdatableUserFields = map[string]bool{
+	"name":  true,
+	"age":   true,
+	"email": true,
+}
+
+// UserSortFields whitelists the fields List may sort by.
+var UserSortFields = map[string]bool{
+	"name":       true,
+	"email":      true,
+	"age":        true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// UserFilter narrows down a user listing. Zero values match everything.
+type UserFilter struct {
+	Name          string // case-insensitive substring
+	Email         string // case-insensitive substring
+	MinAge        *int64
+	MaxAge        *int64
+	CreatedAfter  *time.Time // inclusive
+	CreatedBefore *time.Time // exclusive
+}
+
+// UserListOptions selects a page of users. A page is either addressed by
+// Offset or, for keyset pagination, by After: the UUID of the last user of
+// the previous page. Users without a name or age sort as "" and -1; ties
+// are broken by creation order.
+type UserListOptions struct {
+	Filter UserFilter
+	Sort   string // one of UserSortFields, "created_at" if empty
+	Desc   bool
+	Limit  int // 0 means no limit
+	Offset int
+	After  string
+}
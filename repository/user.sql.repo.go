@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const userColumns = "id, uuid, name, email, age, password_hash, created_at, updated_at, email_verified_at, disabled_at, password_reset_required"
+
+// SQLUserRepository stores users in any of the supported SQL databases.
+type SQLUserRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLUserRepository(database *sqlx.DB) *SQLUserRepository {
+	return &SQLUserRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLUserRepository) Create(ctx context.Context, user *model.User) error {
+	query := r.Dialect.InsertReturning("users",
+		[]string{"uuid", "email", "password_hash"},
+		"id", "created_at", "updated_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	params := map[string]interface{}{
+		"uuid":          user.UUID,
+		"email":         user.Email,
+		"password_hash": user.Password,
+	}
+
+	row := stmt.QueryRowxContext(ctx, params)
+	if err := row.Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLUserRepository) GetByUUID(ctx context.Context, uuid string) (*model.User, error) {
+	return r.getBy(ctx, "uuid", uuid)
+}
+
+func (r *SQLUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.getBy(ctx, "email", email)
+}
+
+func (r *SQLUserRepository) getBy(ctx context.Context, column string, value string) (*model.User, error) {
+	query := fmt.Sprintf("SELECT %s FROM users WHERE %s = :value AND deleted_at IS NULL", userColumns, column)
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var user model.User
+	if err := stmt.GetContext(ctx, &user, map[string]interface{}{"value": value}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &user, nil
+}
+
+// userSortColumns maps UserSortFields to SQL expressions. Nullable columns
+// are coalesced so that every database orders missing values the same way.
+var userSortColumns = map[string]string{
+	"name":       "COALESCE(name, '')",
+	"email":      "email",
+	"age":        "COALESCE(age, -1)",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// likeEscaper escapes LIKE wildcards with '!'; '[' is a wildcard in SQL Server.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "[", "![")
+
+// userConditions builds the WHERE conditions of filter and adds their
+// parameters to params.
+func userConditions(filter UserFilter, params map[string]interface{}) []string {
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.Name != "" {
+		conditions = append(conditions, "LOWER(name) LIKE :name ESCAPE '!'")
+		params["name"] = "%" + likeEscaper.Replace(strings.ToLower(filter.Name)) + "%"
+	}
+	if filter.Email != "" {
+		conditions = append(conditions, "LOWER(email) LIKE :email ESCAPE '!'")
+		params["email"] = "%" + likeEscaper.Replace(strings.ToLower(filter.Email)) + "%"
+	}
+	if filter.MinAge != nil {
+		conditions = append(conditions, "age >= :min_age")
+		params["min_age"] = *filter.MinAge
+	}
+	if filter.MaxAge != nil {
+		conditions = append(conditions, "age <= :max_age")
+		params["max_age"] = *filter.MaxAge
+	}
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= :created_after")
+		params["created_after"] = filter.CreatedAfter.UTC()
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < :created_before")
+		params["created_before"] = filter.CreatedBefore.UTC()
+	}
+	return conditions
+}
+
+func whereClause(conditions []string) string {
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func (r *SQLUserRepository) List(ctx context.Context, opts UserListOptions) ([]model.PublicUser, error) {
+	params := map[string]interface{}{}
+	conditions := userConditions(opts.Filter, params)
+
+	column, ok := userSortColumns[opts.Sort]
+	if !ok {
+		column = userSortColumns["created_at"]
+	}
+	direction, cmp := "ASC", ">"
+	if opts.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if opts.After != "" {
+		// Compare with the cursor row inside the database, so its values
+		// never have to round-trip through Go types.
+		after := fmt.Sprintf("(SELECT %s FROM users WHERE uuid = :after)", column)
+		afterID := "(SELECT id FROM users WHERE uuid = :after)"
+		conditions = append(conditions, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))",
+			column, cmp, after, column, after, cmp, afterID))
+		params["after"] = opts.After
+	}
+
+	query := "SELECT uuid, name, email, age, created_at, updated_at, disabled_at, password_reset_required FROM users" +
+		whereClause(conditions) +
+		fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	if opts.Limit > 0 {
+		query += " " + r.Dialect.Paginate()
+		params["limit"] = opts.Limit
+		params["offset"] = opts.Offset
+	}
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	users := []model.PublicUser{}
+	if err := stmt.SelectContext(ctx, &users, params); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *SQLUserRepository) Count(ctx context.Context, filter UserFilter) (int, error) {
+	params := map[string]interface{}{}
+	query := "SELECT COUNT(*) FROM users" + whereClause(userConditions(filter, params))
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var count int
+	if err := stmt.GetContext(ctx, &count, params); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLUserRepository) Update(ctx context.Context, uuid string, fields map[string]interface{}) error {
+	setClauses := []string{}
+	params := map[string]interface{}{
+		"uuid": uuid,
+	}
+
+	for key, value := range fields {
+		if !UpdatableUserFields[key] {
+			return fmt.Errorf("%w: %s", ErrInvalidField, key)
+		}
+		setClauses = append(setClauses, fmt.Sprintf("%s = :%s", key, key))
+		params[key] = value
+	}
+
+	// A new address has to be verified again; SET sees the old email
+	if _, ok := fields["email"]; ok {
+		setClauses = append(setClauses, "email_verified_at = CASE WHEN email = :email THEN email_verified_at END")
+	}
+
+	// Always update updated_at
+	setClauses = append(setClauses, "updated_at = "+r.Dialect.Now())
+
+	query := fmt.Sprintf("UPDATE users SET %s WHERE uuid = :uuid AND deleted_at IS NULL", strings.Join(setClauses, ", "))
+	result, err := r.DB.NamedExecContext(ctx, query, params)
+	if err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) Delete(ctx context.Context, uuid string) error {
+	query := "UPDATE users SET deleted_at = :now WHERE uuid = :uuid AND deleted_at IS NULL"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid": uuid,
+		"now":  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) GetDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	query := fmt.Sprintf(`SELECT %s FROM users
+	WHERE email = :email AND deleted_at IS NOT NULL AND purged_at IS NULL`, userColumns)
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var user model.User
+	if err := stmt.GetContext(ctx, &user, map[string]interface{}{"email": email}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &user, nil
+}
+
+func (r *SQLUserRepository) Restore(ctx context.Context, uuid string, deletedAfter time.Time) error {
+	query := `
+	UPDATE users SET deleted_at = NULL
+	WHERE uuid = :uuid AND deleted_at >= :deleted_after AND purged_at IS NULL
+	`
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid":          uuid,
+		"deleted_after": deletedAfter.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	query := "SELECT id, uuid, email FROM users WHERE deleted_at < :deleted_before AND purged_at IS NULL"
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var users []model.User
+	if err := stmt.SelectContext(ctx, &users, map[string]interface{}{"deleted_before": deletedBefore.UTC()}); err != nil {
+		return 0, err
+	}
+
+	for i, user := range users {
+		if err := r.purge(ctx, user); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+// purge anonymizes one deleted user. The row itself stays behind as a
+// tombstone so that its id and UUID are never handed out again.
+func (r *SQLUserRepository) purge(ctx context.Context, user model.User) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	params := map[string]interface{}{
+		"id": user.ID,
+		// Frees the address for a new signup
+		"email": "deleted-" + user.UUID + "@invalid",
+		"now":   time.Now().UTC(),
+		// Failed logins are counted under the old address
+		"attempt_subject": AccountAttemptSubject(user.Email),
+	}
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE user_id = :id",
+		"DELETE FROM user_roles WHERE user_id = :id",
+		"DELETE FROM password_history WHERE user_id = :id",
+		"DELETE FROM user_tokens WHERE user_id = :id",
+		"DELETE FROM mfa_recovery_codes WHERE user_id = :id",
+		"DELETE FROM user_totp WHERE user_id = :id",
+		"DELETE FROM webauthn_credentials WHERE user_id = :id",
+		"DELETE FROM webauthn_sessions WHERE user_id = :id",
+		"DELETE FROM user_identities WHERE user_id = :id",
+		"DELETE FROM oidc_states WHERE user_id = :id",
+		"DELETE FROM oauth_codes WHERE user_id = :id",
+		"DELETE FROM oauth_consents WHERE user_id = :id",
+		"DELETE FROM api_keys WHERE user_id = :id",
+		"DELETE FROM sessions WHERE user_id = :id",
+		"DELETE FROM login_attempts WHERE subject = :attempt_subject",
+		`UPDATE users
+		SET email = :email, name = NULL, age = NULL, password_hash = '', email_verified_at = NULL, purged_at = :now
+		WHERE id = :id`,
+	} {
+		if _, err := tx.NamedExecContext(ctx, query, params); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) UpdatePassword(ctx context.Context, uuid string, passwordHash string) error {
+	query := "UPDATE users SET password_hash = :password_hash, password_reset_required = :required, updated_at = " +
+		r.Dialect.Now() + " WHERE uuid = :uuid AND deleted_at IS NULL"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid":          uuid,
+		"password_hash": passwordHash,
+		"required":      false,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) RehashPassword(ctx context.Context, uuid string, oldHash string, newHash string) error {
+	query := "UPDATE users SET password_hash = :new_hash WHERE uuid = :uuid AND password_hash = :old_hash"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid":     uuid,
+		"old_hash": oldHash,
+		"new_hash": newHash,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) SetDisabled(ctx context.Context, uuid string, disabled bool) error {
+	// Keep the original timestamp when disabling twice
+	query := "UPDATE users SET disabled_at = COALESCE(disabled_at, " + r.Dialect.Now() + ") WHERE uuid = :uuid AND deleted_at IS NULL"
+	if !disabled {
+		query = "UPDATE users SET disabled_at = NULL WHERE uuid = :uuid AND deleted_at IS NULL"
+	}
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{"uuid": uuid})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) SetPasswordResetRequired(ctx context.Context, uuid string, required bool) error {
+	query := "UPDATE users SET password_reset_required = :required WHERE uuid = :uuid AND deleted_at IS NULL"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"uuid":     uuid,
+		"required": required,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserRepository) MarkEmailVerified(ctx context.Context, uuid string) error {
+	// Keep the original timestamp when verifying twice
+	query := "UPDATE users SET email_verified_at = COALESCE(email_verified_at, " + r.Dialect.Now() + ") WHERE uuid = :uuid AND deleted_at IS NULL"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{"uuid": uuid})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+// MemoryUserTokenRepository keeps tokens in a map. UserUUID is stored as
+// given to Create since there is no users table to join.
+type MemoryUserTokenRepository struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[string]*model.UserToken // keyed by token hash
+}
+
+func NewMemoryUserTokenRepository() *MemoryUserTokenRepository {
+	return &MemoryUserTokenRepository{
+		nextID: 1,
+		tokens: make(map[string]*model.UserToken),
+	}
+}
+
+func (r *MemoryUserTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tokens[token.TokenHash]; ok {
+		return ErrConflict
+	}
+	token.ID = r.nextID
+	token.CreatedAt = time.Now()
+	r.nextID++
+
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *MemoryUserTokenRepository) GetValid(ctx context.Context, purpose string, tokenHash string) (*model.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.tokens[tokenHash]
+	if !ok || t.Purpose != purpose || t.UsedAt != nil || !time.Now().Before(t.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	token := *t
+	return &token, nil
+}
+
+func (r *MemoryUserTokenRepository) Use(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.tokens {
+		if t.ID == id {
+			if t.UsedAt != nil {
+				return ErrNotFound
+			}
+			now := time.Now()
+			t.UsedAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryUserTokenRepository) RevokeAll(ctx context.Context, userID int, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserTokenRepository) Fail(ctx context.Context, id int, maxAttempts int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.tokens {
+		if t.ID == id && t.UsedAt == nil {
+			t.Attempts++
+			if t.Attempts >= maxAttempts {
+				now := time.Now()
+				t.UsedAt = &now
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// UserTokenRepository stores hashed single-use tokens such as password
+// reset tokens and MFA challenges.
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *model.UserToken) error
+	// GetValid finds an unused, unexpired token of the given purpose, with
+	// its user's UUID.
+	GetValid(ctx context.Context, purpose string, tokenHash string) (*model.UserToken, error)
+	// Use marks the token as used. It returns ErrNotFound if the token was
+	// already used, so only one caller can redeem it.
+	Use(ctx context.Context, id int) error
+	// RevokeAll marks every unused token of the user for purpose as used.
+	RevokeAll(ctx context.Context, userID int, purpose string) error
+	// Fail counts a failed attempt against the token and marks it as used
+	// once maxAttempts is reached.
+	Fail(ctx context.Context, id int, maxAttempts int) error
+}
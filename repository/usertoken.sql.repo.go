@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLUserTokenRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLUserTokenRepository(database *sqlx.DB) *SQLUserTokenRepository {
+	return &SQLUserTokenRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLUserTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	query := r.Dialect.InsertReturning("user_tokens",
+		[]string{"user_id", "purpose", "email", "token_hash", "expires_at"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"user_id":    token.UserID,
+		"purpose":    token.Purpose,
+		"email":      token.Email,
+		"token_hash": token.TokenHash,
+		"expires_at": token.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&token.ID, &token.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLUserTokenRepository) GetValid(ctx context.Context, purpose string, tokenHash string) (*model.UserToken, error) {
+	query := `
+	SELECT t.id, t.user_id, u.uuid AS user_uuid, t.purpose, t.email, t.token_hash,
+	       t.expires_at, t.created_at, t.used_at, t.attempts
+	FROM user_tokens t
+	JOIN users u ON u.id = t.user_id
+	WHERE t.token_hash = :token_hash AND t.purpose = :purpose
+	  AND t.used_at IS NULL AND t.expires_at > :now
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var token model.UserToken
+	err = stmt.GetContext(ctx, &token, map[string]interface{}{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"now":        time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &token, nil
+}
+
+func (r *SQLUserTokenRepository) Use(ctx context.Context, id int) error {
+	query := "UPDATE user_tokens SET used_at = :now WHERE id = :id AND used_at IS NULL"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":  id,
+		"now": time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLUserTokenRepository) RevokeAll(ctx context.Context, userID int, purpose string) error {
+	query := `
+	UPDATE user_tokens SET used_at = :now
+	WHERE user_id = :user_id AND purpose = :purpose AND used_at IS NULL
+	`
+
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"user_id": userID,
+		"purpose": purpose,
+		"now":     time.Now().UTC(),
+	})
+	return err
+}
+
+func (r *SQLUserTokenRepository) Fail(ctx context.Context, id int, maxAttempts int) error {
+	// The CASE sees attempts before the increment
+	query := `
+	UPDATE user_tokens
+	SET attempts = attempts + 1,
+	    used_at = CASE WHEN attempts + 1 >= :max_attempts THEN :now ELSE used_at END
+	WHERE id = :id AND used_at IS NULL
+	`
+
+	_, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":           id,
+		"max_attempts": maxAttempts,
+		"now":          time.Now().UTC(),
+	})
+	return err
+}
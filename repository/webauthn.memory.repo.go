@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fiet/model"
+)
+
+type MemoryWebAuthnRepository struct {
+	mu          sync.Mutex
+	credentials map[int]*model.WebAuthnCredential
+	sessions    map[string]*model.WebAuthnSession
+	nextID      int
+	nextSession int
+}
+
+func NewMemoryWebAuthnRepository() *MemoryWebAuthnRepository {
+	return &MemoryWebAuthnRepository{
+		credentials: make(map[int]*model.WebAuthnCredential),
+		sessions:    make(map[string]*model.WebAuthnSession),
+	}
+}
+
+func (r *MemoryWebAuthnRepository) ListCredentials(ctx context.Context, userID int) ([]model.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credentials := []model.WebAuthnCredential{}
+	for _, c := range r.credentials {
+		if c.UserID == userID {
+			credentials = append(credentials, *c)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].ID < credentials[j].ID })
+	return credentials, nil
+}
+
+func (r *MemoryWebAuthnRepository) GetCredential(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.credentials {
+		if c.CredentialID == credentialID {
+			credential := *c
+			return &credential, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryWebAuthnRepository) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.credentials {
+		if c.CredentialID == credential.CredentialID {
+			return ErrConflict
+		}
+	}
+	r.nextID++
+	credential.ID = r.nextID
+	credential.CreatedAt = time.Now()
+	stored := *credential
+	r.credentials[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) UpdateCredentialUse(ctx context.Context, id int, signCount int64, backupState bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.credentials[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	c.SignCount = signCount
+	c.BackupState = backupState
+	c.LastUsedAt = &now
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredential(ctx context.Context, userID int, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.credentials[id]
+	if !ok || c.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.credentials, id)
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredentials(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, c := range r.credentials {
+		if c.UserID == userID {
+			delete(r.credentials, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) CreateSession(ctx context.Context, session *model.WebAuthnSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, s := range r.sessions {
+		if !s.ExpiresAt.After(now) {
+			delete(r.sessions, hash)
+		}
+	}
+	if _, ok := r.sessions[session.TokenHash]; ok {
+		return ErrConflict
+	}
+	r.nextSession++
+	session.ID = r.nextSession
+	stored := *session
+	r.sessions[stored.TokenHash] = &stored
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) TakeSession(ctx context.Context, purpose string, tokenHash string) (*model.WebAuthnSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[tokenHash]
+	if !ok || s.Purpose != purpose || !s.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	delete(r.sessions, tokenHash)
+	session := *s
+	return &session, nil
+}
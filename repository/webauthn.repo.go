@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"fiet/model"
+)
+
+// WebAuthnRepository stores registered WebAuthn credentials and the state
+// of ceremonies in progress.
+type WebAuthnRepository interface {
+	ListCredentials(ctx context.Context, userID int) ([]model.WebAuthnCredential, error)
+	// GetCredential finds a credential by its base64url credential ID.
+	GetCredential(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error)
+	// CreateCredential stores a new credential and fills in its ID and
+	// CreatedAt. It returns ErrConflict if the credential ID is taken.
+	CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	// UpdateCredentialUse records a successful login with the credential.
+	UpdateCredentialUse(ctx context.Context, id int, signCount int64, backupState bool) error
+	// DeleteCredential removes a credential of the user.
+	DeleteCredential(ctx context.Context, userID int, id int) error
+	// DeleteCredentials removes every credential of the user.
+	DeleteCredentials(ctx context.Context, userID int) error
+	// CreateSession stores the state of a new ceremony.
+	CreateSession(ctx context.Context, session *model.WebAuthnSession) error
+	// TakeSession removes and returns an unexpired ceremony of purpose, so
+	// each challenge can be answered only once.
+	TakeSession(ctx context.Context, purpose string, tokenHash string) (*model.WebAuthnSession, error)
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	db "fiet/database"
+	"fiet/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, attestation_type, aaguid,
+	transports, sign_count, backup_eligible, backup_state, name, created_at, last_used_at`
+
+type SQLWebAuthnRepository struct {
+	DB      *sqlx.DB
+	Dialect db.Dialect
+}
+
+func NewSQLWebAuthnRepository(database *sqlx.DB) *SQLWebAuthnRepository {
+	return &SQLWebAuthnRepository{
+		DB:      database,
+		Dialect: db.DialectFor(database.DriverName()),
+	}
+}
+
+func (r *SQLWebAuthnRepository) ListCredentials(ctx context.Context, userID int) ([]model.WebAuthnCredential, error) {
+	query := "SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE user_id = :user_id ORDER BY id"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	credentials := []model.WebAuthnCredential{}
+	if err := stmt.SelectContext(ctx, &credentials, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (r *SQLWebAuthnRepository) GetCredential(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	query := "SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE credential_id = :credential_id"
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var credential model.WebAuthnCredential
+	if err := stmt.GetContext(ctx, &credential, map[string]interface{}{"credential_id": credentialID}); err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+	return &credential, nil
+}
+
+func (r *SQLWebAuthnRepository) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	query := r.Dialect.InsertReturning("webauthn_credentials",
+		[]string{"user_id", "credential_id", "public_key", "attestation_type", "aaguid",
+			"transports", "sign_count", "backup_eligible", "backup_state", "name"},
+		"id", "created_at")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"user_id":          credential.UserID,
+		"credential_id":    credential.CredentialID,
+		"public_key":       credential.PublicKey,
+		"attestation_type": credential.AttestationType,
+		"aaguid":           credential.AAGUID,
+		"transports":       credential.Transports,
+		"sign_count":       credential.SignCount,
+		"backup_eligible":  credential.BackupEligible,
+		"backup_state":     credential.BackupState,
+		"name":             credential.Name,
+	})
+	if err := row.Scan(&credential.ID, &credential.CreatedAt); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLWebAuthnRepository) UpdateCredentialUse(ctx context.Context, id int, signCount int64, backupState bool) error {
+	query := `
+	UPDATE webauthn_credentials
+	SET sign_count = :sign_count, backup_state = :backup_state, last_used_at = :now
+	WHERE id = :id
+	`
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":           id,
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"now":          time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLWebAuthnRepository) DeleteCredential(ctx context.Context, userID int, id int) error {
+	query := "DELETE FROM webauthn_credentials WHERE id = :id AND user_id = :user_id"
+
+	result, err := r.DB.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":      id,
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+	return requireRows(result)
+}
+
+func (r *SQLWebAuthnRepository) DeleteCredentials(ctx context.Context, userID int) error {
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM webauthn_credentials WHERE user_id = :user_id",
+		map[string]interface{}{"user_id": userID})
+	return err
+}
+
+func (r *SQLWebAuthnRepository) CreateSession(ctx context.Context, session *model.WebAuthnSession) error {
+	now := time.Now().UTC()
+	// Abandoned ceremonies are cleaned up whenever a new one starts
+	_, err := r.DB.NamedExecContext(ctx, "DELETE FROM webauthn_sessions WHERE expires_at <= :now",
+		map[string]interface{}{"now": now})
+	if err != nil {
+		return err
+	}
+
+	query := r.Dialect.InsertReturning("webauthn_sessions",
+		[]string{"token_hash", "purpose", "user_id", "data", "expires_at"},
+		"id")
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	row := stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"token_hash": session.TokenHash,
+		"purpose":    session.Purpose,
+		"user_id":    session.UserID,
+		"data":       session.Data,
+		"expires_at": session.ExpiresAt.UTC(),
+	})
+	if err := row.Scan(&session.ID); err != nil {
+		return mapError(r.Dialect, err)
+	}
+	return nil
+}
+
+func (r *SQLWebAuthnRepository) TakeSession(ctx context.Context, purpose string, tokenHash string) (*model.WebAuthnSession, error) {
+	query := `
+	SELECT id, token_hash, purpose, user_id, data, expires_at
+	FROM webauthn_sessions
+	WHERE token_hash = :token_hash AND purpose = :purpose AND expires_at > :now
+	`
+
+	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var session model.WebAuthnSession
+	err = stmt.GetContext(ctx, &session, map[string]interface{}{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"now":        time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, mapError(r.Dialect, err)
+	}
+
+	// Whoever deletes the row owns the ceremony
+	result, err := r.DB.NamedExecContext(ctx, "DELETE FROM webauthn_sessions WHERE id = :id",
+		map[string]interface{}{"id": session.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRows(result); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fiet/repository"
+	"fmt"
+	"io"
+)
+
+// roleCommand runs the "role" CLI subcommand, mainly to create the first
+// admin before anyone can use the admin API:
+//
+//	role grant <email> <role>
+//	role revoke <email> <role>
+//	role list <email>
+func roleCommand(ctx context.Context, store *repository.Store, args []string, out io.Writer) error {
+	usage := fmt.Errorf("usage: role grant|revoke <email> <role> | role list <email>")
+	if len(args) < 2 {
+		return usage
+	}
+
+	user, err := store.Users.GetByEmail(ctx, args[1])
+	if err != nil {
+		return fmt.Errorf("user %s: %w", args[1], err)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 2:
+		// printed below
+	case args[0] == "grant" && len(args) == 3:
+		if err := store.Roles.AssignRole(ctx, user.ID, args[2]); err != nil {
+			return fmt.Errorf("role %s: %w", args[2], err)
+		}
+	case args[0] == "revoke" && len(args) == 3:
+		if err := store.Roles.RemoveRole(ctx, user.ID, args[2]); err != nil {
+			return fmt.Errorf("role %s: %w", args[2], err)
+		}
+	default:
+		return usage
+	}
+
+	roles, err := store.Roles.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: %v\n", user.Email, roles)
+	return nil
+}
@@ -1,27 +1,107 @@
 package router
 
 import (
+	"fiet/auth"
 	"fiet/controller"
 	"fiet/middleware"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 )
 
-func SetUserRoutes(router *gin.RouterGroup, db *sqlx.DB) {
-	ctls := controller.DBController{Database: db}
+func SetUserRoutes(router *gin.RouterGroup, ctls *controller.DBController) {
+	// Rate limit policies; routes that share a policy share its buckets
+	signupLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "signup", Limit: 10, Period: time.Hour})
+	loginLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "login", Limit: 30, Period: time.Minute})
+	emailLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "email", Limit: 5, Period: time.Hour})
+	tokenLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "token", Limit: 60, Period: time.Minute})
+	oauthLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "oauth", Limit: 600, Period: time.Minute})
+	userLimit := middleware.RateLimit(ctls.RateLimits, middleware.RatePolicy{Name: "user", Limit: 300, Period: time.Minute, Key: middleware.ByAPIKey})
 
 	// Public routes
-	router.POST("/signup", ctls.CreateUser)
-	router.POST("/login", ctls.Login)
+	router.POST("/signup", signupLimit, ctls.CreateUser)
+	router.POST("/login", loginLimit, ctls.Login)
+	router.POST("/login/mfa", loginLimit, ctls.LoginMFA)
+	router.POST("/login/mfa/webauthn/begin", loginLimit, ctls.BeginWebAuthnMFA)
+	router.POST("/login/mfa/webauthn/finish", loginLimit, ctls.FinishWebAuthnMFA)
+	router.POST("/login/webauthn/begin", loginLimit, ctls.BeginWebAuthnLogin)
+	router.POST("/login/webauthn/finish", loginLimit, ctls.FinishWebAuthnLogin)
+	router.GET("/login/oidc/providers", tokenLimit, ctls.GetOIDCProviders)
+	router.POST("/login/oidc/:provider/begin", loginLimit, ctls.BeginOIDCLogin)
+	router.POST("/login/oidc/finish", loginLimit, ctls.FinishOIDCLogin)
+	router.POST("/login/magic-link", emailLimit, ctls.RequestMagicLink)
+	router.GET("/login/magic-link/verify", loginLimit, ctls.VerifyMagicLink)
+	router.POST("/token/refresh", tokenLimit, ctls.RefreshToken)
+	router.POST("/user/restore", loginLimit, ctls.RestoreUser)
+	router.POST("/password/forgot", emailLimit, ctls.ForgotPassword)
+	router.POST("/password/reset", loginLimit, ctls.ResetPassword)
+	router.GET("/verify-email", tokenLimit, ctls.VerifyEmail)
+	router.POST("/verify-email/resend", emailLimit, ctls.ResendVerification)
+	router.GET("/oauth/authorize", loginLimit, ctls.Authorize)
+	router.POST("/oauth/token", oauthLimit, ctls.OAuthToken)
+	router.GET("/oauth/userinfo", oauthLimit, ctls.OAuthUserInfo)
+	router.POST("/oauth/userinfo", oauthLimit, ctls.OAuthUserInfo)
 
 	// Protected routes with middleware
 	protected := router.Group("/")
-	protected.Use(middleware.JWTAuthMiddleware())
+	protected.Use(middleware.JWTAuthMiddleware(ctls.Revoker, auth.NewAPIKeyVerifier(ctls.APIKeys, ctls.Users, ctls.Roles), ctls.SessionManager), userLimit)
+	// Routes that manage the account itself need a login, not an API key
+	loginOnly := middleware.RejectAPIKeys()
 	{
-		protected.GET("/users", ctls.GetUsers)
+		protected.POST("/logout", loginOnly, ctls.Logout)
+		protected.POST("/logout-all", loginOnly, ctls.LogoutAll)
+		protected.GET("/users", middleware.RequirePermission("users:list"), ctls.GetUsers)
 		protected.GET("/user", ctls.GetUserByID)
-		protected.PATCH("/user", ctls.UpdateUser)
-		protected.DELETE("/user", ctls.DeleteUserByID)
+		protected.PATCH("/user", loginOnly, ctls.UpdateUser)
+		protected.DELETE("/user", loginOnly, ctls.DeleteUserByID)
+		protected.POST("/user/password", loginOnly, ctls.ChangePassword)
+		protected.GET("/user/mfa", loginOnly, ctls.GetMFAStatus)
+		protected.POST("/user/mfa/totp", loginOnly, ctls.EnrollTOTP)
+		protected.POST("/user/mfa/totp/confirm", loginOnly, ctls.ConfirmTOTP)
+		protected.POST("/user/mfa/totp/disable", loginOnly, ctls.DisableTOTP)
+		protected.POST("/user/mfa/recovery-codes", loginOnly, ctls.RegenerateRecoveryCodes)
+		protected.POST("/user/webauthn/register/begin", loginOnly, ctls.BeginWebAuthnRegistration)
+		protected.POST("/user/webauthn/register/finish", loginOnly, ctls.FinishWebAuthnRegistration)
+		protected.GET("/user/webauthn/credentials", loginOnly, ctls.ListWebAuthnCredentials)
+		protected.DELETE("/user/webauthn/credentials/:id", loginOnly, ctls.DeleteWebAuthnCredential)
+		protected.GET("/user/identities", loginOnly, ctls.ListIdentities)
+		protected.POST("/user/identities/:provider/begin", loginOnly, ctls.BeginOIDCLink)
+		protected.POST("/user/identities/finish", loginOnly, ctls.FinishOIDCLink)
+		protected.DELETE("/user/identities/:id", loginOnly, ctls.DeleteIdentity)
+		protected.GET("/oauth/requests/:request", loginOnly, ctls.GetOAuthRequest)
+		protected.POST("/oauth/requests/:request", loginOnly, ctls.AnswerOAuthRequest)
+		protected.POST("/user/api-keys", loginOnly, ctls.CreateAPIKey)
+		protected.GET("/user/api-keys", loginOnly, ctls.ListAPIKeys)
+		protected.DELETE("/user/api-keys/:id", loginOnly, ctls.DeleteAPIKey)
+		protected.GET("/user/sessions", loginOnly, ctls.ListSessions)
+		protected.DELETE("/user/sessions/:id", loginOnly, ctls.DeleteSession)
+	}
+
+	// Admin routes, each guarded by its own permission
+	admin := protected.Group("/admin")
+	{
+		admin.GET("/users/:uuid", middleware.RequirePermission("users:read"), ctls.AdminGetUser)
+		admin.PATCH("/users/:uuid", middleware.RequirePermission("users:update"), ctls.AdminUpdateUser)
+		admin.DELETE("/users/:uuid", middleware.RequirePermission("users:delete"), ctls.AdminDeleteUser)
+		admin.POST("/users/:uuid/restore", middleware.RequirePermission("users:delete"), ctls.AdminRestoreUser)
+		admin.POST("/users/:uuid/disable", middleware.RequirePermission("users:update"), ctls.DisableUser)
+		admin.POST("/users/:uuid/enable", middleware.RequirePermission("users:update"), ctls.EnableUser)
+		admin.POST("/users/:uuid/force-password-reset", middleware.RequirePermission("users:update"), ctls.ForcePasswordReset)
+		admin.POST("/users/:uuid/mfa/reset", middleware.RequirePermission("users:update"), ctls.AdminResetMFA)
+		admin.POST("/users/:uuid/unlock", middleware.RequirePermission("users:update"), ctls.UnlockUser)
+
+		admin.GET("/roles", middleware.RequirePermission("roles:list"), ctls.GetRoles)
+		admin.GET("/users/:uuid/roles", middleware.RequirePermission("roles:list"), ctls.GetUserRoles)
+		admin.POST("/users/:uuid/roles", middleware.RequirePermission("roles:assign"), ctls.AssignRole)
+		admin.DELETE("/users/:uuid/roles/:role", middleware.RequirePermission("roles:assign"), ctls.RemoveRole)
+
+		admin.POST("/oauth/clients", middleware.RequirePermission("oauth_clients:manage"), ctls.CreateOAuthClient)
+		admin.GET("/oauth/clients", middleware.RequirePermission("oauth_clients:manage"), ctls.ListOAuthClients)
+		admin.DELETE("/oauth/clients/:client_id", middleware.RequirePermission("oauth_clients:manage"), ctls.DeleteOAuthClient)
+
+		admin.POST("/service-accounts", middleware.RequirePermission("service_accounts:manage"), ctls.CreateServiceAccount)
+		admin.GET("/service-accounts", middleware.RequirePermission("service_accounts:manage"), ctls.ListServiceAccounts)
+		admin.POST("/service-accounts/:uuid/secret", middleware.RequirePermission("service_accounts:manage"), ctls.RotateServiceAccountSecret)
+		admin.DELETE("/service-accounts/:uuid", middleware.RequirePermission("service_accounts:manage"), ctls.DeleteServiceAccount)
 	}
 }
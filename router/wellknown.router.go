@@ -0,0 +1,14 @@
+package router
+
+import (
+	"fiet/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetWellKnownRoutes registers the /.well-known documents, which live at
+// the root of the server rather than under /api/v1.
+func SetWellKnownRoutes(router *gin.Engine, ctls *controller.DBController) {
+	router.GET("/.well-known/jwks.json", controller.JWKS)
+	router.GET("/.well-known/openid-configuration", ctls.OpenIDConfiguration)
+}